@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -13,6 +14,17 @@ import (
 	"google.golang.org/grpc"
 )
 
+// adminToken authenticates admin calls with the --admin-token token.
+type adminToken string
+
+func (t adminToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t adminToken) RequireTransportSecurity() bool {
+	return false
+}
+
 func push(c pb.TorrentStoreClient, path string) error {
 	bytes, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -28,6 +40,40 @@ func push(c pb.TorrentStoreClient, path string) error {
 	return nil
 }
 
+func pushStream(c pb.TorrentStoreClient, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stream, err := c.PushStream(ctx)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 1024*1024)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.TorrentChunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	r, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	fmt.Println(r.InfoHash)
+	return nil
+}
+
 func touch(c pb.TorrentStoreClient, infoHash string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
@@ -54,6 +100,33 @@ func pull(c pb.TorrentStoreClient, infoHash string, path string) error {
 	return nil
 }
 
+func pullStream(c pb.TorrentStoreClient, infoHash string, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stream, err := c.PullStream(ctx, &pb.PullRequest{InfoHash: infoHash})
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if _, err := f.Write(chunk.GetData()); err != nil {
+			return err
+		}
+	}
+	fmt.Println("Pulled")
+	return nil
+}
+
 func files(c pb.TorrentStoreClient, infoHash string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
@@ -68,9 +141,116 @@ func files(c pb.TorrentStoreClient, infoHash string) error {
 	return nil
 }
 
+func check(c pb.TorrentStoreClient, infoHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	r, err := c.Check(ctx, &pb.CheckRequest{InfoHash: infoHash})
+	if err != nil {
+		return err
+	}
+	if !r.GetExists() {
+		fmt.Println("Not found")
+		return nil
+	}
+	fmt.Printf("Found in %s\n", r.GetProvider())
+	return nil
+}
+
+func del(c pb.TorrentStoreClient, infoHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	r, err := c.Delete(ctx, &pb.DeleteRequest{InfoHash: infoHash})
+	if err != nil {
+		return err
+	}
+	for _, t := range r.GetTiers() {
+		if t.GetOk() {
+			fmt.Printf("%s\tdeleted\n", t.GetProvider())
+		} else {
+			fmt.Printf("%s\tfailed: %s\n", t.GetProvider(), t.GetError())
+		}
+	}
+	return nil
+}
+
+func explain(c pb.TorrentStoreClient, infoHash string, path string) error {
+	req := &pb.ExplainStoplistRequest{InfoHash: infoHash}
+	if path != "" {
+		torrent, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		req.Torrent = torrent
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	r, err := c.ExplainStoplist(ctx, req)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("infoHash: %s\nstoplist version: %s\n", r.GetInfoHash(), r.GetVersion())
+	if len(r.GetMatches()) == 0 {
+		fmt.Println("No matches")
+		return nil
+	}
+	for _, m := range r.GetMatches() {
+		fmt.Printf("%s\t%s\t%s\n", m.GetField(), m.GetRule(), strings.Join(m.GetSections(), "+"))
+		fmt.Printf("\tdata: %s\n\tnormalized: %s\n\tstack: %s\n", m.GetData(), m.GetNormalized(), strings.Join(m.GetStack(), ": "))
+	}
+	return nil
+}
+
+func allowlistAdd(c pb.TorrentStoreClient, kind string, value string, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	r, err := c.AddAllowlistEntry(ctx, &pb.AddAllowlistEntryRequest{Entry: &pb.AllowlistEntry{
+		Kind:   kind,
+		Value:  value,
+		Reason: reason,
+	}})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("added %s:%s\n", r.GetEntry().GetKind(), r.GetEntry().GetValue())
+	return nil
+}
+
+func allowlistRemove(c pb.TorrentStoreClient, kind string, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err := c.RemoveAllowlistEntry(ctx, &pb.RemoveAllowlistEntryRequest{Kind: kind, Value: value})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("removed %s:%s\n", kind, value)
+	return nil
+}
+
+func allowlistList(c pb.TorrentStoreClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	r, err := c.ListAllowlist(ctx, &pb.ListAllowlistRequest{})
+	if err != nil {
+		return err
+	}
+	for _, e := range r.GetEntries() {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", e.GetKind(), e.GetValue(),
+			time.Unix(e.GetCreated(), 0).UTC().Format(time.RFC3339), e.GetAuthor(), e.GetReason())
+	}
+	return nil
+}
+
 func withClient(host string, port int, action func(c pb.TorrentStoreClient) error) error {
+	return withAdminClient(host, port, "", action)
+}
+
+func withAdminClient(host string, port int, token string, action func(c pb.TorrentStoreClient) error) error {
 	address := fmt.Sprintf("%s:%d", host, port)
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(adminToken(token)))
+	}
+	conn, err := grpc.Dial(address, opts...)
 	if err != nil {
 		return err
 	}
@@ -97,6 +277,11 @@ func main() {
 			Value:  50051,
 			EnvVar: "TORRENT_STORE_PORT",
 		},
+		cli.StringFlag{
+			Name:   "admin-token",
+			Usage:  "token for admin commands (delete, explain, allowlist)",
+			EnvVar: "TORRENT_STORE_ADMIN_TOKEN",
+		},
 	}
 	app.Commands = []cli.Command{
 		{
@@ -124,9 +309,16 @@ func main() {
 					Name:  "input, i",
 					Usage: "path to the input torrent file",
 				},
+				cli.BoolFlag{
+					Name:  "stream, s",
+					Usage: "push in chunks (for torrents larger than the max message size)",
+				},
 			},
 			Action: func(ctx *cli.Context) error {
 				return withClient(ctx.GlobalString("host"), ctx.GlobalInt("port"), func(c pb.TorrentStoreClient) error {
+					if ctx.Bool("stream") {
+						return pushStream(c, ctx.String("input"))
+					}
 					return push(c, ctx.String("input"))
 				})
 			},
@@ -144,9 +336,16 @@ func main() {
 					Name:  "hash, ha",
 					Usage: "info hash of the torrent file",
 				},
+				cli.BoolFlag{
+					Name:  "stream, s",
+					Usage: "pull in chunks (for torrents larger than the max message size)",
+				},
 			},
 			Action: func(ctx *cli.Context) error {
 				return withClient(ctx.GlobalString("host"), ctx.GlobalInt("port"), func(c pb.TorrentStoreClient) error {
+					if ctx.Bool("stream") {
+						return pullStream(c, ctx.String("hash"), ctx.String("output"))
+					}
 					return pull(c, ctx.String("hash"), ctx.String("output"))
 				})
 			},
@@ -167,6 +366,118 @@ func main() {
 				})
 			},
 		},
+		{
+			Name:    "check",
+			Aliases: []string{"ch"},
+			Usage:   "checks whether torrent exists in the store",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "hash, ha",
+					Usage: "info hash of the torrent file",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				return withClient(ctx.GlobalString("host"), ctx.GlobalInt("port"), func(c pb.TorrentStoreClient) error {
+					return check(c, ctx.String("hash"))
+				})
+			},
+		},
+		{
+			Name:    "delete",
+			Aliases: []string{"del"},
+			Usage:   "deletes torrent and its manifest from every tier",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "hash, ha",
+					Usage: "info hash of the torrent file",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				return withAdminClient(ctx.GlobalString("host"), ctx.GlobalInt("port"), ctx.GlobalString("admin-token"), func(c pb.TorrentStoreClient) error {
+					return del(c, ctx.String("hash"))
+				})
+			},
+		},
+		{
+			Name:    "explain",
+			Aliases: []string{"ex"},
+			Usage:   "explains which data strings of a torrent match the stoplist",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "hash, ha",
+					Usage: "info hash of a stored torrent",
+				},
+				cli.StringFlag{
+					Name:  "input, i",
+					Usage: "path to a torrent file",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				return withAdminClient(ctx.GlobalString("host"), ctx.GlobalInt("port"), ctx.GlobalString("admin-token"), func(c pb.TorrentStoreClient) error {
+					return explain(c, ctx.String("hash"), ctx.String("input"))
+				})
+			},
+		},
+		{
+			Name:    "allowlist",
+			Aliases: []string{"al"},
+			Usage:   "manages the stoplist allowlist",
+			Subcommands: []cli.Command{
+				{
+					Name:  "add",
+					Usage: "lets a torrent through the stoplist",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "kind, k",
+							Usage: "entry kind: hash or name",
+							Value: "hash",
+						},
+						cli.StringFlag{
+							Name:  "value, v",
+							Usage: "info hash or case-insensitive torrent name regexp",
+						},
+						cli.StringFlag{
+							Name:  "reason, r",
+							Usage: "why the torrent is allowed",
+						},
+					},
+					Action: func(ctx *cli.Context) error {
+						return withAdminClient(ctx.GlobalString("host"), ctx.GlobalInt("port"), ctx.GlobalString("admin-token"), func(c pb.TorrentStoreClient) error {
+							return allowlistAdd(c, ctx.String("kind"), ctx.String("value"), ctx.String("reason"))
+						})
+					},
+				},
+				{
+					Name:  "remove",
+					Usage: "removes an allowlist entry",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "kind, k",
+							Usage: "entry kind: hash or name",
+							Value: "hash",
+						},
+						cli.StringFlag{
+							Name:  "value, v",
+							Usage: "info hash or case-insensitive torrent name regexp",
+						},
+					},
+					Action: func(ctx *cli.Context) error {
+						return withAdminClient(ctx.GlobalString("host"), ctx.GlobalInt("port"), ctx.GlobalString("admin-token"), func(c pb.TorrentStoreClient) error {
+							return allowlistRemove(c, ctx.String("kind"), ctx.String("value"))
+						})
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "lists allowlist entries",
+					Action: func(ctx *cli.Context) error {
+						return withAdminClient(ctx.GlobalString("host"), ctx.GlobalInt("port"), ctx.GlobalString("admin-token"), func(c pb.TorrentStoreClient) error {
+							return allowlistList(c)
+						})
+					},
+				},
+			},
+		},
 	}
 	err := app.Run(os.Args)
 	if err != nil {
@@ -6,5 +6,9 @@ import (
 
 func configure(app *cli.App) {
 	serveCmd := makeServeCMD()
-	app.Commands = []cli.Command{serveCmd}
+	reencryptCmd := makeReencryptCMD()
+	migrateKeysCmd := makeMigrateKeysCMD()
+	rescanCmd := makeRescanCMD()
+	stoplistCmd := makeStoplistCMD()
+	app.Commands = []cli.Command{serveCmd, reencryptCmd, migrateKeysCmd, rescanCmd, stoplistCmd}
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	cs "github.com/webtor-io/common-services"
+	s "github.com/webtor-io/torrent-store/services"
+	p "github.com/webtor-io/torrent-store/services/providers"
+)
+
+const (
+	migrateKeysTiersFlag       = "tiers"
+	migrateKeysConcurrencyFlag = "concurrency"
+)
+
+func makeMigrateKeysCMD() cli.Command {
+	migrateKeysCmd := cli.Command{
+		Name:   "migrate-keys",
+		Usage:  "Moves values from the old key layout to the current one",
+		Action: migrateKeys,
+	}
+	configureMigrateKeys(&migrateKeysCmd)
+	return migrateKeysCmd
+}
+
+func configureMigrateKeys(c *cli.Command) {
+	c.Flags = cs.RegisterS3ClientFlags(c.Flags)
+	c.Flags = cs.RegisterRedisClientFlags(c.Flags)
+	c.Flags = p.RegisterRedisFlags(c.Flags)
+	c.Flags = p.RegisterS3Flags(c.Flags)
+	c.Flags = append(c.Flags,
+		cli.StringFlag{
+			Name:  migrateKeysTiersFlag,
+			Usage: "comma-separated tiers to migrate (redis, s3)",
+			Value: p.RedisName + "," + p.S3Name,
+		},
+		cli.IntFlag{
+			Name:  migrateKeysConcurrencyFlag,
+			Usage: "values moved concurrently",
+			Value: 8,
+		},
+	)
+}
+
+// migrateKeys runs against live tiers: serve keeps reading the old layout
+// through the key fallback flags until every value is moved.
+func migrateKeys(c *cli.Context) (err error) {
+	// Setting Redis Client
+	redisCl := cs.NewRedisClient(c)
+	defer redisCl.Close()
+
+	// Setting S3 Client
+	s3Cl := cs.NewS3Client(c, &http.Client{Timeout: time.Minute})
+
+	for _, part := range strings.Split(c.String(migrateKeysTiersFlag), ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		var m p.KeyMigrator
+		switch name {
+		case "":
+			continue
+		case p.RedisName:
+			m = p.NewRedis(c, redisCl)
+		case p.S3Name:
+			if m, err = p.NewS3(c, s3Cl); err != nil {
+				return
+			}
+		default:
+			return errors.Errorf("provider %q in --%v has no key layout", name, migrateKeysTiersFlag)
+		}
+		err = migrateTier(context.Background(), name, m, c.Int(migrateKeysConcurrencyFlag))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// migrateTier lists every infoHash under the old layout and moves it with
+// bounded parallelism. A single failing value is logged and counted, not
+// fatal, so the command can be rerun until it reports nothing failed.
+func migrateTier(ctx context.Context, name string, m p.KeyMigrator, concurrency int) error {
+	t := time.Now()
+	tLog := log.WithField("provider", name)
+	tLog.Info("migrating provider keys")
+	var done, moved atomic.Int64
+	st, err := s.ForEachStoredKey(ctx, s.ListerFunc(m.ListLegacy), concurrency, func(ctx context.Context, h string) error {
+		if n := done.Add(1); n%1000 == 0 {
+			tLog.WithField("scanned", n).WithField("moved", moved.Load()).Info("key migration progress")
+		}
+		ok, err := m.MoveKey(ctx, h)
+		if err != nil {
+			tLog.WithField("infohash", h).WithError(err).Warn("failed to move key")
+			return err
+		}
+		if ok {
+			moved.Add(1)
+		}
+		return nil
+	})
+	tLog = tLog.WithField("scanned", st.Scanned).
+		WithField("moved", moved.Load()).
+		WithField("failed", st.Failed).
+		WithField("duration", time.Since(t))
+	if err != nil {
+		tLog.WithError(err).Error("failed to list provider")
+		return errors.Wrapf(err, "failed to list provider %v", name)
+	}
+	if st.Failed > 0 {
+		tLog.Error("provider keys partially migrated")
+		return errors.Errorf("failed to move %v values of provider %v", st.Failed, name)
+	}
+	tLog.Info("provider keys migrated")
+	return nil
+}
@@ -256,10 +256,12 @@ func (x *CheckRequest) GetInfoHash() string {
 	return ""
 }
 
-// The check response message containing existance flag
+// The check response message containing existance flag and the name of
+// the first provider tier that holds the torrent (empty when not found)
 type CheckReply struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Exists        bool                   `protobuf:"varint,1,opt,name=exists,proto3" json:"exists,omitempty"`
+	Provider      string                 `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -301,6 +303,13 @@ func (x *CheckReply) GetExists() bool {
 	return false
 }
 
+func (x *CheckReply) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
 // The touch response message
 type TouchReply struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -548,46 +557,1202 @@ func (x *FilesReply) GetFiles() []*FileInfo {
 	return nil
 }
 
-var File_proto_torrent_store_proto protoreflect.FileDescriptor
+// The delete request message containing the infoHash
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InfoHash      string                 `protobuf:"bytes,1,opt,name=infoHash,proto3" json:"infoHash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_proto_torrent_store_proto_rawDesc = "" +
-	"\n" +
-	"\x19proto/torrent-store.proto\"'\n" +
-	"\tPushReply\x12\x1a\n" +
-	"\binfoHash\x18\x01 \x01(\tR\binfoHash\"C\n" +
-	"\vPushRequest\x12\x18\n" +
-	"\atorrent\x18\x01 \x01(\fR\atorrent\x12\x1a\n" +
-	"\x06expire\x18\x02 \x01(\x05B\x02\x18\x01R\x06expire\")\n" +
-	"\vPullRequest\x12\x1a\n" +
-	"\binfoHash\x18\x01 \x01(\tR\binfoHash\"%\n" +
-	"\tPullReply\x12\x18\n" +
-	"\atorrent\x18\x01 \x01(\fR\atorrent\"*\n" +
-	"\fCheckRequest\x12\x1a\n" +
-	"\binfoHash\x18\x01 \x01(\tR\binfoHash\"$\n" +
-	"\n" +
-	"CheckReply\x12\x16\n" +
-	"\x06exists\x18\x01 \x01(\bR\x06exists\"\f\n" +
-	"\n" +
-	"TouchReply\"F\n" +
-	"\fTouchRequest\x12\x1a\n" +
-	"\binfoHash\x18\x01 \x01(\tR\binfoHash\x12\x1a\n" +
-	"\x06expire\x18\x02 \x01(\x05B\x02\x18\x01R\x06expire\"*\n" +
-	"\fFilesRequest\x12\x1a\n" +
-	"\binfoHash\x18\x01 \x01(\tR\binfoHash\"6\n" +
-	"\bFileInfo\x12\x12\n" +
-	"\x04path\x18\x01 \x03(\tR\x04path\x12\x16\n" +
-	"\x06length\x18\x02 \x01(\x03R\x06length\"A\n" +
-	"\n" +
-	"FilesReply\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1f\n" +
-	"\x05files\x18\x02 \x03(\v2\t.FileInfoR\x05files2\xa4\x01\n" +
-	"\fTorrentStore\x12\"\n" +
-	"\x04Push\x12\f.PushRequest\x1a\n" +
-	".PushReply\"\x00\x12\"\n" +
-	"\x04Pull\x12\f.PullRequest\x1a\n" +
-	".PullReply\"\x00\x12%\n" +
-	"\x05Touch\x12\r.TouchRequest\x1a\v.TouchReply\"\x00\x12%\n" +
-	"\x05Files\x12\r.FilesRequest\x1a\v.FilesReply\"\x00B\x04Z\x02./b\x06proto3"
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_proto_torrent_store_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DeleteRequest) GetInfoHash() string {
+	if x != nil {
+		return x.InfoHash
+	}
+	return ""
+}
+
+// The outcome of a delete on a single provider tier. error is empty on
+// success.
+type DeleteTierResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Provider      string                 `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	Ok            bool                   `protobuf:"varint,2,opt,name=ok,proto3" json:"ok,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteTierResult) Reset() {
+	*x = DeleteTierResult{}
+	mi := &file_proto_torrent_store_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteTierResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteTierResult) ProtoMessage() {}
+
+func (x *DeleteTierResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteTierResult.ProtoReflect.Descriptor instead.
+func (*DeleteTierResult) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DeleteTierResult) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *DeleteTierResult) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+func (x *DeleteTierResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// The delete response message containing per-tier results in provider order
+type DeleteReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tiers         []*DeleteTierResult    `protobuf:"bytes,1,rep,name=tiers,proto3" json:"tiers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteReply) Reset() {
+	*x = DeleteReply{}
+	mi := &file_proto_torrent_store_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteReply) ProtoMessage() {}
+
+func (x *DeleteReply) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteReply.ProtoReflect.Descriptor instead.
+func (*DeleteReply) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeleteReply) GetTiers() []*DeleteTierResult {
+	if x != nil {
+		return x.Tiers
+	}
+	return nil
+}
+
+// The batch pull request message containing the infoHashes
+type BatchPullRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InfoHashes    []string               `protobuf:"bytes,1,rep,name=infoHashes,proto3" json:"infoHashes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchPullRequest) Reset() {
+	*x = BatchPullRequest{}
+	mi := &file_proto_torrent_store_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchPullRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchPullRequest) ProtoMessage() {}
+
+func (x *BatchPullRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchPullRequest.ProtoReflect.Descriptor instead.
+func (*BatchPullRequest) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *BatchPullRequest) GetInfoHashes() []string {
+	if x != nil {
+		return x.InfoHashes
+	}
+	return nil
+}
+
+// A single batch pull result. code is a google.rpc.Code value (0 = OK) and
+// torrent is only set when code is OK.
+type BatchPullItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InfoHash      string                 `protobuf:"bytes,1,opt,name=infoHash,proto3" json:"infoHash,omitempty"`
+	Torrent       []byte                 `protobuf:"bytes,2,opt,name=torrent,proto3" json:"torrent,omitempty"`
+	Code          int32                  `protobuf:"varint,3,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchPullItem) Reset() {
+	*x = BatchPullItem{}
+	mi := &file_proto_torrent_store_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchPullItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchPullItem) ProtoMessage() {}
+
+func (x *BatchPullItem) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchPullItem.ProtoReflect.Descriptor instead.
+func (*BatchPullItem) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *BatchPullItem) GetInfoHash() string {
+	if x != nil {
+		return x.InfoHash
+	}
+	return ""
+}
+
+func (x *BatchPullItem) GetTorrent() []byte {
+	if x != nil {
+		return x.Torrent
+	}
+	return nil
+}
+
+func (x *BatchPullItem) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *BatchPullItem) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// The batch pull response message containing results in request order
+type BatchPullReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*BatchPullItem       `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchPullReply) Reset() {
+	*x = BatchPullReply{}
+	mi := &file_proto_torrent_store_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchPullReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchPullReply) ProtoMessage() {}
+
+func (x *BatchPullReply) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchPullReply.ProtoReflect.Descriptor instead.
+func (*BatchPullReply) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *BatchPullReply) GetItems() []*BatchPullItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// The batch files request message containing the infoHashes
+type BatchFilesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InfoHashes    []string               `protobuf:"bytes,1,rep,name=infoHashes,proto3" json:"infoHashes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchFilesRequest) Reset() {
+	*x = BatchFilesRequest{}
+	mi := &file_proto_torrent_store_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchFilesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchFilesRequest) ProtoMessage() {}
+
+func (x *BatchFilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchFilesRequest.ProtoReflect.Descriptor instead.
+func (*BatchFilesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *BatchFilesRequest) GetInfoHashes() []string {
+	if x != nil {
+		return x.InfoHashes
+	}
+	return nil
+}
+
+// A single batch files result. code is a google.rpc.Code value (0 = OK) and
+// files is only set when code is OK.
+type BatchFilesItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InfoHash      string                 `protobuf:"bytes,1,opt,name=infoHash,proto3" json:"infoHash,omitempty"`
+	Files         *FilesReply            `protobuf:"bytes,2,opt,name=files,proto3" json:"files,omitempty"`
+	Code          int32                  `protobuf:"varint,3,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchFilesItem) Reset() {
+	*x = BatchFilesItem{}
+	mi := &file_proto_torrent_store_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchFilesItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchFilesItem) ProtoMessage() {}
+
+func (x *BatchFilesItem) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchFilesItem.ProtoReflect.Descriptor instead.
+func (*BatchFilesItem) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *BatchFilesItem) GetInfoHash() string {
+	if x != nil {
+		return x.InfoHash
+	}
+	return ""
+}
+
+func (x *BatchFilesItem) GetFiles() *FilesReply {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *BatchFilesItem) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *BatchFilesItem) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// The batch files response message containing results in request order
+type BatchFilesReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*BatchFilesItem      `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchFilesReply) Reset() {
+	*x = BatchFilesReply{}
+	mi := &file_proto_torrent_store_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchFilesReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchFilesReply) ProtoMessage() {}
+
+func (x *BatchFilesReply) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchFilesReply.ProtoReflect.Descriptor instead.
+func (*BatchFilesReply) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *BatchFilesReply) GetItems() []*BatchFilesItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// A chunk of torrent bytes used by PullStream and PushStream
+type TorrentChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TorrentChunk) Reset() {
+	*x = TorrentChunk{}
+	mi := &file_proto_torrent_store_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TorrentChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TorrentChunk) ProtoMessage() {}
+
+func (x *TorrentChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TorrentChunk.ProtoReflect.Descriptor instead.
+func (*TorrentChunk) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *TorrentChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// The explain stoplist request message containing either the torrent or the
+// infoHash of a stored torrent. The torrent takes precedence when both are
+// set.
+type ExplainStoplistRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InfoHash      string                 `protobuf:"bytes,1,opt,name=infoHash,proto3" json:"infoHash,omitempty"`
+	Torrent       []byte                 `protobuf:"bytes,2,opt,name=torrent,proto3" json:"torrent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExplainStoplistRequest) Reset() {
+	*x = ExplainStoplistRequest{}
+	mi := &file_proto_torrent_store_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExplainStoplistRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExplainStoplistRequest) ProtoMessage() {}
+
+func (x *ExplainStoplistRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExplainStoplistRequest.ProtoReflect.Descriptor instead.
+func (*ExplainStoplistRequest) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ExplainStoplistRequest) GetInfoHash() string {
+	if x != nil {
+		return x.InfoHash
+	}
+	return ""
+}
+
+func (x *ExplainStoplistRequest) GetTorrent() []byte {
+	if x != nil {
+		return x.Torrent
+	}
+	return nil
+}
+
+// A single data string matching the stoplist. field is the torrent field it
+// came from (name, path, comment or createdBy), normalized is the form the
+// rules saw, rule is the main-rule label, sections lists the stoplist
+// sections referenced on the way to the match and stack is the full rule
+// stack.
+type StoplistMatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Field         string                 `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Data          string                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Normalized    string                 `protobuf:"bytes,3,opt,name=normalized,proto3" json:"normalized,omitempty"`
+	Rule          string                 `protobuf:"bytes,4,opt,name=rule,proto3" json:"rule,omitempty"`
+	Sections      []string               `protobuf:"bytes,5,rep,name=sections,proto3" json:"sections,omitempty"`
+	Stack         []string               `protobuf:"bytes,6,rep,name=stack,proto3" json:"stack,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StoplistMatch) Reset() {
+	*x = StoplistMatch{}
+	mi := &file_proto_torrent_store_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StoplistMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoplistMatch) ProtoMessage() {}
+
+func (x *StoplistMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoplistMatch.ProtoReflect.Descriptor instead.
+func (*StoplistMatch) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *StoplistMatch) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *StoplistMatch) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *StoplistMatch) GetNormalized() string {
+	if x != nil {
+		return x.Normalized
+	}
+	return ""
+}
+
+func (x *StoplistMatch) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *StoplistMatch) GetSections() []string {
+	if x != nil {
+		return x.Sections
+	}
+	return nil
+}
+
+func (x *StoplistMatch) GetStack() []string {
+	if x != nil {
+		return x.Stack
+	}
+	return nil
+}
+
+// The explain stoplist response message containing every match in data
+// order and the version of the stoplist used
+type ExplainStoplistReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InfoHash      string                 `protobuf:"bytes,1,opt,name=infoHash,proto3" json:"infoHash,omitempty"`
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Matches       []*StoplistMatch       `protobuf:"bytes,3,rep,name=matches,proto3" json:"matches,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExplainStoplistReply) Reset() {
+	*x = ExplainStoplistReply{}
+	mi := &file_proto_torrent_store_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExplainStoplistReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExplainStoplistReply) ProtoMessage() {}
+
+func (x *ExplainStoplistReply) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExplainStoplistReply.ProtoReflect.Descriptor instead.
+func (*ExplainStoplistReply) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ExplainStoplistReply) GetInfoHash() string {
+	if x != nil {
+		return x.InfoHash
+	}
+	return ""
+}
+
+func (x *ExplainStoplistReply) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *ExplainStoplistReply) GetMatches() []*StoplistMatch {
+	if x != nil {
+		return x.Matches
+	}
+	return nil
+}
+
+// An allowlist entry. kind is "hash" (value is an infoHash) or "name"
+// (value is a case-insensitive regexp over the torrent name). author,
+// reason and created (unix seconds) are the audit trail.
+type AllowlistEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Author        string                 `protobuf:"bytes,3,opt,name=author,proto3" json:"author,omitempty"`
+	Reason        string                 `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	Created       int64                  `protobuf:"varint,5,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllowlistEntry) Reset() {
+	*x = AllowlistEntry{}
+	mi := &file_proto_torrent_store_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllowlistEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllowlistEntry) ProtoMessage() {}
+
+func (x *AllowlistEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllowlistEntry.ProtoReflect.Descriptor instead.
+func (*AllowlistEntry) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *AllowlistEntry) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *AllowlistEntry) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *AllowlistEntry) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *AllowlistEntry) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *AllowlistEntry) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+// The add allowlist entry request message. reason is required; author and
+// created are ignored and set by the server, author being the name of the
+// admin token of the call.
+type AddAllowlistEntryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entry         *AllowlistEntry        `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddAllowlistEntryRequest) Reset() {
+	*x = AddAllowlistEntryRequest{}
+	mi := &file_proto_torrent_store_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddAllowlistEntryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddAllowlistEntryRequest) ProtoMessage() {}
+
+func (x *AddAllowlistEntryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddAllowlistEntryRequest.ProtoReflect.Descriptor instead.
+func (*AddAllowlistEntryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *AddAllowlistEntryRequest) GetEntry() *AllowlistEntry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+// The add allowlist entry response message containing the stored entry
+type AddAllowlistEntryReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entry         *AllowlistEntry        `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddAllowlistEntryReply) Reset() {
+	*x = AddAllowlistEntryReply{}
+	mi := &file_proto_torrent_store_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddAllowlistEntryReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddAllowlistEntryReply) ProtoMessage() {}
+
+func (x *AddAllowlistEntryReply) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddAllowlistEntryReply.ProtoReflect.Descriptor instead.
+func (*AddAllowlistEntryReply) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *AddAllowlistEntryReply) GetEntry() *AllowlistEntry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+// The remove allowlist entry request message. author is ignored, logs
+// record the name of the admin token of the call.
+type RemoveAllowlistEntryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Author        string                 `protobuf:"bytes,3,opt,name=author,proto3" json:"author,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveAllowlistEntryRequest) Reset() {
+	*x = RemoveAllowlistEntryRequest{}
+	mi := &file_proto_torrent_store_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveAllowlistEntryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveAllowlistEntryRequest) ProtoMessage() {}
+
+func (x *RemoveAllowlistEntryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveAllowlistEntryRequest.ProtoReflect.Descriptor instead.
+func (*RemoveAllowlistEntryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *RemoveAllowlistEntryRequest) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *RemoveAllowlistEntryRequest) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *RemoveAllowlistEntryRequest) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+// The remove allowlist entry response message
+type RemoveAllowlistEntryReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveAllowlistEntryReply) Reset() {
+	*x = RemoveAllowlistEntryReply{}
+	mi := &file_proto_torrent_store_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveAllowlistEntryReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveAllowlistEntryReply) ProtoMessage() {}
+
+func (x *RemoveAllowlistEntryReply) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveAllowlistEntryReply.ProtoReflect.Descriptor instead.
+func (*RemoveAllowlistEntryReply) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{28}
+}
+
+// The list allowlist request message
+type ListAllowlistRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAllowlistRequest) Reset() {
+	*x = ListAllowlistRequest{}
+	mi := &file_proto_torrent_store_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAllowlistRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAllowlistRequest) ProtoMessage() {}
+
+func (x *ListAllowlistRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAllowlistRequest.ProtoReflect.Descriptor instead.
+func (*ListAllowlistRequest) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{29}
+}
+
+// The list allowlist response message containing every entry
+type ListAllowlistReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*AllowlistEntry      `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAllowlistReply) Reset() {
+	*x = ListAllowlistReply{}
+	mi := &file_proto_torrent_store_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAllowlistReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAllowlistReply) ProtoMessage() {}
+
+func (x *ListAllowlistReply) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_torrent_store_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAllowlistReply.ProtoReflect.Descriptor instead.
+func (*ListAllowlistReply) Descriptor() ([]byte, []int) {
+	return file_proto_torrent_store_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *ListAllowlistReply) GetEntries() []*AllowlistEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+var File_proto_torrent_store_proto protoreflect.FileDescriptor
+
+const file_proto_torrent_store_proto_rawDesc = "" +
+	"\n" +
+	"\x19proto/torrent-store.proto\"'\n" +
+	"\tPushReply\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\"C\n" +
+	"\vPushRequest\x12\x18\n" +
+	"\atorrent\x18\x01 \x01(\fR\atorrent\x12\x1a\n" +
+	"\x06expire\x18\x02 \x01(\x05B\x02\x18\x01R\x06expire\")\n" +
+	"\vPullRequest\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\"%\n" +
+	"\tPullReply\x12\x18\n" +
+	"\atorrent\x18\x01 \x01(\fR\atorrent\"*\n" +
+	"\fCheckRequest\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\"@\n" +
+	"\n" +
+	"CheckReply\x12\x16\n" +
+	"\x06exists\x18\x01 \x01(\bR\x06exists\x12\x1a\n" +
+	"\bprovider\x18\x02 \x01(\tR\bprovider\"\f\n" +
+	"\n" +
+	"TouchReply\"F\n" +
+	"\fTouchRequest\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\x12\x1a\n" +
+	"\x06expire\x18\x02 \x01(\x05B\x02\x18\x01R\x06expire\"*\n" +
+	"\fFilesRequest\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\"6\n" +
+	"\bFileInfo\x12\x12\n" +
+	"\x04path\x18\x01 \x03(\tR\x04path\x12\x16\n" +
+	"\x06length\x18\x02 \x01(\x03R\x06length\"A\n" +
+	"\n" +
+	"FilesReply\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1f\n" +
+	"\x05files\x18\x02 \x03(\v2\t.FileInfoR\x05files\"+\n" +
+	"\rDeleteRequest\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\"T\n" +
+	"\x10DeleteTierResult\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12\x0e\n" +
+	"\x02ok\x18\x02 \x01(\bR\x02ok\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"6\n" +
+	"\vDeleteReply\x12'\n" +
+	"\x05tiers\x18\x01 \x03(\v2\x11.DeleteTierResultR\x05tiers\"2\n" +
+	"\x10BatchPullRequest\x12\x1e\n" +
+	"\n" +
+	"infoHashes\x18\x01 \x03(\tR\n" +
+	"infoHashes\"s\n" +
+	"\rBatchPullItem\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\x12\x18\n" +
+	"\atorrent\x18\x02 \x01(\fR\atorrent\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\x05R\x04code\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\"6\n" +
+	"\x0eBatchPullReply\x12$\n" +
+	"\x05items\x18\x01 \x03(\v2\x0e.BatchPullItemR\x05items\"3\n" +
+	"\x11BatchFilesRequest\x12\x1e\n" +
+	"\n" +
+	"infoHashes\x18\x01 \x03(\tR\n" +
+	"infoHashes\"}\n" +
+	"\x0eBatchFilesItem\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\x12!\n" +
+	"\x05files\x18\x02 \x01(\v2\v.FilesReplyR\x05files\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\x05R\x04code\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\"8\n" +
+	"\x0fBatchFilesReply\x12%\n" +
+	"\x05items\x18\x01 \x03(\v2\x0f.BatchFilesItemR\x05items\"\"\n" +
+	"\fTorrentChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"N\n" +
+	"\x16ExplainStoplistRequest\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\x12\x18\n" +
+	"\atorrent\x18\x02 \x01(\fR\atorrent\"\x9f\x01\n" +
+	"\rStoplistMatch\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\tR\x04data\x12\x1e\n" +
+	"\n" +
+	"normalized\x18\x03 \x01(\tR\n" +
+	"normalized\x12\x12\n" +
+	"\x04rule\x18\x04 \x01(\tR\x04rule\x12\x1a\n" +
+	"\bsections\x18\x05 \x03(\tR\bsections\x12\x14\n" +
+	"\x05stack\x18\x06 \x03(\tR\x05stack\"v\n" +
+	"\x14ExplainStoplistReply\x12\x1a\n" +
+	"\binfoHash\x18\x01 \x01(\tR\binfoHash\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12(\n" +
+	"\amatches\x18\x03 \x03(\v2\x0e.StoplistMatchR\amatches\"\x84\x01\n" +
+	"\x0eAllowlistEntry\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\x12\x16\n" +
+	"\x06author\x18\x03 \x01(\tR\x06author\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\x12\x18\n" +
+	"\acreated\x18\x05 \x01(\x03R\acreated\"A\n" +
+	"\x18AddAllowlistEntryRequest\x12%\n" +
+	"\x05entry\x18\x01 \x01(\v2\x0f.AllowlistEntryR\x05entry\"?\n" +
+	"\x16AddAllowlistEntryReply\x12%\n" +
+	"\x05entry\x18\x01 \x01(\v2\x0f.AllowlistEntryR\x05entry\"_\n" +
+	"\x1bRemoveAllowlistEntryRequest\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\x12\x16\n" +
+	"\x06author\x18\x03 \x01(\tR\x06author\"\x1b\n" +
+	"\x19RemoveAllowlistEntryReply\"\x16\n" +
+	"\x14ListAllowlistRequest\"?\n" +
+	"\x12ListAllowlistReply\x12)\n" +
+	"\aentries\x18\x01 \x03(\v2\x0f.AllowlistEntryR\aentries2\xdd\x05\n" +
+	"\fTorrentStore\x12\"\n" +
+	"\x04Push\x12\f.PushRequest\x1a\n" +
+	".PushReply\"\x00\x12\"\n" +
+	"\x04Pull\x12\f.PullRequest\x1a\n" +
+	".PullReply\"\x00\x12%\n" +
+	"\x05Touch\x12\r.TouchRequest\x1a\v.TouchReply\"\x00\x12%\n" +
+	"\x05Files\x12\r.FilesRequest\x1a\v.FilesReply\"\x00\x12%\n" +
+	"\x05Check\x12\r.CheckRequest\x1a\v.CheckReply\"\x00\x12(\n" +
+	"\x06Delete\x12\x0e.DeleteRequest\x1a\f.DeleteReply\"\x00\x121\n" +
+	"\tBatchPull\x12\x11.BatchPullRequest\x1a\x0f.BatchPullReply\"\x00\x124\n" +
+	"\n" +
+	"BatchFiles\x12\x12.BatchFilesRequest\x1a\x10.BatchFilesReply\"\x00\x12-\n" +
+	"\n" +
+	"PullStream\x12\f.PullRequest\x1a\r.TorrentChunk\"\x000\x01\x12+\n" +
+	"\n" +
+	"PushStream\x12\r.TorrentChunk\x1a\n" +
+	".PushReply\"\x00(\x01\x12C\n" +
+	"\x0fExplainStoplist\x12\x17.ExplainStoplistRequest\x1a\x15.ExplainStoplistReply\"\x00\x12I\n" +
+	"\x11AddAllowlistEntry\x12\x19.AddAllowlistEntryRequest\x1a\x17.AddAllowlistEntryReply\"\x00\x12R\n" +
+	"\x14RemoveAllowlistEntry\x12\x1c.RemoveAllowlistEntryRequest\x1a\x1a.RemoveAllowlistEntryReply\"\x00\x12=\n" +
+	"\rListAllowlist\x12\x15.ListAllowlistRequest\x1a\x13.ListAllowlistReply\"\x00B\x04Z\x02./b\x06proto3"
 
 var (
 	file_proto_torrent_store_proto_rawDescOnce sync.Once
@@ -601,35 +1766,83 @@ func file_proto_torrent_store_proto_rawDescGZIP() []byte {
 	return file_proto_torrent_store_proto_rawDescData
 }
 
-var file_proto_torrent_store_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_proto_torrent_store_proto_msgTypes = make([]protoimpl.MessageInfo, 31)
 var file_proto_torrent_store_proto_goTypes = []any{
-	(*PushReply)(nil),    // 0: PushReply
-	(*PushRequest)(nil),  // 1: PushRequest
-	(*PullRequest)(nil),  // 2: PullRequest
-	(*PullReply)(nil),    // 3: PullReply
-	(*CheckRequest)(nil), // 4: CheckRequest
-	(*CheckReply)(nil),   // 5: CheckReply
-	(*TouchReply)(nil),   // 6: TouchReply
-	(*TouchRequest)(nil), // 7: TouchRequest
-	(*FilesRequest)(nil), // 8: FilesRequest
-	(*FileInfo)(nil),     // 9: FileInfo
-	(*FilesReply)(nil),   // 10: FilesReply
+	(*PushReply)(nil),                   // 0: PushReply
+	(*PushRequest)(nil),                 // 1: PushRequest
+	(*PullRequest)(nil),                 // 2: PullRequest
+	(*PullReply)(nil),                   // 3: PullReply
+	(*CheckRequest)(nil),                // 4: CheckRequest
+	(*CheckReply)(nil),                  // 5: CheckReply
+	(*TouchReply)(nil),                  // 6: TouchReply
+	(*TouchRequest)(nil),                // 7: TouchRequest
+	(*FilesRequest)(nil),                // 8: FilesRequest
+	(*FileInfo)(nil),                    // 9: FileInfo
+	(*FilesReply)(nil),                  // 10: FilesReply
+	(*DeleteRequest)(nil),               // 11: DeleteRequest
+	(*DeleteTierResult)(nil),            // 12: DeleteTierResult
+	(*DeleteReply)(nil),                 // 13: DeleteReply
+	(*BatchPullRequest)(nil),            // 14: BatchPullRequest
+	(*BatchPullItem)(nil),               // 15: BatchPullItem
+	(*BatchPullReply)(nil),              // 16: BatchPullReply
+	(*BatchFilesRequest)(nil),           // 17: BatchFilesRequest
+	(*BatchFilesItem)(nil),              // 18: BatchFilesItem
+	(*BatchFilesReply)(nil),             // 19: BatchFilesReply
+	(*TorrentChunk)(nil),                // 20: TorrentChunk
+	(*ExplainStoplistRequest)(nil),      // 21: ExplainStoplistRequest
+	(*StoplistMatch)(nil),               // 22: StoplistMatch
+	(*ExplainStoplistReply)(nil),        // 23: ExplainStoplistReply
+	(*AllowlistEntry)(nil),              // 24: AllowlistEntry
+	(*AddAllowlistEntryRequest)(nil),    // 25: AddAllowlistEntryRequest
+	(*AddAllowlistEntryReply)(nil),      // 26: AddAllowlistEntryReply
+	(*RemoveAllowlistEntryRequest)(nil), // 27: RemoveAllowlistEntryRequest
+	(*RemoveAllowlistEntryReply)(nil),   // 28: RemoveAllowlistEntryReply
+	(*ListAllowlistRequest)(nil),        // 29: ListAllowlistRequest
+	(*ListAllowlistReply)(nil),          // 30: ListAllowlistReply
 }
 var file_proto_torrent_store_proto_depIdxs = []int32{
 	9,  // 0: FilesReply.files:type_name -> FileInfo
-	1,  // 1: TorrentStore.Push:input_type -> PushRequest
-	2,  // 2: TorrentStore.Pull:input_type -> PullRequest
-	7,  // 3: TorrentStore.Touch:input_type -> TouchRequest
-	8,  // 4: TorrentStore.Files:input_type -> FilesRequest
-	0,  // 5: TorrentStore.Push:output_type -> PushReply
-	3,  // 6: TorrentStore.Pull:output_type -> PullReply
-	6,  // 7: TorrentStore.Touch:output_type -> TouchReply
-	10, // 8: TorrentStore.Files:output_type -> FilesReply
-	5,  // [5:9] is the sub-list for method output_type
-	1,  // [1:5] is the sub-list for method input_type
-	1,  // [1:1] is the sub-list for extension type_name
-	1,  // [1:1] is the sub-list for extension extendee
-	0,  // [0:1] is the sub-list for field type_name
+	12, // 1: DeleteReply.tiers:type_name -> DeleteTierResult
+	15, // 2: BatchPullReply.items:type_name -> BatchPullItem
+	10, // 3: BatchFilesItem.files:type_name -> FilesReply
+	18, // 4: BatchFilesReply.items:type_name -> BatchFilesItem
+	22, // 5: ExplainStoplistReply.matches:type_name -> StoplistMatch
+	24, // 6: AddAllowlistEntryRequest.entry:type_name -> AllowlistEntry
+	24, // 7: AddAllowlistEntryReply.entry:type_name -> AllowlistEntry
+	24, // 8: ListAllowlistReply.entries:type_name -> AllowlistEntry
+	1,  // 9: TorrentStore.Push:input_type -> PushRequest
+	2,  // 10: TorrentStore.Pull:input_type -> PullRequest
+	7,  // 11: TorrentStore.Touch:input_type -> TouchRequest
+	8,  // 12: TorrentStore.Files:input_type -> FilesRequest
+	4,  // 13: TorrentStore.Check:input_type -> CheckRequest
+	11, // 14: TorrentStore.Delete:input_type -> DeleteRequest
+	14, // 15: TorrentStore.BatchPull:input_type -> BatchPullRequest
+	17, // 16: TorrentStore.BatchFiles:input_type -> BatchFilesRequest
+	2,  // 17: TorrentStore.PullStream:input_type -> PullRequest
+	20, // 18: TorrentStore.PushStream:input_type -> TorrentChunk
+	21, // 19: TorrentStore.ExplainStoplist:input_type -> ExplainStoplistRequest
+	25, // 20: TorrentStore.AddAllowlistEntry:input_type -> AddAllowlistEntryRequest
+	27, // 21: TorrentStore.RemoveAllowlistEntry:input_type -> RemoveAllowlistEntryRequest
+	29, // 22: TorrentStore.ListAllowlist:input_type -> ListAllowlistRequest
+	0,  // 23: TorrentStore.Push:output_type -> PushReply
+	3,  // 24: TorrentStore.Pull:output_type -> PullReply
+	6,  // 25: TorrentStore.Touch:output_type -> TouchReply
+	10, // 26: TorrentStore.Files:output_type -> FilesReply
+	5,  // 27: TorrentStore.Check:output_type -> CheckReply
+	13, // 28: TorrentStore.Delete:output_type -> DeleteReply
+	16, // 29: TorrentStore.BatchPull:output_type -> BatchPullReply
+	19, // 30: TorrentStore.BatchFiles:output_type -> BatchFilesReply
+	20, // 31: TorrentStore.PullStream:output_type -> TorrentChunk
+	0,  // 32: TorrentStore.PushStream:output_type -> PushReply
+	23, // 33: TorrentStore.ExplainStoplist:output_type -> ExplainStoplistReply
+	26, // 34: TorrentStore.AddAllowlistEntry:output_type -> AddAllowlistEntryReply
+	28, // 35: TorrentStore.RemoveAllowlistEntry:output_type -> RemoveAllowlistEntryReply
+	30, // 36: TorrentStore.ListAllowlist:output_type -> ListAllowlistReply
+	23, // [23:37] is the sub-list for method output_type
+	9,  // [9:23] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_proto_torrent_store_proto_init() }
@@ -643,7 +1856,7 @@ func file_proto_torrent_store_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_torrent_store_proto_rawDesc), len(file_proto_torrent_store_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   11,
+			NumMessages:   31,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,10 +19,20 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	TorrentStore_Push_FullMethodName  = "/TorrentStore/Push"
-	TorrentStore_Pull_FullMethodName  = "/TorrentStore/Pull"
-	TorrentStore_Touch_FullMethodName = "/TorrentStore/Touch"
-	TorrentStore_Files_FullMethodName = "/TorrentStore/Files"
+	TorrentStore_Push_FullMethodName                 = "/TorrentStore/Push"
+	TorrentStore_Pull_FullMethodName                 = "/TorrentStore/Pull"
+	TorrentStore_Touch_FullMethodName                = "/TorrentStore/Touch"
+	TorrentStore_Files_FullMethodName                = "/TorrentStore/Files"
+	TorrentStore_Check_FullMethodName                = "/TorrentStore/Check"
+	TorrentStore_Delete_FullMethodName               = "/TorrentStore/Delete"
+	TorrentStore_BatchPull_FullMethodName            = "/TorrentStore/BatchPull"
+	TorrentStore_BatchFiles_FullMethodName           = "/TorrentStore/BatchFiles"
+	TorrentStore_PullStream_FullMethodName           = "/TorrentStore/PullStream"
+	TorrentStore_PushStream_FullMethodName           = "/TorrentStore/PushStream"
+	TorrentStore_ExplainStoplist_FullMethodName      = "/TorrentStore/ExplainStoplist"
+	TorrentStore_AddAllowlistEntry_FullMethodName    = "/TorrentStore/AddAllowlistEntry"
+	TorrentStore_RemoveAllowlistEntry_FullMethodName = "/TorrentStore/RemoveAllowlistEntry"
+	TorrentStore_ListAllowlist_FullMethodName        = "/TorrentStore/ListAllowlist"
 )
 
 // TorrentStoreClient is the client API for TorrentStore service.
@@ -40,6 +50,41 @@ type TorrentStoreClient interface {
 	// cached in the multi-level store, so listing avoids transferring and
 	// parsing the full .torrent on every request.
 	Files(ctx context.Context, in *FilesRequest, opts ...grpc.CallOption) (*FilesReply, error)
+	// Check reports whether a torrent is present in the store without
+	// transferring it. Each tier is probed with a cheap existence check
+	// (key lookup / EXISTS / HEAD) instead of a full Pull.
+	Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckReply, error)
+	// Delete purges a torrent and its derived manifest from every tier
+	// (e.g. on takedown) and reports the outcome per tier.
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteReply, error)
+	// BatchPull pulls many torrents at once. Every item is gated and resolved
+	// independently, so one missing or restricted torrent doesn't fail the
+	// whole batch; per-item outcomes are reported with gRPC status codes.
+	BatchPull(ctx context.Context, in *BatchPullRequest, opts ...grpc.CallOption) (*BatchPullReply, error)
+	// BatchFiles is the batched counterpart of Files with the same per-item
+	// semantics as BatchPull.
+	BatchFiles(ctx context.Context, in *BatchFilesRequest, opts ...grpc.CallOption) (*BatchFilesReply, error)
+	// PullStream pulls torrent from the store as a stream of chunks, so
+	// torrents larger than the max gRPC message size can be transferred
+	// without either side holding a single giant message.
+	PullStream(ctx context.Context, in *PullRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TorrentChunk], error)
+	// PushStream pushes torrent to the store as a stream of chunks. The
+	// server reassembles them and applies the same checks as Push.
+	PushStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TorrentChunk, PushReply], error)
+	// ExplainStoplist is an admin call for moderators. It reports every data
+	// string of a torrent (given as is or by the infoHash of a stored one)
+	// that matches the stoplist, instead of stopping at the first match the
+	// way the Pull gate does. Nothing is blocked or counted.
+	ExplainStoplist(ctx context.Context, in *ExplainStoplistRequest, opts ...grpc.CallOption) (*ExplainStoplistReply, error)
+	// AddAllowlistEntry is an admin call letting a known stoplist false
+	// positive through, by infoHash or by a torrent name pattern. The abuse
+	// gate still applies to allowlisted torrents.
+	AddAllowlistEntry(ctx context.Context, in *AddAllowlistEntryRequest, opts ...grpc.CallOption) (*AddAllowlistEntryReply, error)
+	// RemoveAllowlistEntry is an admin call removing an allowlist entry.
+	RemoveAllowlistEntry(ctx context.Context, in *RemoveAllowlistEntryRequest, opts ...grpc.CallOption) (*RemoveAllowlistEntryReply, error)
+	// ListAllowlist is an admin call listing the allowlist with its audit
+	// metadata.
+	ListAllowlist(ctx context.Context, in *ListAllowlistRequest, opts ...grpc.CallOption) (*ListAllowlistReply, error)
 }
 
 type torrentStoreClient struct {
@@ -90,6 +135,118 @@ func (c *torrentStoreClient) Files(ctx context.Context, in *FilesRequest, opts .
 	return out, nil
 }
 
+func (c *torrentStoreClient) Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckReply)
+	err := c.cc.Invoke(ctx, TorrentStore_Check_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *torrentStoreClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteReply)
+	err := c.cc.Invoke(ctx, TorrentStore_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *torrentStoreClient) BatchPull(ctx context.Context, in *BatchPullRequest, opts ...grpc.CallOption) (*BatchPullReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchPullReply)
+	err := c.cc.Invoke(ctx, TorrentStore_BatchPull_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *torrentStoreClient) BatchFiles(ctx context.Context, in *BatchFilesRequest, opts ...grpc.CallOption) (*BatchFilesReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchFilesReply)
+	err := c.cc.Invoke(ctx, TorrentStore_BatchFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *torrentStoreClient) PullStream(ctx context.Context, in *PullRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TorrentChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TorrentStore_ServiceDesc.Streams[0], TorrentStore_PullStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PullRequest, TorrentChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TorrentStore_PullStreamClient = grpc.ServerStreamingClient[TorrentChunk]
+
+func (c *torrentStoreClient) PushStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TorrentChunk, PushReply], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TorrentStore_ServiceDesc.Streams[1], TorrentStore_PushStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TorrentChunk, PushReply]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TorrentStore_PushStreamClient = grpc.ClientStreamingClient[TorrentChunk, PushReply]
+
+func (c *torrentStoreClient) ExplainStoplist(ctx context.Context, in *ExplainStoplistRequest, opts ...grpc.CallOption) (*ExplainStoplistReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExplainStoplistReply)
+	err := c.cc.Invoke(ctx, TorrentStore_ExplainStoplist_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *torrentStoreClient) AddAllowlistEntry(ctx context.Context, in *AddAllowlistEntryRequest, opts ...grpc.CallOption) (*AddAllowlistEntryReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddAllowlistEntryReply)
+	err := c.cc.Invoke(ctx, TorrentStore_AddAllowlistEntry_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *torrentStoreClient) RemoveAllowlistEntry(ctx context.Context, in *RemoveAllowlistEntryRequest, opts ...grpc.CallOption) (*RemoveAllowlistEntryReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveAllowlistEntryReply)
+	err := c.cc.Invoke(ctx, TorrentStore_RemoveAllowlistEntry_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *torrentStoreClient) ListAllowlist(ctx context.Context, in *ListAllowlistRequest, opts ...grpc.CallOption) (*ListAllowlistReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAllowlistReply)
+	err := c.cc.Invoke(ctx, TorrentStore_ListAllowlist_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TorrentStoreServer is the server API for TorrentStore service.
 // All implementations must embed UnimplementedTorrentStoreServer
 // for forward compatibility.
@@ -105,6 +262,41 @@ type TorrentStoreServer interface {
 	// cached in the multi-level store, so listing avoids transferring and
 	// parsing the full .torrent on every request.
 	Files(context.Context, *FilesRequest) (*FilesReply, error)
+	// Check reports whether a torrent is present in the store without
+	// transferring it. Each tier is probed with a cheap existence check
+	// (key lookup / EXISTS / HEAD) instead of a full Pull.
+	Check(context.Context, *CheckRequest) (*CheckReply, error)
+	// Delete purges a torrent and its derived manifest from every tier
+	// (e.g. on takedown) and reports the outcome per tier.
+	Delete(context.Context, *DeleteRequest) (*DeleteReply, error)
+	// BatchPull pulls many torrents at once. Every item is gated and resolved
+	// independently, so one missing or restricted torrent doesn't fail the
+	// whole batch; per-item outcomes are reported with gRPC status codes.
+	BatchPull(context.Context, *BatchPullRequest) (*BatchPullReply, error)
+	// BatchFiles is the batched counterpart of Files with the same per-item
+	// semantics as BatchPull.
+	BatchFiles(context.Context, *BatchFilesRequest) (*BatchFilesReply, error)
+	// PullStream pulls torrent from the store as a stream of chunks, so
+	// torrents larger than the max gRPC message size can be transferred
+	// without either side holding a single giant message.
+	PullStream(*PullRequest, grpc.ServerStreamingServer[TorrentChunk]) error
+	// PushStream pushes torrent to the store as a stream of chunks. The
+	// server reassembles them and applies the same checks as Push.
+	PushStream(grpc.ClientStreamingServer[TorrentChunk, PushReply]) error
+	// ExplainStoplist is an admin call for moderators. It reports every data
+	// string of a torrent (given as is or by the infoHash of a stored one)
+	// that matches the stoplist, instead of stopping at the first match the
+	// way the Pull gate does. Nothing is blocked or counted.
+	ExplainStoplist(context.Context, *ExplainStoplistRequest) (*ExplainStoplistReply, error)
+	// AddAllowlistEntry is an admin call letting a known stoplist false
+	// positive through, by infoHash or by a torrent name pattern. The abuse
+	// gate still applies to allowlisted torrents.
+	AddAllowlistEntry(context.Context, *AddAllowlistEntryRequest) (*AddAllowlistEntryReply, error)
+	// RemoveAllowlistEntry is an admin call removing an allowlist entry.
+	RemoveAllowlistEntry(context.Context, *RemoveAllowlistEntryRequest) (*RemoveAllowlistEntryReply, error)
+	// ListAllowlist is an admin call listing the allowlist with its audit
+	// metadata.
+	ListAllowlist(context.Context, *ListAllowlistRequest) (*ListAllowlistReply, error)
 	mustEmbedUnimplementedTorrentStoreServer()
 }
 
@@ -127,6 +319,36 @@ func (UnimplementedTorrentStoreServer) Touch(context.Context, *TouchRequest) (*T
 func (UnimplementedTorrentStoreServer) Files(context.Context, *FilesRequest) (*FilesReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Files not implemented")
 }
+func (UnimplementedTorrentStoreServer) Check(context.Context, *CheckRequest) (*CheckReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Check not implemented")
+}
+func (UnimplementedTorrentStoreServer) Delete(context.Context, *DeleteRequest) (*DeleteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedTorrentStoreServer) BatchPull(context.Context, *BatchPullRequest) (*BatchPullReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchPull not implemented")
+}
+func (UnimplementedTorrentStoreServer) BatchFiles(context.Context, *BatchFilesRequest) (*BatchFilesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchFiles not implemented")
+}
+func (UnimplementedTorrentStoreServer) PullStream(*PullRequest, grpc.ServerStreamingServer[TorrentChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method PullStream not implemented")
+}
+func (UnimplementedTorrentStoreServer) PushStream(grpc.ClientStreamingServer[TorrentChunk, PushReply]) error {
+	return status.Errorf(codes.Unimplemented, "method PushStream not implemented")
+}
+func (UnimplementedTorrentStoreServer) ExplainStoplist(context.Context, *ExplainStoplistRequest) (*ExplainStoplistReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExplainStoplist not implemented")
+}
+func (UnimplementedTorrentStoreServer) AddAllowlistEntry(context.Context, *AddAllowlistEntryRequest) (*AddAllowlistEntryReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddAllowlistEntry not implemented")
+}
+func (UnimplementedTorrentStoreServer) RemoveAllowlistEntry(context.Context, *RemoveAllowlistEntryRequest) (*RemoveAllowlistEntryReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveAllowlistEntry not implemented")
+}
+func (UnimplementedTorrentStoreServer) ListAllowlist(context.Context, *ListAllowlistRequest) (*ListAllowlistReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAllowlist not implemented")
+}
 func (UnimplementedTorrentStoreServer) mustEmbedUnimplementedTorrentStoreServer() {}
 func (UnimplementedTorrentStoreServer) testEmbeddedByValue()                      {}
 
@@ -220,6 +442,168 @@ func _TorrentStore_Files_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TorrentStore_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TorrentStoreServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TorrentStore_Check_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TorrentStoreServer).Check(ctx, req.(*CheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TorrentStore_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TorrentStoreServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TorrentStore_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TorrentStoreServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TorrentStore_BatchPull_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchPullRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TorrentStoreServer).BatchPull(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TorrentStore_BatchPull_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TorrentStoreServer).BatchPull(ctx, req.(*BatchPullRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TorrentStore_BatchFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TorrentStoreServer).BatchFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TorrentStore_BatchFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TorrentStoreServer).BatchFiles(ctx, req.(*BatchFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TorrentStore_PullStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PullRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TorrentStoreServer).PullStream(m, &grpc.GenericServerStream[PullRequest, TorrentChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TorrentStore_PullStreamServer = grpc.ServerStreamingServer[TorrentChunk]
+
+func _TorrentStore_PushStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TorrentStoreServer).PushStream(&grpc.GenericServerStream[TorrentChunk, PushReply]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TorrentStore_PushStreamServer = grpc.ClientStreamingServer[TorrentChunk, PushReply]
+
+func _TorrentStore_ExplainStoplist_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainStoplistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TorrentStoreServer).ExplainStoplist(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TorrentStore_ExplainStoplist_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TorrentStoreServer).ExplainStoplist(ctx, req.(*ExplainStoplistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TorrentStore_AddAllowlistEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddAllowlistEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TorrentStoreServer).AddAllowlistEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TorrentStore_AddAllowlistEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TorrentStoreServer).AddAllowlistEntry(ctx, req.(*AddAllowlistEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TorrentStore_RemoveAllowlistEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveAllowlistEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TorrentStoreServer).RemoveAllowlistEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TorrentStore_RemoveAllowlistEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TorrentStoreServer).RemoveAllowlistEntry(ctx, req.(*RemoveAllowlistEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TorrentStore_ListAllowlist_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAllowlistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TorrentStoreServer).ListAllowlist(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TorrentStore_ListAllowlist_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TorrentStoreServer).ListAllowlist(ctx, req.(*ListAllowlistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // TorrentStore_ServiceDesc is the grpc.ServiceDesc for TorrentStore service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -243,7 +627,50 @@ var TorrentStore_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Files",
 			Handler:    _TorrentStore_Files_Handler,
 		},
+		{
+			MethodName: "Check",
+			Handler:    _TorrentStore_Check_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _TorrentStore_Delete_Handler,
+		},
+		{
+			MethodName: "BatchPull",
+			Handler:    _TorrentStore_BatchPull_Handler,
+		},
+		{
+			MethodName: "BatchFiles",
+			Handler:    _TorrentStore_BatchFiles_Handler,
+		},
+		{
+			MethodName: "ExplainStoplist",
+			Handler:    _TorrentStore_ExplainStoplist_Handler,
+		},
+		{
+			MethodName: "AddAllowlistEntry",
+			Handler:    _TorrentStore_AddAllowlistEntry_Handler,
+		},
+		{
+			MethodName: "RemoveAllowlistEntry",
+			Handler:    _TorrentStore_RemoveAllowlistEntry_Handler,
+		},
+		{
+			MethodName: "ListAllowlist",
+			Handler:    _TorrentStore_ListAllowlist_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PullStream",
+			Handler:       _TorrentStore_PullStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PushStream",
+			Handler:       _TorrentStore_PushStream_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/torrent-store.proto",
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	cs "github.com/webtor-io/common-services"
+	s "github.com/webtor-io/torrent-store/services"
+	p "github.com/webtor-io/torrent-store/services/providers"
+)
+
+const (
+	reencryptConcurrencyFlag = "concurrency"
+)
+
+func makeReencryptCMD() cli.Command {
+	reencryptCmd := cli.Command{
+		Name:   "reencrypt",
+		Usage:  "Rewrites every value of the encrypted tiers under the active key",
+		Action: reencrypt,
+	}
+	configureReencrypt(&reencryptCmd)
+	return reencryptCmd
+}
+
+func configureReencrypt(c *cli.Command) {
+	c.Flags = cs.RegisterS3ClientFlags(c.Flags)
+	c.Flags = cs.RegisterRedisClientFlags(c.Flags)
+	c.Flags = p.RegisterRedisFlags(c.Flags)
+	c.Flags = p.RegisterS3Flags(c.Flags)
+	c.Flags = p.RegisterEncryptFlags(c.Flags)
+	c.Flags = append(c.Flags,
+		cli.IntFlag{
+			Name:  reencryptConcurrencyFlag,
+			Usage: "values rewritten concurrently",
+			Value: 8,
+		},
+	)
+}
+
+func reencrypt(c *cli.Context) (err error) {
+	encryptor, err := p.NewEncryptor(c)
+	if err != nil {
+		return
+	}
+	if encryptor == nil {
+		return errors.Errorf("no encrypted tiers, set --%v", p.EncryptFlag)
+	}
+
+	// Setting Redis Client
+	redisCl := cs.NewRedisClient(c)
+	defer redisCl.Close()
+
+	// Setting S3 Client
+	s3Cl := cs.NewS3Client(c, &http.Client{Timeout: time.Minute})
+
+	for _, name := range encryptor.Tiers() {
+		var provider s.StoreProvider
+		switch name {
+		case p.RedisName:
+			provider = p.NewRedis(c, redisCl)
+		case p.S3Name:
+			if provider, err = p.NewS3(c, s3Cl); err != nil {
+				return
+			}
+		}
+		err = rotateTier(context.Background(), encryptor.Wrap(provider).(*p.Encrypted), c.Int(reencryptConcurrencyFlag))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// rotateTier lists every infoHash of the tier and rotates it with bounded
+// parallelism. A single failing value is logged and counted, not fatal.
+func rotateTier(ctx context.Context, e *p.Encrypted, concurrency int) error {
+	t := time.Now()
+	tLog := log.WithField("provider", e.Name())
+	tLog.Info("re-encrypting provider")
+	var done, rotated atomic.Int64
+	st, err := s.ForEachStoredKey(ctx, e, concurrency, func(ctx context.Context, h string) error {
+		if n := done.Add(1); n%1000 == 0 {
+			tLog.WithField("scanned", n).WithField("rotated", rotated.Load()).Info("re-encrypt progress")
+		}
+		ok, err := e.Rotate(ctx, h)
+		if err != nil {
+			tLog.WithField("infohash", h).WithError(err).Warn("failed to re-encrypt")
+			return err
+		}
+		if ok {
+			rotated.Add(1)
+		}
+		return nil
+	})
+	tLog = tLog.WithField("scanned", st.Scanned).
+		WithField("rotated", rotated.Load()).
+		WithField("failed", st.Failed).
+		WithField("duration", time.Since(t))
+	if err != nil {
+		tLog.WithError(err).Error("failed to list provider")
+		return errors.Wrapf(err, "failed to list provider %v", e.Name())
+	}
+	if st.Failed > 0 {
+		tLog.Error("provider partially re-encrypted")
+		return errors.Errorf("failed to re-encrypt %v values of provider %v", st.Failed, e.Name())
+	}
+	tLog.Info("provider re-encrypted")
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	cs "github.com/webtor-io/common-services"
+	s "github.com/webtor-io/torrent-store/services"
+	p "github.com/webtor-io/torrent-store/services/providers"
+)
+
+const (
+	rescanSourceFlag      = "source"
+	rescanPurgeFlag       = "purge"
+	rescanPurgeTiersFlag  = "purge-tiers"
+	rescanOutputFlag      = "output"
+	rescanConcurrencyFlag = "concurrency"
+)
+
+func makeRescanCMD() cli.Command {
+	rescanCmd := cli.Command{
+		Name:   "rescan",
+		Usage:  "Checks every stored torrent against the stoplist",
+		Action: rescan,
+	}
+	configureRescan(&rescanCmd)
+	return rescanCmd
+}
+
+func configureRescan(c *cli.Command) {
+	c.Flags = cs.RegisterS3ClientFlags(c.Flags)
+	c.Flags = cs.RegisterRedisClientFlags(c.Flags)
+	c.Flags = p.RegisterRedisFlags(c.Flags)
+	c.Flags = p.RegisterS3Flags(c.Flags)
+	c.Flags = p.RegisterCompressFlags(c.Flags)
+	c.Flags = p.RegisterEncryptFlags(c.Flags)
+	c.Flags = s.RegisterStoplistFlags(c.Flags)
+	c.Flags = s.RegisterAllowlistFlags(c.Flags)
+	c.Flags = append(c.Flags,
+		cli.StringFlag{
+			Name:  rescanSourceFlag,
+			Usage: "tier to scan (redis, s3)",
+			Value: p.S3Name,
+		},
+		cli.BoolFlag{
+			Name:  rescanPurgeFlag,
+			Usage: "delete matching torrents, manifests and verdicts",
+		},
+		cli.StringFlag{
+			Name:  rescanPurgeTiersFlag,
+			Usage: "comma-separated tiers to purge matches from (redis, s3)",
+			Value: p.RedisName + "," + p.S3Name,
+		},
+		cli.StringFlag{
+			Name:  rescanOutputFlag,
+			Usage: "file to write matches to as JSON lines, - for stdout",
+			Value: "-",
+		},
+		cli.IntFlag{
+			Name:  rescanConcurrencyFlag,
+			Usage: "torrents checked concurrently",
+			Value: 8,
+		},
+	)
+}
+
+// rescan runs against live tiers. Pod-local tiers (memory, badger) can't be
+// reached from here and keep their copies until they expire, but the servers
+// re-check a cached manifest against the stoplist before serving it.
+func rescan(c *cli.Context) (err error) {
+	stoplist, err := s.NewStoplist(c)
+	if err != nil {
+		return
+	}
+	if stoplist == nil {
+		return errors.Errorf("no stoplist, set --%v", s.StoplistPathFlag)
+	}
+	defer stoplist.Close()
+
+	encryptor, err := p.NewEncryptor(c)
+	if err != nil {
+		return
+	}
+	compressor, err := p.NewCompressor(c)
+	if err != nil {
+		return
+	}
+	defer compressor.Close()
+
+	// Setting Redis Client
+	redisCl := cs.NewRedisClient(c)
+	defer redisCl.Close()
+
+	// Setting S3 Client
+	s3Cl := cs.NewS3Client(c, &http.Client{Timeout: time.Minute})
+
+	newTier := func(name string) (s.StoreProvider, error) {
+		switch name {
+		case p.RedisName:
+			return p.NewRedis(c, redisCl), nil
+		case p.S3Name:
+			return p.NewS3(c, s3Cl)
+		default:
+			return nil, errors.Errorf("provider %q can't be rescanned", name)
+		}
+	}
+
+	// Listing goes to the bare tier: the wrappers only transform values.
+	src, err := newTier(strings.ToLower(strings.TrimSpace(c.String(rescanSourceFlag))))
+	if err != nil {
+		return
+	}
+	l, ok := src.(s.Lister)
+	if !ok {
+		return errors.Errorf("provider %v can't list", src.Name())
+	}
+
+	var purge *s.Store
+	if c.Bool(rescanPurgeFlag) {
+		var tiers []s.StoreProvider
+		for _, part := range strings.Split(c.String(rescanPurgeTiersFlag), ",") {
+			name := strings.ToLower(strings.TrimSpace(part))
+			if name == "" {
+				continue
+			}
+			t, err := newTier(name)
+			if err != nil {
+				return err
+			}
+			tiers = append(tiers, compressor.Wrap(encryptor.Wrap(t)))
+		}
+		purge = s.NewStore(tiers, nil)
+		defer purge.Close()
+	}
+
+	allowlist, err := s.NewAllowlist(c, redisCl)
+	if err != nil {
+		return
+	}
+	defer allowlist.Close()
+
+	var out io.Writer = os.Stdout
+	if path := c.String(rescanOutputFlag); path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create %v", path)
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+
+	r := s.NewRescan(compressor.Wrap(encryptor.Wrap(src)), l, stoplist, allowlist, purge, c.Int(rescanConcurrencyFlag))
+	st, err := r.Run(context.Background(), func(m *s.RescanMatch) error {
+		return enc.Encode(m)
+	})
+	if err != nil {
+		return
+	}
+	if st.Failed > 0 {
+		return errors.Errorf("failed to rescan %v torrents", st.Failed)
+	}
+	return
+}
@@ -30,12 +30,21 @@ func configureServe(c *cli.Command) {
 	c.Flags = cs.RegisterRedisClientFlags(c.Flags)
 	c.Flags = cs.RegisterPprofFlags(c.Flags)
 	c.Flags = s.RegisterGRPCFlags(c.Flags)
+	c.Flags = p.RegisterProvidersFlags(c.Flags)
+	c.Flags = p.RegisterMemoryFlags(c.Flags)
 	c.Flags = p.RegisterBadgerFlags(c.Flags)
 	c.Flags = p.RegisterRedisFlags(c.Flags)
+	c.Flags = p.RegisterFSFlags(c.Flags)
 	c.Flags = p.RegisterS3Flags(c.Flags)
+	c.Flags = p.RegisterCompressFlags(c.Flags)
+	c.Flags = p.RegisterEncryptFlags(c.Flags)
 	c.Flags = s.RegisterAbuseClientFlags(c.Flags)
 	c.Flags = s.RegisterAbuseFlags(c.Flags)
 	c.Flags = s.RegisterStoplistFlags(c.Flags)
+	c.Flags = s.RegisterAllowlistFlags(c.Flags)
+	c.Flags = s.RegisterTrackerBlocklistFlags(c.Flags)
+	c.Flags = s.RegisterStoreFlags(c.Flags)
+	c.Flags = s.RegisterRateLimitFlags(c.Flags)
 	c.Flags = s.RegisterServerFlags(c.Flags)
 }
 
@@ -44,6 +53,14 @@ func serve(c *cli.Context) (err error) {
 	if err != nil {
 		return
 	}
+	defer stoplist.Close()
+	extraStoplists, err := s.NewExtraStoplists(c)
+	if err != nil {
+		return
+	}
+	for _, l := range extraStoplists {
+		defer l.Close()
+	}
 
 	var servers []cs.Servable
 
@@ -68,26 +85,6 @@ func serve(c *cli.Context) (err error) {
 		defer pprof.Close()
 	}
 
-	var providers []s.StoreProvider
-
-	// Setting Badger Provider
-	badger, err := p.NewBadger(c)
-	if err != nil {
-		return
-	}
-	defer badger.Close()
-	providers = append(providers, badger)
-
-	// Setting Redis Client
-	redisCl := cs.NewRedisClient(c)
-	defer redisCl.Close()
-
-	// Setting Redis Provider
-	redis := p.NewRedis(c, redisCl)
-	if redis != nil {
-		providers = append(providers, redis)
-	}
-
 	// Setting HTTP Client
 	myTransport := &http.Transport{
 		MaxIdleConns:        500,
@@ -104,17 +101,70 @@ func serve(c *cli.Context) (err error) {
 		Transport: myTransport,
 	}
 
+	// Setting Redis Client
+	redisCl := cs.NewRedisClient(c)
+	defer redisCl.Close()
+
 	// Setting S3 Client
 	s3Cl := cs.NewS3Client(c, cl)
 
-	// Setting S3 Provider
-	s3 := p.NewS3(c, s3Cl)
-	if s3 != nil {
-		providers = append(providers, s3)
+	// Setting Providers
+	names, err := p.ParseProviders(c)
+	if err != nil {
+		return
+	}
+	var providers []s.StoreProvider
+	for _, name := range names {
+		switch name {
+		case p.MemoryName:
+			providers = append(providers, p.NewMemory(c))
+		case p.BadgerName:
+			badger, err := p.NewBadger(c)
+			if err != nil {
+				return err
+			}
+			defer badger.Close()
+			providers = append(providers, badger)
+		case p.RedisName:
+			providers = append(providers, p.NewRedis(c, redisCl))
+		case p.FSName:
+			fs, err := p.NewFS(c)
+			if err != nil {
+				return err
+			}
+			defer fs.Close()
+			providers = append(providers, fs)
+		case p.S3Name:
+			s3, err := p.NewS3(c, s3Cl)
+			if err != nil {
+				return err
+			}
+			providers = append(providers, s3)
+		}
+	}
+
+	// Setting Encryption and Compression (compress first, then encrypt:
+	// ciphertext doesn't compress)
+	encryptor, err := p.NewEncryptor(c)
+	if err != nil {
+		return
+	}
+	compressor, err := p.NewCompressor(c)
+	if err != nil {
+		return
+	}
+	defer compressor.Close()
+	for i := range providers {
+		providers[i] = compressor.Wrap(encryptor.Wrap(providers[i]))
 	}
 
 	// Setting Store
-	store := s.NewStore(providers)
+	storeCfg, err := s.NewStoreConfig(c, redisCl)
+	if err != nil {
+		return
+	}
+	store := s.NewStore(providers, storeCfg)
+	defer store.Close()
 
 	// Setting Abuse Client
 	aCl := s.NewAbuseClient(c)
@@ -122,11 +172,36 @@ func serve(c *cli.Context) (err error) {
 	// Setting Abuse
 	abuse := s.NewAbuse(c, aCl)
 
+	// Setting Rate Limit
+	rl, err := s.NewRateLimit(c, redisCl)
+	if err != nil {
+		return
+	}
+
+	// Setting Allowlist
+	allowlist, err := s.NewAllowlist(c, redisCl)
+	if err != nil {
+		return
+	}
+	defer allowlist.Close()
+
+	// Setting Tracker Blocklist
+	trackerBlocklist, err := s.NewTrackerBlocklist(c)
+	if err != nil {
+		return
+	}
+
 	// Setting Server
-	server := s.NewServer(store, abuse, stoplist, s.ParseDefaultTrackers(c))
+	server := s.NewServer(c, store, abuse, stoplist, extraStoplists, trackerBlocklist, allowlist, rl)
+
+	// Setting Admin Auth
+	adminAuth, err := s.NewAdminAuth(c)
+	if err != nil {
+		return
+	}
 
 	// Setting GRPC Server
-	grpcServer := s.NewGRPCServer(c, server)
+	grpcServer := s.NewGRPCServer(c, server, adminAuth)
 	servers = append(servers, grpcServer)
 	defer grpcServer.Close()
 
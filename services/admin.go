@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	AdminTokensFlag = "admin-tokens"
+	// adminAuthHeader carries "Bearer <token>" on admin calls.
+	adminAuthHeader = "authorization"
+)
+
+// adminMethods are the RPCs that purge torrents, bypass or reveal the
+// stoplist. They are served on the public listener like the rest, so each
+// call must carry an admin token.
+var adminMethods = map[string]struct{}{
+	pb.TorrentStore_Delete_FullMethodName:               {},
+	pb.TorrentStore_ExplainStoplist_FullMethodName:      {},
+	pb.TorrentStore_AddAllowlistEntry_FullMethodName:    {},
+	pb.TorrentStore_RemoveAllowlistEntry_FullMethodName: {},
+	pb.TorrentStore_ListAllowlist_FullMethodName:        {},
+}
+
+func RegisterAdminFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.StringFlag{
+			Name:   AdminTokensFlag,
+			Usage:  "admin tokens as name:token, the name is recorded as the author of admin calls, empty disables admin rpcs",
+			EnvVar: "ADMIN_TOKENS",
+		},
+	)
+}
+
+type adminToken struct {
+	name  string
+	token []byte
+}
+
+// AdminAuth authenticates admin RPCs against the --admin-tokens tokens and
+// puts the name of the matching token into the call context, see
+// adminIdentity. Without tokens admin RPCs are refused altogether.
+type AdminAuth struct {
+	tokens []adminToken
+}
+
+func NewAdminAuth(c *cli.Context) (*AdminAuth, error) {
+	tokens, err := parseAdminTokens(c.String(AdminTokensFlag))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse --%v", AdminTokensFlag)
+	}
+	return &AdminAuth{tokens: tokens}, nil
+}
+
+// parseAdminTokens reads "name:token" entries.
+func parseAdminTokens(raw string) ([]adminToken, error) {
+	var tokens []adminToken
+	seen := map[string]struct{}{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, errors.Errorf("malformed entry, want name:token")
+		}
+		name := strings.TrimSpace(kv[0])
+		if _, dup := seen[name]; dup {
+			return nil, errors.Errorf("duplicate name %q", name)
+		}
+		seen[name] = struct{}{}
+		tokens = append(tokens, adminToken{name: name, token: []byte(strings.TrimSpace(kv[1]))})
+	}
+	return tokens, nil
+}
+
+// authenticate returns the name of the token carried by ctx. Every token is
+// compared, in constant time, so timing doesn't tell which one got close.
+func (s *AdminAuth) authenticate(ctx context.Context) (string, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token []byte
+	for _, v := range md.Get(adminAuthHeader) {
+		if t, ok := strings.CutPrefix(v, "Bearer "); ok {
+			token = []byte(strings.TrimSpace(t))
+			break
+		}
+	}
+	if len(token) == 0 {
+		return "", false
+	}
+	name := ""
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(t.token, token) == 1 {
+			name = t.name
+		}
+	}
+	return name, name != ""
+}
+
+// UnaryInterceptor gates the admin RPCs, every other call passes through.
+func (s *AdminAuth) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if _, ok := adminMethods[info.FullMethod]; !ok {
+		return handler(ctx, req)
+	}
+	if len(s.tokens) == 0 {
+		return nil, status.Errorf(codes.PermissionDenied, "admin rpcs are disabled, see --%v", AdminTokensFlag)
+	}
+	name, ok := s.authenticate(ctx)
+	if !ok {
+		log.WithField("method", info.FullMethod).Warn("unauthenticated admin call")
+		return nil, status.Errorf(codes.Unauthenticated, "admin token required")
+	}
+	return handler(withAdminIdentity(ctx, name), req)
+}
+
+type adminIdentityKey struct{}
+
+func withAdminIdentity(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, adminIdentityKey{}, name)
+}
+
+// adminIdentity returns the name of the admin token the call was
+// authenticated with, empty outside of an admin call.
+func adminIdentity(ctx context.Context) string {
+	name, _ := ctx.Value(adminIdentityKey{}).(string)
+	return name
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseAdminTokens(t *testing.T) {
+	tokens, err := parseAdminTokens(" alice:s3cr:et , bob:t0ken ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0].name != "alice" || string(tokens[0].token) != "s3cr:et" || tokens[1].name != "bob" {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+	for _, bad := range []string{"alice", "alice:", ":token", "a:x,a:y"} {
+		if _, err = parseAdminTokens(bad); err == nil {
+			t.Fatalf("%q must be rejected", bad)
+		}
+	}
+}
+
+func TestAdminAuthInterceptor(t *testing.T) {
+	tokens, err := parseAdminTokens("alice:s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := &AdminAuth{tokens: tokens}
+	var author string
+	handler := func(ctx context.Context, _ any) (any, error) {
+		author = adminIdentity(ctx)
+		return nil, nil
+	}
+	call := func(a *AdminAuth, method string, token string) error {
+		ctx := context.Background()
+		if token != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(adminAuthHeader, "Bearer "+token))
+		}
+		author = ""
+		_, err := a.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	if err = call(auth, pb.TorrentStore_Pull_FullMethodName, ""); err != nil {
+		t.Fatalf("err = %v, regular rpcs need no token", err)
+	}
+	if err = call(auth, pb.TorrentStore_Delete_FullMethodName, ""); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("err = %v, want Unauthenticated without a token", err)
+	}
+	if err = call(auth, pb.TorrentStore_AddAllowlistEntry_FullMethodName, "wrong"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("err = %v, want Unauthenticated with a wrong token", err)
+	}
+	if err = call(auth, pb.TorrentStore_AddAllowlistEntry_FullMethodName, "s3cret"); err != nil || author != "alice" {
+		t.Fatalf("author = %q, err = %v", author, err)
+	}
+	if err = call(&AdminAuth{}, pb.TorrentStore_ExplainStoplist_FullMethodName, "s3cret"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied without configured tokens", err)
+	}
+}
@@ -0,0 +1,430 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	cs "github.com/webtor-io/common-services"
+)
+
+const (
+	AllowlistPathFlag            = "allowlist-path"
+	AllowlistRedisFlag           = "allowlist-redis"
+	AllowlistRefreshIntervalFlag = "allowlist-refresh-interval"
+	allowlistRedisKey            = "al"
+)
+
+// Allowlist entry kinds.
+const (
+	AllowlistKindHash = "hash"
+	AllowlistKindName = "name"
+)
+
+var (
+	ErrAllowlistEntryNotFound = errors.New("allowlist: entry not found")
+	ErrInvalidAllowlistEntry  = errors.New("allowlist: invalid entry")
+)
+
+var (
+	allowlistOverridesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_stoplist_allowlisted_total",
+		Help: "Stoplist checks bypassed by the allowlist, labelled by entry kind (hash, name).",
+	}, []string{"kind"})
+)
+
+func RegisterAllowlistFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.StringFlag{
+			Name:   AllowlistPathFlag,
+			Usage:  "stoplist allowlist file path (json)",
+			Value:  "",
+			EnvVar: "ALLOWLIST_PATH",
+		},
+		cli.BoolFlag{
+			Name:   AllowlistRedisFlag,
+			Usage:  "keep the stoplist allowlist in redis so it is shared across replicas",
+			EnvVar: "ALLOWLIST_REDIS",
+		},
+		cli.IntFlag{
+			Name:   AllowlistRefreshIntervalFlag,
+			Usage:  "reread the stoplist allowlist every (sec), 0 disables",
+			Value:  30,
+			EnvVar: "ALLOWLIST_REFRESH_INTERVAL",
+		},
+	)
+}
+
+// AllowlistEntry overrides the stoplist for known false positives: either a
+// single infoHash or a regexp over the torrent name. Author, Reason and
+// Created are the audit trail.
+type AllowlistEntry struct {
+	Kind    string    `json:"kind"`
+	Value   string    `json:"value"`
+	Author  string    `json:"author"`
+	Reason  string    `json:"reason"`
+	Created time.Time `json:"created"`
+}
+
+func (e AllowlistEntry) id() string {
+	return e.Kind + ":" + e.Value
+}
+
+// normalizeAllowlistEntry validates e and brings its value to canonical
+// form, so the same infoHash can't be listed twice in different case.
+func normalizeAllowlistEntry(e AllowlistEntry) (AllowlistEntry, error) {
+	e.Value = strings.TrimSpace(e.Value)
+	switch e.Kind {
+	case AllowlistKindHash:
+		e.Value = strings.ToLower(e.Value)
+		var h metainfo.Hash
+		if err := h.FromHexString(e.Value); err != nil {
+			return e, errors.Wrapf(ErrInvalidAllowlistEntry, "bad infoHash %q: %v", e.Value, err)
+		}
+	case AllowlistKindName:
+		if _, err := compileAllowlistPattern(e.Value); err != nil {
+			return e, errors.Wrapf(ErrInvalidAllowlistEntry, "bad name pattern %q: %v", e.Value, err)
+		}
+	default:
+		return e, errors.Wrapf(ErrInvalidAllowlistEntry, "unknown kind %q (want %v or %v)", e.Kind, AllowlistKindHash, AllowlistKindName)
+	}
+	return e, nil
+}
+
+func compileAllowlistPattern(p string) (*regexp.Regexp, error) {
+	if p == "" {
+		return nil, errors.New("empty pattern")
+	}
+	return regexp.Compile("(?i)" + p)
+}
+
+// AllowlistStore persists allowlist entries.
+type AllowlistStore interface {
+	List(ctx context.Context) ([]AllowlistEntry, error)
+	Put(ctx context.Context, e AllowlistEntry) error
+	// Remove returns ErrAllowlistEntryNotFound if there was no such entry.
+	Remove(ctx context.Context, kind string, value string) error
+}
+
+// FileAllowlistStore keeps entries in a JSON file, rewritten atomically on
+// every change. Meant for a single replica or a file managed outside of the
+// service; use RedisAllowlistStore to share changes across replicas.
+type FileAllowlistStore struct {
+	mux  sync.Mutex
+	path string
+}
+
+func NewFileAllowlistStore(path string) *FileAllowlistStore {
+	return &FileAllowlistStore{path: path}
+}
+
+func (s *FileAllowlistStore) read() ([]AllowlistEntry, error) {
+	raw, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read allowlist %q", s.path)
+	}
+	var entries []AllowlistEntry
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+	if err = json.Unmarshal(raw, &entries); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse allowlist %q", s.path)
+	}
+	return entries, nil
+}
+
+func (s *FileAllowlistStore) write(entries []AllowlistEntry) error {
+	raw, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "failed to write allowlist")
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(append(raw, '\n')); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "failed to write allowlist")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write allowlist")
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *FileAllowlistStore) List(_ context.Context) ([]AllowlistEntry, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.read()
+}
+
+func (s *FileAllowlistStore) Put(_ context.Context, e AllowlistEntry) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range entries {
+		if entries[i].id() == e.id() {
+			entries[i] = e
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, e)
+	}
+	return s.write(entries)
+}
+
+func (s *FileAllowlistStore) Remove(_ context.Context, kind string, value string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	id := AllowlistEntry{Kind: kind, Value: value}.id()
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.id() != id {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(entries) {
+		return ErrAllowlistEntryNotFound
+	}
+	return s.write(kept)
+}
+
+// RedisAllowlistStore keeps entries as JSON in a single Redis hash keyed by
+// kind and value, namespaced by the Redis key prefix.
+type RedisAllowlistStore struct {
+	cl  *cs.RedisClient
+	key string
+}
+
+func NewRedisAllowlistStore(cl *cs.RedisClient, prefix string) *RedisAllowlistStore {
+	return &RedisAllowlistStore{cl: cl, key: prefix + allowlistRedisKey}
+}
+
+func (s *RedisAllowlistStore) List(ctx context.Context) ([]AllowlistEntry, error) {
+	res, err := s.cl.Get().HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]AllowlistEntry, 0, len(res))
+	for id, raw := range res {
+		var e AllowlistEntry
+		if err = json.Unmarshal([]byte(raw), &e); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse allowlist entry %q", id)
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+	return entries, nil
+}
+
+func (s *RedisAllowlistStore) Put(ctx context.Context, e AllowlistEntry) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.cl.Get().HSet(ctx, s.key, e.id(), raw).Err()
+}
+
+func (s *RedisAllowlistStore) Remove(ctx context.Context, kind string, value string) error {
+	n, err := s.cl.Get().HDel(ctx, s.key, AllowlistEntry{Kind: kind, Value: value}.id()).Result()
+	if errors.Is(err, redis.Nil) || (err == nil && n == 0) {
+		return ErrAllowlistEntryNotFound
+	}
+	return err
+}
+
+// allowlistSnapshot is the compiled form of the entries, swapped as a whole
+// on refresh.
+type allowlistSnapshot struct {
+	hashes map[string]AllowlistEntry
+	names  []allowlistPattern
+}
+
+type allowlistPattern struct {
+	re *regexp.Regexp
+	e  AllowlistEntry
+}
+
+// Allowlist lets known false positives through the stoplist. Lookups are
+// served from an in-memory snapshot, refreshed after every change made
+// through it and periodically to pick up changes of other replicas.
+type Allowlist struct {
+	st     AllowlistStore
+	snap   atomic.Pointer[allowlistSnapshot]
+	closeC chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewAllowlist(c *cli.Context, cl *cs.RedisClient) (*Allowlist, error) {
+	var st AllowlistStore
+	if c.Bool(AllowlistRedisFlag) {
+		st = NewRedisAllowlistStore(cl, c.String(RedisKeyPrefixFlag))
+	} else if path := c.String(AllowlistPathFlag); path != "" {
+		st = NewFileAllowlistStore(path)
+	} else {
+		return nil, nil
+	}
+	return newAllowlist(st, time.Duration(c.Int(AllowlistRefreshIntervalFlag))*time.Second)
+}
+
+func newAllowlist(st AllowlistStore, interval time.Duration) (*Allowlist, error) {
+	s := &Allowlist{
+		st:     st,
+		closeC: make(chan struct{}),
+	}
+	if err := s.refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		s.wg.Add(1)
+		go s.refreshLoop(interval)
+	}
+	return s, nil
+}
+
+func (s *Allowlist) refreshLoop(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.closeC:
+			return
+		}
+		if err := s.refresh(context.Background()); err != nil {
+			log.WithError(err).Warn("failed to refresh allowlist, keeping previous entries")
+		}
+	}
+}
+
+func (s *Allowlist) refresh(ctx context.Context) error {
+	entries, err := s.st.List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load allowlist")
+	}
+	snap := &allowlistSnapshot{hashes: map[string]AllowlistEntry{}}
+	for _, e := range entries {
+		switch e.Kind {
+		case AllowlistKindHash:
+			snap.hashes[strings.ToLower(e.Value)] = e
+		case AllowlistKindName:
+			re, err := compileAllowlistPattern(e.Value)
+			if err != nil {
+				log.WithError(err).WithField("pattern", e.Value).Warn("skipping bad allowlist pattern")
+				continue
+			}
+			snap.names = append(snap.names, allowlistPattern{re: re, e: e})
+		}
+	}
+	s.snap.Store(snap)
+	return nil
+}
+
+// AllowedHash returns the entry allowlisting h, if any.
+func (s *Allowlist) AllowedHash(h string) (AllowlistEntry, bool) {
+	if s == nil {
+		return AllowlistEntry{}, false
+	}
+	e, ok := s.snap.Load().hashes[strings.ToLower(h)]
+	return e, ok
+}
+
+// AllowedName returns the entry allowlisting the torrent by its name, if
+// any. The torrent is only parsed when name patterns are configured.
+func (s *Allowlist) AllowedName(torrent []byte) (AllowlistEntry, bool) {
+	if s == nil {
+		return AllowlistEntry{}, false
+	}
+	names := s.snap.Load().names
+	if len(names) == 0 {
+		return AllowlistEntry{}, false
+	}
+	mi, err := metainfo.Load(bytes.NewReader(torrent))
+	if err != nil {
+		return AllowlistEntry{}, false
+	}
+	i, err := mi.UnmarshalInfo()
+	if err != nil {
+		return AllowlistEntry{}, false
+	}
+	for _, p := range names {
+		if p.re.MatchString(i.Name) {
+			return p.e, true
+		}
+	}
+	return AllowlistEntry{}, false
+}
+
+func (s *Allowlist) List(ctx context.Context) ([]AllowlistEntry, error) {
+	return s.st.List(ctx)
+}
+
+// Add stores e, replacing an entry of the same kind and value.
+func (s *Allowlist) Add(ctx context.Context, e AllowlistEntry) (AllowlistEntry, error) {
+	e, err := normalizeAllowlistEntry(e)
+	if err != nil {
+		return e, err
+	}
+	if e.Created.IsZero() {
+		e.Created = time.Now().UTC()
+	}
+	if err = s.st.Put(ctx, e); err != nil {
+		return e, errors.Wrap(err, "failed to store allowlist entry")
+	}
+	return e, s.refresh(ctx)
+}
+
+func (s *Allowlist) Remove(ctx context.Context, kind string, value string) error {
+	e, err := normalizeAllowlistEntry(AllowlistEntry{Kind: kind, Value: value})
+	if err != nil {
+		return err
+	}
+	if err = s.st.Remove(ctx, e.Kind, e.Value); err != nil {
+		return err
+	}
+	return s.refresh(ctx)
+}
+
+// Close stops refreshing the allowlist.
+func (s *Allowlist) Close() {
+	if s == nil {
+		return
+	}
+	close(s.closeC)
+	s.wg.Wait()
+}
+
+var _ AllowlistStore = (*FileAllowlistStore)(nil)
+var _ AllowlistStore = (*RedisAllowlistStore)(nil)
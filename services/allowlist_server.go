@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func allowlistEntryToProto(e AllowlistEntry) *pb.AllowlistEntry {
+	return &pb.AllowlistEntry{
+		Kind:    e.Kind,
+		Value:   e.Value,
+		Author:  e.Author,
+		Reason:  e.Reason,
+		Created: e.Created.Unix(),
+	}
+}
+
+func (s *Server) AddAllowlistEntry(ctx context.Context, in *pb.AddAllowlistEntryRequest) (*pb.AddAllowlistEntryReply, error) {
+	t := time.Now()
+	if s.al == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "allowlist is not configured")
+	}
+	author := adminIdentity(ctx)
+	if author == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "admin token required")
+	}
+	pe := in.GetEntry()
+	if pe.GetReason() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "reason is required")
+	}
+	e, err := s.al.Add(ctx, AllowlistEntry{
+		Kind:   pe.GetKind(),
+		Value:  pe.GetValue(),
+		Author: author,
+		Reason: pe.GetReason(),
+	})
+	aLog := log.WithField("kind", e.Kind).
+		WithField("value", e.Value).
+		WithField("author", e.Author).
+		WithField("reason", e.Reason).
+		WithField("method", "add_allowlist_entry")
+	if errors.Is(err, ErrInvalidAllowlistEntry) {
+		aLog.WithField("duration", time.Since(t)).WithError(err).Warn("rejected allowlist entry")
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	} else if err != nil {
+		aLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to add allowlist entry")
+		return nil, errors.Wrap(err, "failed to add allowlist entry")
+	}
+	aLog.WithField("duration", time.Since(t)).Warn("allowlist entry added")
+	return &pb.AddAllowlistEntryReply{Entry: allowlistEntryToProto(e)}, nil
+}
+
+func (s *Server) RemoveAllowlistEntry(ctx context.Context, in *pb.RemoveAllowlistEntryRequest) (*pb.RemoveAllowlistEntryReply, error) {
+	t := time.Now()
+	if s.al == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "allowlist is not configured")
+	}
+	author := adminIdentity(ctx)
+	if author == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "admin token required")
+	}
+	aLog := log.WithField("kind", in.GetKind()).
+		WithField("value", in.GetValue()).
+		WithField("author", author).
+		WithField("method", "remove_allowlist_entry")
+	err := s.al.Remove(ctx, in.GetKind(), in.GetValue())
+	if errors.Is(err, ErrAllowlistEntryNotFound) {
+		aLog.WithField("duration", time.Since(t)).Info("allowlist entry not found")
+		return nil, status.Errorf(codes.NotFound, "unable to find allowlist entry %v:%v", in.GetKind(), in.GetValue())
+	} else if errors.Is(err, ErrInvalidAllowlistEntry) {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	} else if err != nil {
+		aLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to remove allowlist entry")
+		return nil, errors.Wrap(err, "failed to remove allowlist entry")
+	}
+	aLog.WithField("duration", time.Since(t)).Warn("allowlist entry removed")
+	return &pb.RemoveAllowlistEntryReply{}, nil
+}
+
+func (s *Server) ListAllowlist(ctx context.Context, _ *pb.ListAllowlistRequest) (*pb.ListAllowlistReply, error) {
+	if s.al == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "allowlist is not configured")
+	}
+	entries, err := s.al.List(ctx)
+	if err != nil {
+		log.WithError(err).WithField("method", "list_allowlist").Error("failed to list allowlist")
+		return nil, errors.Wrap(err, "failed to list allowlist")
+	}
+	reply := &pb.ListAllowlistReply{}
+	for _, e := range entries {
+		reply.Entries = append(reply.Entries, allowlistEntryToProto(e))
+	}
+	return reply, nil
+}
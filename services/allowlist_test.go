@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const allowlistTestHash = "08ada5a7a6183aae1e09d831df6748d566095a10"
+
+func newTestAllowlist(t *testing.T) (*Allowlist, string) {
+	path := filepath.Join(t.TempDir(), "allowlist.json")
+	al, err := newAllowlist(NewFileAllowlistStore(path), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(al.Close)
+	return al, path
+}
+
+func TestAllowlistFileStore(t *testing.T) {
+	al, path := newTestAllowlist(t)
+	ctx := context.Background()
+
+	e, err := al.Add(ctx, AllowlistEntry{Kind: AllowlistKindHash, Value: " 08ADA5A7A6183AAE1E09D831DF6748D566095A10 ", Author: "mod", Reason: "audiobook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Value != allowlistTestHash || e.Created.IsZero() {
+		t.Fatalf("entry = %+v", e)
+	}
+	if _, ok := al.AllowedHash(allowlistTestHash); !ok {
+		t.Fatal("hash must be allowed")
+	}
+	for _, bad := range []AllowlistEntry{
+		{Kind: AllowlistKindHash, Value: "nothex"},
+		{Kind: AllowlistKindName, Value: "(unclosed"},
+		{Kind: "other", Value: "x"},
+	} {
+		if _, err = al.Add(ctx, bad); !errors.Is(err, ErrInvalidAllowlistEntry) {
+			t.Fatalf("%+v: err = %v, want ErrInvalidAllowlistEntry", bad, err)
+		}
+	}
+
+	// A fresh Allowlist over the same file sees the entry with its audit
+	// metadata.
+	other, err := newAllowlist(NewFileAllowlistStore(path), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if got, ok := other.AllowedHash(allowlistTestHash); !ok || got.Author != "mod" || got.Reason != "audiobook" {
+		t.Fatalf("entry = %+v, %v", got, ok)
+	}
+
+	if err = al.Remove(ctx, AllowlistKindHash, allowlistTestHash); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := al.AllowedHash(allowlistTestHash); ok {
+		t.Fatal("removed hash must not be allowed")
+	}
+	if err = al.Remove(ctx, AllowlistKindHash, allowlistTestHash); !errors.Is(err, ErrAllowlistEntryNotFound) {
+		t.Fatalf("err = %v, want ErrAllowlistEntryNotFound", err)
+	}
+}
+
+func TestCheckStoplistAllowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - apple\n")
+	st, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	al, _ := newTestAllowlist(t)
+	srv := &Server{s: NewStore(nil, nil), sl: st, al: al}
+	ctx := context.Background()
+	torrent := makeMultiFileTorrent(t, "Apple Audiobook", []metainfo.FileInfo{{Path: []string{"a"}, Length: 1}})
+	hLog := log.WithField("infoHash", allowlistTestHash)
+
+	blocks := stoplistBlocksTotal.WithLabelValues("bad", StoplistFieldName)
+	matches := stoplistActionMatchesTotal.WithLabelValues(StoplistMainName, StoplistActionBlock, "bad", StoplistFieldName)
+	blocksBefore, matchesBefore := counterValue(t, blocks), counterValue(t, matches)
+	if _, err = srv.checkStoplist(ctx, torrent, hLog, time.Now(), allowlistTestHash, true); status.Code(err) != codes.PermissionDenied {
+		t.Fatal("expected PermissionDenied before allowlisting")
+	}
+
+	_, err = srv.AddAllowlistEntry(ctx, &pb.AddAllowlistEntryRequest{Entry: &pb.AllowlistEntry{
+		Kind: AllowlistKindName, Value: "audiobook$", Author: "mod", Reason: "false positive",
+	}})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("err = %v, want Unauthenticated outside of an admin call", err)
+	}
+	ctx = withAdminIdentity(ctx, "mod")
+	_, err = srv.AddAllowlistEntry(ctx, &pb.AddAllowlistEntryRequest{Entry: &pb.AllowlistEntry{Kind: AllowlistKindName, Value: "audiobook$"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument without reason", err)
+	}
+	reply, err := srv.AddAllowlistEntry(ctx, &pb.AddAllowlistEntryRequest{Entry: &pb.AllowlistEntry{
+		Kind: AllowlistKindName, Value: "audiobook$", Author: "spoofed", Reason: "false positive",
+	}})
+	if err != nil || reply.GetEntry().GetCreated() == 0 {
+		t.Fatalf("reply = %+v, err = %v", reply, err)
+	}
+	if _, err = srv.checkStoplist(ctx, torrent, hLog, time.Now(), allowlistTestHash, true); err != nil {
+		t.Fatalf("err = %v, want allowed by name", err)
+	}
+	// Both checks matched, only the first one blocked.
+	if got := counterValue(t, blocks) - blocksBefore; got != 1 {
+		t.Fatalf("blocks = %v, want 1", got)
+	}
+	if got := counterValue(t, matches) - matchesBefore; got != 2 {
+		t.Fatalf("matches = %v, want 2", got)
+	}
+
+	list, err := srv.ListAllowlist(ctx, &pb.ListAllowlistRequest{})
+	if err != nil || len(list.GetEntries()) != 1 || list.GetEntries()[0].GetAuthor() != "mod" {
+		t.Fatalf("list = %+v, err = %v", list, err)
+	}
+	_, err = srv.RemoveAllowlistEntry(ctx, &pb.RemoveAllowlistEntryRequest{Kind: AllowlistKindName, Value: "audiobook$", Author: "mod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = srv.checkStoplist(ctx, torrent, hLog, time.Now(), allowlistTestHash, true); status.Code(err) != codes.PermissionDenied {
+		t.Fatal("expected PermissionDenied after removal")
+	}
+	_, err = srv.RemoveAllowlistEntry(ctx, &pb.RemoveAllowlistEntryRequest{Kind: AllowlistKindName, Value: "audiobook$", Author: "mod"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound", err)
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) BatchPull(ctx context.Context, in *pb.BatchPullRequest) (*pb.BatchPullReply, error) {
+	t := time.Now()
+	hashes := in.GetInfoHashes()
+	bLog := log.WithField("items", len(hashes)).WithField("method", "batch_pull")
+	bLog.Info("batch pull request")
+
+	if err := s.checkBatchSize(len(hashes)); err != nil {
+		return nil, err
+	}
+
+	items := make([]*pb.BatchPullItem, len(hashes))
+	s.runBatch(ctx, len(hashes), func(i int) {
+		item := &pb.BatchPullItem{InfoHash: hashes[i]}
+		torrent, err := s.pull(ctx, hashes[i], "batch_pull")
+		item.Code, item.Message = itemStatus(err)
+		if err == nil {
+			item.Torrent = torrent
+		}
+		items[i] = item
+	})
+
+	bLog.WithField("duration", time.Since(t)).Info("sending batch pull response")
+	return &pb.BatchPullReply{Items: items}, nil
+}
+
+func (s *Server) BatchFiles(ctx context.Context, in *pb.BatchFilesRequest) (*pb.BatchFilesReply, error) {
+	t := time.Now()
+	hashes := in.GetInfoHashes()
+	bLog := log.WithField("items", len(hashes)).WithField("method", "batch_files")
+	bLog.Info("batch files request")
+
+	if err := s.checkBatchSize(len(hashes)); err != nil {
+		return nil, err
+	}
+
+	items := make([]*pb.BatchFilesItem, len(hashes))
+	s.runBatch(ctx, len(hashes), func(i int) {
+		item := &pb.BatchFilesItem{InfoHash: hashes[i]}
+		files, err := s.files(ctx, hashes[i], "batch_files")
+		item.Code, item.Message = itemStatus(err)
+		if err == nil {
+			item.Files = files
+		}
+		items[i] = item
+	})
+
+	bLog.WithField("duration", time.Since(t)).Info("sending batch files response")
+	return &pb.BatchFilesReply{Items: items}, nil
+}
+
+func (s *Server) checkBatchSize(n int) error {
+	if s.batchMaxSize > 0 && n > s.batchMaxSize {
+		return status.Errorf(codes.InvalidArgument, "batch of %v items exceeds limit of %v", n, s.batchMaxSize)
+	}
+	return nil
+}
+
+// runBatch calls fn for every index in [0, n) with at most batchConcurrency
+// calls in flight. Items left unstarted when ctx is done are still visited
+// so every slot gets a result; the per-item handlers observe ctx themselves.
+func (s *Server) runBatch(ctx context.Context, n int, fn func(i int)) {
+	concurrency := s.batchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			fn(i)
+			wg.Done()
+			continue
+		}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// itemStatus flattens a per-item error into a gRPC code and message. Errors
+// that already carry a status (NotFound, PermissionDenied) keep it; anything
+// else is reported as Internal, mirroring what a unary call would return.
+func itemStatus(err error) (int32, string) {
+	if err == nil {
+		return int32(codes.OK), ""
+	}
+	if st, ok := status.FromError(err); ok {
+		return int32(st.Code()), st.Message()
+	}
+	if ctxErr := status.FromContextError(err); ctxErr.Code() != codes.Unknown {
+		return int32(ctxErr.Code()), ctxErr.Message()
+	}
+	return int32(codes.Internal), err.Error()
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBatchPullPerItemStatus(t *testing.T) {
+	p := newFakeProvider("fast", true)
+	srv := &Server{s: NewStore([]StoreProvider{p}, nil), batchConcurrency: 2}
+
+	torrent := makeMultiFileTorrent(t, "x", []metainfo.FileInfo{{Path: []string{"a"}, Length: 1}})
+	_, _ = p.Push(context.Background(), "present", torrent)
+
+	reply, err := srv.BatchPull(context.Background(), &pb.BatchPullRequest{
+		InfoHashes: []string{"missing", "present"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := reply.GetItems()
+	if len(items) != 2 {
+		t.Fatalf("items = %d, want 2", len(items))
+	}
+	if items[0].GetInfoHash() != "missing" || codes.Code(items[0].GetCode()) != codes.NotFound {
+		t.Fatalf("item 0 = %+v, want NotFound", items[0])
+	}
+	if codes.Code(items[1].GetCode()) != codes.OK || len(items[1].GetTorrent()) == 0 {
+		t.Fatalf("item 1 = %+v, want OK with torrent", items[1])
+	}
+}
+
+func TestBatchFilesPerItemStatus(t *testing.T) {
+	p := newFakeProvider("fast", true)
+	srv := &Server{s: NewStore([]StoreProvider{p}, nil), batchConcurrency: 2}
+
+	torrent := makeMultiFileTorrent(t, "show", []metainfo.FileInfo{{Path: []string{"e01.mkv"}, Length: 1}})
+	_, _ = p.Push(context.Background(), "present", torrent)
+
+	reply, err := srv.BatchFiles(context.Background(), &pb.BatchFilesRequest{
+		InfoHashes: []string{"present", "missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := reply.GetItems()
+	if codes.Code(items[0].GetCode()) != codes.OK || items[0].GetFiles().GetName() != "show" {
+		t.Fatalf("item 0 = %+v, want OK show", items[0])
+	}
+	if codes.Code(items[1].GetCode()) != codes.NotFound || items[1].GetFiles() != nil {
+		t.Fatalf("item 1 = %+v, want NotFound", items[1])
+	}
+}
+
+func TestBatchRejectsOversizedRequest(t *testing.T) {
+	srv := &Server{s: NewStore(nil, nil), batchMaxSize: 1}
+	_, err := srv.BatchPull(context.Background(), &pb.BatchPullRequest{InfoHashes: []string{"a", "b"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+}
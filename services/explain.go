@@ -0,0 +1,71 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExplainStoplist reports why a torrent is (or isn't) blocked by the
+// stoplist. It skips the abuse gate and the rate limit: it is an admin call
+// and the torrent is never handed out.
+func (s *Server) ExplainStoplist(ctx context.Context, in *pb.ExplainStoplistRequest) (*pb.ExplainStoplistReply, error) {
+	t := time.Now()
+	if s.sl == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "stoplist is not configured")
+	}
+	torrent := in.GetTorrent()
+	infoHash := in.GetInfoHash()
+	if len(torrent) > 0 {
+		mi, err := metainfo.Load(bytes.NewReader(torrent))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to read torrent: %v", err)
+		}
+		infoHash = mi.HashInfoBytes().HexString()
+	} else if infoHash == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "torrent or infoHash is required")
+	}
+	hLog := log.WithField("infoHash", infoHash).WithField("method", "explain_stoplist").WithField("author", adminIdentity(ctx))
+	hLog.Info("explain stoplist request")
+
+	if len(torrent) == 0 {
+		var err error
+		torrent, err = s.s.Pull(ctx, infoHash)
+		if errors.Is(err, ErrNotFound) {
+			hLog.WithField("duration", time.Since(t)).Info("torrent not found")
+			return nil, status.Errorf(codes.NotFound, "unable to find torrent for infoHash=%v", infoHash)
+		} else if err != nil {
+			hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to pull")
+			return nil, errors.Wrapf(err, "failed to pull torrent infoHash=%v", infoHash)
+		}
+	}
+
+	matches, version, err := s.sl.Explain(torrent)
+	if err != nil {
+		hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to explain stoplist")
+		return nil, status.Errorf(codes.InvalidArgument, "failed to explain stoplist infoHash=%v: %v", infoHash, err)
+	}
+	reply := &pb.ExplainStoplistReply{InfoHash: infoHash, Version: version}
+	for _, m := range matches {
+		reply.Matches = append(reply.Matches, &pb.StoplistMatch{
+			Field:      m.Field,
+			Data:       m.Data,
+			Normalized: m.Normalized,
+			Rule:       m.Rule,
+			Sections:   m.Sections,
+			Stack:      m.Stack,
+		})
+	}
+	hLog.WithField("matches", len(matches)).
+		WithField("version", version).
+		WithField("duration", time.Since(t)).
+		Info("sending explain stoplist reply")
+	return reply, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExplainStoplist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\n  - \"{age}+{sexual}\"\nbad:\n  - apple\nage:\n  - teen\nsexual:\n  - kiss\n")
+	st, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	p := newFakeProvider("fast", true)
+	srv := &Server{s: NewStore([]StoreProvider{p}, nil), sl: st}
+	ctx := context.Background()
+
+	torrent := makeMultiFileTorrent(t, "Apple pie", []metainfo.FileInfo{
+		{Path: []string{"clean.txt"}, Length: 1},
+		{Path: []string{"Teen-Kiss.mkv"}, Length: 1},
+		{Path: []string{"apple.jpg"}, Length: 1},
+	})
+	_, _ = p.Push(ctx, "stored", torrent)
+
+	for _, req := range []*pb.ExplainStoplistRequest{{Torrent: torrent}, {InfoHash: "stored"}} {
+		reply, err := srv.ExplainStoplist(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.GetVersion() != st.Version() {
+			t.Fatalf("version = %q, want %q", reply.GetVersion(), st.Version())
+		}
+		m := reply.GetMatches()
+		// Every match is reported, not just the first one.
+		if len(m) != 3 {
+			t.Fatalf("matches = %+v, want 3", m)
+		}
+		if m[0].GetField() != StoplistFieldName || m[0].GetNormalized() != "apple pie" || m[0].GetRule() != "bad" {
+			t.Fatalf("match 0 = %+v", m[0])
+		}
+		if m[1].GetField() != StoplistFieldPath || m[1].GetData() != "Teen-Kiss.mkv" || m[1].GetRule() != "age_sexual" {
+			t.Fatalf("match 1 = %+v", m[1])
+		}
+		if s := m[1].GetSections(); len(s) != 2 || s[0] != "age" || s[1] != "sexual" {
+			t.Fatalf("sections = %v, want [age sexual]", s)
+		}
+		if m[2].GetField() != StoplistFieldPath || len(m[2].GetStack()) == 0 {
+			t.Fatalf("match 2 = %+v", m[2])
+		}
+	}
+
+	_, err = srv.ExplainStoplist(ctx, &pb.ExplainStoplistRequest{InfoHash: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound", err)
+	}
+	_, err = srv.ExplainStoplist(ctx, &pb.ExplainStoplistRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+	_, err = (&Server{}).ExplainStoplist(ctx, &pb.ExplainStoplistRequest{InfoHash: "stored"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("err = %v, want FailedPrecondition", err)
+	}
+}
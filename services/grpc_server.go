@@ -25,13 +25,15 @@ type GRPCServer struct {
 	port int
 	ln   net.Listener
 	s    *Server
+	auth *AdminAuth
 }
 
-func NewGRPCServer(c *cli.Context, s *Server) *GRPCServer {
-	return &GRPCServer{host: c.String(grpcServerHostFlag), port: c.Int(grpcServerPortFlag), s: s}
+func NewGRPCServer(c *cli.Context, s *Server, auth *AdminAuth) *GRPCServer {
+	return &GRPCServer{host: c.String(grpcServerHostFlag), port: c.Int(grpcServerPortFlag), s: s, auth: auth}
 }
 
 func RegisterGRPCFlags(f []cli.Flag) []cli.Flag {
+	f = RegisterAdminFlags(f)
 	return append(f,
 		cli.StringFlag{
 			Name:   grpcServerHostFlag,
@@ -59,6 +61,7 @@ func (s *GRPCServer) Serve() error {
 	gs := grpc.NewServer(
 		grpc.MaxRecvMsgSize(grpcMaxMsgSize),
 		grpc.MaxSendMsgSize(grpcMaxMsgSize),
+		grpc.UnaryInterceptor(s.auth.UnaryInterceptor),
 	)
 
 	pb.RegisterTorrentStoreServer(gs, s.s)
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	ProviderTimeoutsFlag    = "provider-timeouts"
+	ProviderRetriesFlag     = "provider-retries"
+	BreakerThresholdFlag    = "breaker-threshold"
+	BreakerCooldownFlag     = "breaker-cooldown"
+	providerPolicyDefault   = "*"
+	guardRetryBackoffFactor = 50 * time.Millisecond
+)
+
+var (
+	ErrProviderUnavailable = errors.New("store: provider unavailable (circuit open)")
+)
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+var (
+	providerBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "torrent_store_provider_breaker_state",
+		Help: "Circuit breaker state per provider: 0 closed, 1 open, 2 half-open.",
+	}, []string{"provider"})
+	providerFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_provider_failures_total",
+		Help: "Provider calls that failed (after retries), labelled by provider and reason (timeout, error).",
+	}, []string{"provider", "reason"})
+	providerRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_provider_rejected_total",
+		Help: "Provider calls skipped because the circuit breaker was open.",
+	}, []string{"provider"})
+)
+
+func RegisterGuardFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.StringFlag{
+			Name:   ProviderTimeoutsFlag,
+			Usage:  "per-provider call timeouts, e.g. \"redis=300ms,s3=5s,*=2s\" (* is the default, empty disables)",
+			Value:  "",
+			EnvVar: "PROVIDER_TIMEOUTS",
+		},
+		cli.StringFlag{
+			Name:   ProviderRetriesFlag,
+			Usage:  "per-provider retries on failure, e.g. \"s3=2,*=0\"",
+			Value:  "",
+			EnvVar: "PROVIDER_RETRIES",
+		},
+		cli.IntFlag{
+			Name:   BreakerThresholdFlag,
+			Usage:  "consecutive provider failures that open its circuit breaker, 0 disables",
+			Value:  0,
+			EnvVar: "BREAKER_THRESHOLD",
+		},
+		cli.IntFlag{
+			Name:   BreakerCooldownFlag,
+			Usage:  "how long an open circuit breaker skips its provider before a trial call (sec)",
+			Value:  30,
+			EnvVar: "BREAKER_COOLDOWN",
+		},
+	)
+}
+
+// GuardConfig holds the resilience policy applied to every provider.
+type GuardConfig struct {
+	Timeouts         map[string]time.Duration
+	Retries          map[string]int
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+func NewGuardConfig(c *cli.Context) (*GuardConfig, error) {
+	timeouts := map[string]time.Duration{}
+	err := parseProviderPolicy(c.String(ProviderTimeoutsFlag), func(name string, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		timeouts[name] = d
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse --%v", ProviderTimeoutsFlag)
+	}
+	retries := map[string]int{}
+	err = parseProviderPolicy(c.String(ProviderRetriesFlag), func(name string, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		retries[name] = n
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse --%v", ProviderRetriesFlag)
+	}
+	return &GuardConfig{
+		Timeouts:         timeouts,
+		Retries:          retries,
+		BreakerThreshold: c.Int(BreakerThresholdFlag),
+		BreakerCooldown:  time.Duration(c.Int(BreakerCooldownFlag)) * time.Second,
+	}, nil
+}
+
+// parseProviderPolicy splits a "name=value,name=value" list.
+func parseProviderPolicy(raw string, set func(name string, v string) error) error {
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("malformed entry %q, want name=value", part)
+		}
+		if err := set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])); err != nil {
+			return errors.Wrapf(err, "malformed entry %q", part)
+		}
+	}
+	return nil
+}
+
+func (s *GuardConfig) enabled() bool {
+	return len(s.Timeouts) > 0 || len(s.Retries) > 0 || s.BreakerThreshold > 0
+}
+
+func (s *GuardConfig) timeout(name string) time.Duration {
+	if d, ok := s.Timeouts[name]; ok {
+		return d
+	}
+	return s.Timeouts[providerPolicyDefault]
+}
+
+func (s *GuardConfig) retries(name string) int {
+	if n, ok := s.Retries[name]; ok {
+		return n
+	}
+	return s.Retries[providerPolicyDefault]
+}
+
+// guardedProvider decorates a StoreProvider with a per-call timeout, retries
+// and a consecutive-failure circuit breaker. ErrNotFound is a regular answer
+// and never counts as a failure; neither does the caller's own context being
+// cancelled. While the breaker is open calls fail fast with
+// ErrProviderUnavailable so Store walks on to the next tier right away.
+type guardedProvider struct {
+	StoreProvider
+	timeout   time.Duration
+	retries   int
+	threshold int
+	cooldown  time.Duration
+
+	mux      sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newGuardedProvider(p StoreProvider, cfg *GuardConfig) *guardedProvider {
+	g := &guardedProvider{
+		StoreProvider: p,
+		timeout:       cfg.timeout(p.Name()),
+		retries:       cfg.retries(p.Name()),
+		threshold:     cfg.BreakerThreshold,
+		cooldown:      cfg.BreakerCooldown,
+	}
+	providerBreakerState.WithLabelValues(p.Name()).Set(breakerClosed)
+	return g
+}
+
+func (s *guardedProvider) setState(state int) {
+	if s.state != state {
+		log.WithField("provider", s.Name()).WithField("state", state).Warn("provider circuit breaker state changed")
+	}
+	s.state = state
+	providerBreakerState.WithLabelValues(s.Name()).Set(float64(state))
+}
+
+// allow reports whether a call may proceed. After the cooldown a single
+// trial call is let through (half-open); its outcome decides the next state.
+func (s *guardedProvider) allow() bool {
+	if s.threshold <= 0 {
+		return true
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	switch s.state {
+	case breakerOpen:
+		if time.Since(s.openedAt) < s.cooldown {
+			return false
+		}
+		s.setState(breakerHalfOpen)
+		s.trial = true
+		return true
+	case breakerHalfOpen:
+		if s.trial {
+			return false
+		}
+		s.trial = true
+		return true
+	}
+	return true
+}
+
+// release frees the half-open trial slot of a call whose outcome says
+// nothing about the provider, leaving the breaker state as it is.
+func (s *guardedProvider) release() {
+	if s.threshold <= 0 {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.trial = false
+}
+
+func (s *guardedProvider) record(failed bool) {
+	if s.threshold <= 0 {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.trial = false
+	if !failed {
+		s.failures = 0
+		s.setState(breakerClosed)
+		return
+	}
+	s.failures++
+	if s.state == breakerHalfOpen || s.failures >= s.threshold {
+		s.openedAt = time.Now()
+		s.setState(breakerOpen)
+	}
+}
+
+// guardCall runs op under the provider's policy.
+func guardCall[T any](s *guardedProvider, ctx context.Context, withTimeout bool, op func(ctx context.Context) (T, error)) (res T, err error) {
+	if !s.allow() {
+		providerRejectedTotal.WithLabelValues(s.Name()).Inc()
+		return res, ErrProviderUnavailable
+	}
+	for attempt := 0; ; attempt++ {
+		cctx, cancel := ctx, context.CancelFunc(func() {})
+		if withTimeout && s.timeout > 0 {
+			cctx, cancel = context.WithTimeout(ctx, s.timeout)
+		}
+		res, err = op(cctx)
+		cancel()
+		if err == nil || errors.Is(err, ErrNotFound) {
+			s.record(false)
+			return
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider.
+			s.release()
+			return
+		}
+		if attempt >= s.retries {
+			break
+		}
+		time.Sleep(time.Duration(attempt+1) * guardRetryBackoffFactor)
+	}
+	reason := "error"
+	if errors.Is(err, context.DeadlineExceeded) {
+		reason = "timeout"
+	}
+	providerFailuresTotal.WithLabelValues(s.Name(), reason).Inc()
+	s.record(true)
+	return
+}
+
+func (s *guardedProvider) Push(ctx context.Context, h string, torrent []byte) (bool, error) {
+	return guardCall(s, ctx, true, func(ctx context.Context) (bool, error) {
+		return s.StoreProvider.Push(ctx, h, torrent)
+	})
+}
+
+func (s *guardedProvider) Pull(ctx context.Context, h string) ([]byte, error) {
+	return guardCall(s, ctx, true, func(ctx context.Context) ([]byte, error) {
+		return s.StoreProvider.Pull(ctx, h)
+	})
+}
+
+func (s *guardedProvider) Touch(ctx context.Context, h string) (bool, error) {
+	return guardCall(s, ctx, true, func(ctx context.Context) (bool, error) {
+		return s.StoreProvider.Touch(ctx, h)
+	})
+}
+
+func (s *guardedProvider) Exists(ctx context.Context, h string) (bool, error) {
+	return guardCall(s, ctx, true, func(ctx context.Context) (bool, error) {
+		return s.StoreProvider.Exists(ctx, h)
+	})
+}
+
+func (s *guardedProvider) PushManifest(ctx context.Context, h string, manifest []byte) (bool, error) {
+	return guardCall(s, ctx, true, func(ctx context.Context) (bool, error) {
+		return s.StoreProvider.PushManifest(ctx, h, manifest)
+	})
+}
+
+func (s *guardedProvider) PullManifest(ctx context.Context, h string) ([]byte, error) {
+	return guardCall(s, ctx, true, func(ctx context.Context) ([]byte, error) {
+		return s.StoreProvider.PullManifest(ctx, h)
+	})
+}
+
+func (s *guardedProvider) PushVerdict(ctx context.Context, h string, verdict []byte) (bool, error) {
+	vp, ok := s.StoreProvider.(VerdictProvider)
+	if !ok {
+		return true, nil
+	}
+	return guardCall(s, ctx, true, func(ctx context.Context) (bool, error) {
+		return vp.PushVerdict(ctx, h, verdict)
+	})
+}
+
+func (s *guardedProvider) PullVerdict(ctx context.Context, h string) ([]byte, error) {
+	vp, ok := s.StoreProvider.(VerdictProvider)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return guardCall(s, ctx, true, func(ctx context.Context) ([]byte, error) {
+		return vp.PullVerdict(ctx, h)
+	})
+}
+
+func (s *guardedProvider) Delete(ctx context.Context, h string) (bool, error) {
+	return guardCall(s, ctx, true, func(ctx context.Context) (bool, error) {
+		return s.StoreProvider.Delete(ctx, h)
+	})
+}
+
+// PullStream runs without the call timeout: the stream outlives this call and
+// a deadline would cut the body off mid-transfer.
+func (s *guardedProvider) PullStream(ctx context.Context, h string) (io.ReadCloser, error) {
+	return guardCall(s, ctx, false, func(ctx context.Context) (io.ReadCloser, error) {
+		if sp, ok := s.StoreProvider.(StreamPuller); ok {
+			return sp.PullStream(ctx, h)
+		}
+		torrent, err := s.StoreProvider.Pull(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(torrent)), nil
+	})
+}
+
+func (s *guardedProvider) Durable() bool {
+	d, ok := s.StoreProvider.(DurableProvider)
+	return ok && d.Durable()
+}
+
+var _ StoreProvider = (*guardedProvider)(nil)
+var _ StreamPuller = (*guardedProvider)(nil)
+var _ DurableProvider = (*guardedProvider)(nil)
+var _ VerdictProvider = (*guardedProvider)(nil)
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// slowProvider blocks every Pull until the call context is done, mimicking
+// a Redis that accepts connections but never answers.
+type slowProvider struct {
+	*fakeProvider
+	pulls atomic.Int32
+}
+
+func (s *slowProvider) Pull(ctx context.Context, _ string) ([]byte, error) {
+	s.pulls.Add(1)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestParseProviderPolicy(t *testing.T) {
+	got := map[string]string{}
+	err := parseProviderPolicy(" redis=300ms, s3=5s ,,*=1s", func(name string, v string) error {
+		got[name] = v
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got["redis"] != "300ms" || got["s3"] != "5s" || got["*"] != "1s" {
+		t.Fatalf("got %v", got)
+	}
+	if err := parseProviderPolicy("redis", func(string, string) error { return nil }); err == nil {
+		t.Fatal("expected error for entry without value")
+	}
+	cfg := &GuardConfig{Timeouts: map[string]time.Duration{"redis": time.Second, "*": time.Minute}}
+	if cfg.timeout("redis") != time.Second || cfg.timeout("s3") != time.Minute {
+		t.Fatal("per-provider timeout must override the * default")
+	}
+}
+
+func TestStorePullFallsThroughSlowProvider(t *testing.T) {
+	redis := &slowProvider{fakeProvider: newFakeProvider("redis", true)}
+	s3 := newFakeProvider("s3", true)
+	store := NewStore([]StoreProvider{redis, s3}, &StoreConfig{
+		Guard: &GuardConfig{Timeouts: map[string]time.Duration{"redis": 20 * time.Millisecond}},
+	})
+	_, _ = s3.Push(context.Background(), "h", []byte("t"))
+
+	torrent, err := store.Pull(context.Background(), "h")
+	if err != nil || string(torrent) != "t" {
+		t.Fatalf("torrent = %q, err = %v; want t from s3", torrent, err)
+	}
+}
+
+func TestStorePullSurfacesErrorWhenAbsenceUnconfirmed(t *testing.T) {
+	redis := &slowProvider{fakeProvider: newFakeProvider("redis", true)}
+	s3 := newFakeProvider("s3", true)
+	store := NewStore([]StoreProvider{redis, s3}, &StoreConfig{
+		Guard: &GuardConfig{Timeouts: map[string]time.Duration{"*": 20 * time.Millisecond}},
+	})
+	if _, err := store.pull(context.Background(), "missing", 0); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want timeout", err)
+	}
+}
+
+func TestGuardRetries(t *testing.T) {
+	flaky := &flakyProvider{fakeProvider: newFakeProvider("s3", true), fails: 2}
+	g := newGuardedProvider(flaky, &GuardConfig{Retries: map[string]int{"s3": 2}})
+	if _, err := g.Push(context.Background(), "h", []byte("t")); err != nil {
+		t.Fatal(err)
+	}
+	if n := flaky.pushes.Load(); n != 3 {
+		t.Fatalf("pushes = %d, want 3", n)
+	}
+}
+
+func TestGuardBreakerOpensAndRecovers(t *testing.T) {
+	redis := &slowProvider{fakeProvider: newFakeProvider("redis", true)}
+	g := newGuardedProvider(redis, &GuardConfig{
+		Timeouts:         map[string]time.Duration{"*": 5 * time.Millisecond},
+		BreakerThreshold: 2,
+		BreakerCooldown:  50 * time.Millisecond,
+	})
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := g.Pull(ctx, "h"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want deadline exceeded", err)
+		}
+	}
+	// Open: the provider is skipped without being called.
+	if _, err := g.Pull(ctx, "h"); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("err = %v, want ErrProviderUnavailable", err)
+	}
+	if n := redis.pulls.Load(); n != 2 {
+		t.Fatalf("pulls = %d, want 2", n)
+	}
+	// ErrNotFound on the half-open trial closes the breaker again.
+	time.Sleep(60 * time.Millisecond)
+	if _, err := g.Exists(ctx, "h"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	if g.state != breakerClosed {
+		t.Fatalf("state = %d, want closed", g.state)
+	}
+}
+
+func TestGuardIgnoresCallerCancel(t *testing.T) {
+	redis := &slowProvider{fakeProvider: newFakeProvider("redis", true)}
+	g := newGuardedProvider(redis, &GuardConfig{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _ = g.Pull(ctx, "h")
+	if g.state != breakerClosed {
+		t.Fatal("a cancelled caller must not trip the breaker")
+	}
+
+	// Nor close a half-open one: the trial slot is freed for the next call.
+	g = newGuardedProvider(redis, &GuardConfig{BreakerThreshold: 1})
+	g.setState(breakerHalfOpen)
+	_, _ = g.Pull(ctx, "h")
+	if g.state != breakerHalfOpen {
+		t.Fatalf("state = %d, want half-open", g.state)
+	}
+	if !g.allow() {
+		t.Fatal("a cancelled trial must free the trial slot")
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	HedgeFlag           = "hedge"
+	HedgeDelayFlag      = "hedge-delay"
+	HedgePercentileFlag = "hedge-percentile"
+	// hedgeWindow is the number of recent latencies kept per tier.
+	hedgeWindow = 256
+	// hedgeMinSamples is how many latencies a tier needs before its
+	// percentile replaces the fixed delay.
+	hedgeMinSamples = 20
+)
+
+var (
+	hedgeFiredTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_hedge_fired_total",
+		Help: "Hedged reads started because a tier was slow to answer, labelled by the slow provider.",
+	}, []string{"provider"})
+	hedgeWonTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_hedge_won_total",
+		Help: "Tier that served a pull on which hedging fired, labelled by provider.",
+	}, []string{"provider"})
+)
+
+func RegisterHedgeFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.BoolFlag{
+			Name:   HedgeFlag,
+			Usage:  "query the next tier in parallel when a tier is slow to answer a pull",
+			EnvVar: "HEDGE",
+		},
+		cli.IntFlag{
+			Name:   HedgeDelayFlag,
+			Usage:  "hedge delay (ms), used as is or until enough latencies are observed for --hedge-percentile",
+			Value:  50,
+			EnvVar: "HEDGE_DELAY",
+		},
+		cli.Float64Flag{
+			Name:   HedgePercentileFlag,
+			Usage:  "derive the per-tier hedge delay from this latency percentile (e.g. 95), 0 uses the fixed delay",
+			Value:  0,
+			EnvVar: "HEDGE_PERCENTILE",
+		},
+	)
+}
+
+type HedgeConfig struct {
+	Enabled    bool
+	Delay      time.Duration
+	Percentile float64
+}
+
+func NewHedgeConfig(c *cli.Context) *HedgeConfig {
+	return &HedgeConfig{
+		Enabled:    c.Bool(HedgeFlag),
+		Delay:      time.Duration(c.Int(HedgeDelayFlag)) * time.Millisecond,
+		Percentile: c.Float64(HedgePercentileFlag),
+	}
+}
+
+// hedge keeps a window of recent latencies per tier to turn the configured
+// percentile into a delay.
+type hedge struct {
+	cfg *HedgeConfig
+	mux sync.Mutex
+	lat map[string][]time.Duration
+	pos map[string]int
+}
+
+func newHedge(cfg *HedgeConfig) *hedge {
+	return &hedge{
+		cfg: cfg,
+		lat: map[string][]time.Duration{},
+		pos: map[string]int{},
+	}
+}
+
+func (s *hedge) observe(name string, d time.Duration) {
+	if s.cfg.Percentile <= 0 {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if len(s.lat[name]) < hedgeWindow {
+		s.lat[name] = append(s.lat[name], d)
+		return
+	}
+	s.lat[name][s.pos[name]] = d
+	s.pos[name] = (s.pos[name] + 1) % hedgeWindow
+}
+
+func (s *hedge) delay(name string) time.Duration {
+	if s.cfg.Percentile <= 0 {
+		return s.cfg.Delay
+	}
+	s.mux.Lock()
+	if len(s.lat[name]) < hedgeMinSamples {
+		s.mux.Unlock()
+		return s.cfg.Delay
+	}
+	lat := append([]time.Duration(nil), s.lat[name]...)
+	s.mux.Unlock()
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	i := int(float64(len(lat)-1) * s.cfg.Percentile / 100)
+	if i >= len(lat) {
+		i = len(lat) - 1
+	}
+	return lat[i]
+}
+
+type hedgeResult struct {
+	i       int
+	torrent []byte
+	err     error
+	d       time.Duration
+}
+
+// hedgedPull walks providers from start like sequentialPull, but once a tier
+// has been pending for its hedge delay the next tier is queried alongside it.
+// A miss or an error starts the next tier right away. The first successful
+// answer wins and the calls still in flight are cancelled.
+func (s *Store) hedgedPull(ctx context.Context, h string, start int) (torrent []byte, err error) {
+	if start >= len(s.providers) {
+		return nil, ErrNotFound
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resC := make(chan hedgeResult, len(s.providers)-start)
+	launch := func(i int) {
+		go func() {
+			t := time.Now()
+			torrent, err := s.providers[i].Pull(cctx, h)
+			resC <- hedgeResult{i: i, torrent: torrent, err: err, d: time.Since(t)}
+		}()
+	}
+	t := time.Now()
+	launch(start)
+	last, next, inflight := start, start+1, 1
+	hedged := false
+	var lastErr error
+	timer := time.NewTimer(s.hedge.delay(s.providers[start].Name()))
+	defer timer.Stop()
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			if next < len(s.providers) {
+				hedged = true
+				hedgeFiredTotal.WithLabelValues(s.providers[last].Name()).Inc()
+				log.WithField("infohash", h).WithField("provider", s.providers[last].Name()).Info("provider slow, hedging pull")
+				launch(next)
+				last, next, inflight = next, next+1, inflight+1
+				timer.Reset(s.hedge.delay(s.providers[last].Name()))
+			}
+		case r := <-resC:
+			inflight--
+			name := s.providers[r.i].Name()
+			if r.err == nil || errors.Is(r.err, ErrNotFound) {
+				s.hedge.observe(name, r.d)
+			}
+			if r.err == nil {
+				log.WithField("infohash", h).WithField("duration", r.d).WithField("provider", name).Info("provider pull")
+				if hedged {
+					hedgeWonTotal.WithLabelValues(name).Inc()
+				}
+				cancel()
+				s.backfill(ctx, h, r.torrent, r.i, t)
+				return r.torrent, nil
+			}
+			if !errors.Is(r.err, ErrNotFound) {
+				log.WithField("infohash", h).WithField("duration", r.d).WithField("provider", name).WithError(r.err).Warn("provider has error")
+				lastErr = r.err
+			}
+			if r.i == last && next < len(s.providers) {
+				// The newest tier gave up; don't wait out its delay.
+				launch(next)
+				last, next, inflight = next, next+1, inflight+1
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(s.hedge.delay(s.providers[last].Name()))
+			}
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestStoreHedgedPullSlowTier(t *testing.T) {
+	redis := &slowProvider{fakeProvider: newFakeProvider("redis", true)}
+	s3 := newFakeProvider("s3", true)
+	store := NewStore([]StoreProvider{redis, s3}, &StoreConfig{
+		Hedge: &HedgeConfig{Enabled: true, Delay: 10 * time.Millisecond},
+	})
+	_, _ = s3.Push(context.Background(), "h", []byte("t"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	torrent, err := store.Pull(ctx, "h")
+	if err != nil || string(torrent) != "t" {
+		t.Fatalf("torrent = %q, err = %v; want t from s3", torrent, err)
+	}
+	// The hedged-against tier is still warmed up with the winner's copy.
+	if _, ok := redis.torrents["h"]; !ok {
+		t.Fatal("hedged pull must backfill upper tiers")
+	}
+}
+
+func TestStoreHedgedPullMissStartsNextTierRightAway(t *testing.T) {
+	fast := newFakeProvider("fast", true)
+	slow := newFakeProvider("slow", true)
+	store := NewStore([]StoreProvider{fast, slow}, &StoreConfig{
+		Hedge: &HedgeConfig{Enabled: true, Delay: time.Hour},
+	})
+	_, _ = slow.Push(context.Background(), "h", []byte("t"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := store.Pull(context.Background(), "h"); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a miss must not wait for the hedge delay")
+	}
+	if _, err := store.Pull(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestHedgePercentileDelay(t *testing.T) {
+	hd := newHedge(&HedgeConfig{Delay: time.Second, Percentile: 95})
+	if d := hd.delay("redis"); d != time.Second {
+		t.Fatalf("delay = %v, want fixed delay before enough samples", d)
+	}
+	for i := 1; i <= 100; i++ {
+		hd.observe("redis", time.Duration(i)*time.Millisecond)
+	}
+	if d := hd.delay("redis"); d < 90*time.Millisecond || d > 96*time.Millisecond {
+		t.Fatalf("delay = %v, want ~p95", d)
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	cs "github.com/webtor-io/common-services"
+)
+
+const (
+	RateLimitsFlag     = "rate-limits"
+	RateLimitRedisFlag = "rate-limit-redis"
+	rateLimitDefault   = "*"
+	rateLimitKeyPrefix = "rl:"
+	// tokenBucketSweep is how often idle, fully refilled buckets are dropped.
+	tokenBucketSweep = time.Minute
+)
+
+var (
+	rateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_rate_limited_total",
+		Help: "Calls refused with ResourceExhausted by the rate limiter, labelled by rpc.",
+	}, []string{"rpc"})
+	rateLimitErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_rate_limit_errors_total",
+		Help: "Limiter backend failures (calls are let through), labelled by rpc.",
+	}, []string{"rpc"})
+)
+
+func RegisterRateLimitFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.StringFlag{
+			Name:   RateLimitsFlag,
+			Usage:  "per-rpc miss budget per infoHash as rpc=N/period, e.g. \"pull=10/1m,touch=20/1m,*=10/1m\" (off disables, empty disables all)",
+			Value:  "*=10/1m",
+			EnvVar: "RATE_LIMITS",
+		},
+		cli.BoolFlag{
+			Name:   RateLimitRedisFlag,
+			Usage:  "keep rate limit buckets in redis so limits are shared across replicas",
+			EnvVar: "RATE_LIMIT_REDIS",
+		},
+	)
+}
+
+// Limit is a token bucket of Burst tokens refilled evenly over Period.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Limiter meters per-key token buckets. Allow only looks at the bucket,
+// Spend takes a token from it, so callers can decide after the fact which
+// calls are charged.
+type Limiter interface {
+	Allow(ctx context.Context, key string, l Limit) (bool, error)
+	Spend(ctx context.Context, key string, l Limit) error
+}
+
+// RateLimit applies per-RPC limits to per-infoHash buckets. Only lookups of
+// unknown infoHashes are charged: a hot torrent must never be throttled, but
+// a client hammering hashes the store doesn't have is cut off. A nil
+// RateLimit lets everything through.
+type RateLimit struct {
+	l      Limiter
+	limits map[string]*Limit
+}
+
+func NewRateLimit(c *cli.Context, cl *cs.RedisClient) (*RateLimit, error) {
+	limits, err := parseRateLimits(c.String(RateLimitsFlag))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse --%v", RateLimitsFlag)
+	}
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	var l Limiter = NewTokenBucket()
+	if c.Bool(RateLimitRedisFlag) {
+		l = NewRedisTokenBucket(cl, c.String(RedisKeyPrefixFlag))
+	}
+	return &RateLimit{l: l, limits: limits}, nil
+}
+
+// parseRateLimits reads "rpc=N/period" entries. A nil Limit (value "off")
+// exempts an rpc from the "*" default.
+func parseRateLimits(raw string) (map[string]*Limit, error) {
+	limits := map[string]*Limit{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("malformed entry %q, want rpc=N/period", part)
+		}
+		rpc, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if v == "off" {
+			limits[rpc] = nil
+			continue
+		}
+		l, err := parseLimit(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed entry %q", part)
+		}
+		limits[rpc] = l
+	}
+	return limits, nil
+}
+
+// parseLimit reads a single "N/period" limit.
+func parseLimit(v string) (*Limit, error) {
+	nv := strings.SplitN(v, "/", 2)
+	if len(nv) != 2 {
+		return nil, errors.Errorf("want N/period, got %q", v)
+	}
+	n, err := strconv.Atoi(nv[0])
+	if err != nil || n <= 0 {
+		return nil, errors.Errorf("N must be a positive integer, got %q", nv[0])
+	}
+	d, err := time.ParseDuration(nv[1])
+	if err != nil || d <= 0 {
+		return nil, errors.Errorf("period must be a positive duration, got %q", nv[1])
+	}
+	return &Limit{Burst: n, Period: d}, nil
+}
+
+func (s *RateLimit) limit(rpc string) *Limit {
+	if l, ok := s.limits[rpc]; ok {
+		return l
+	}
+	return s.limits[rateLimitDefault]
+}
+
+// Allow reports whether h still has budget for rpc. A failing limiter
+// backend lets the call through.
+func (s *RateLimit) Allow(ctx context.Context, rpc string, h string) bool {
+	if s == nil {
+		return true
+	}
+	l := s.limit(rpc)
+	if l == nil {
+		return true
+	}
+	ok, err := s.l.Allow(ctx, rpc+":"+h, *l)
+	if err != nil {
+		rateLimitErrorsTotal.WithLabelValues(rpc).Inc()
+		log.WithField("infohash", h).WithField("rpc", rpc).WithError(err).Warn("failed to check rate limit")
+		return true
+	}
+	if !ok {
+		rateLimitedTotal.WithLabelValues(rpc).Inc()
+	}
+	return ok
+}
+
+// Spend charges one miss of h against rpc's budget.
+func (s *RateLimit) Spend(ctx context.Context, rpc string, h string) {
+	if s == nil {
+		return
+	}
+	l := s.limit(rpc)
+	if l == nil {
+		return
+	}
+	if err := s.l.Spend(ctx, rpc+":"+h, *l); err != nil {
+		rateLimitErrorsTotal.WithLabelValues(rpc).Inc()
+		log.WithField("infohash", h).WithField("rpc", rpc).WithError(err).Warn("failed to spend rate limit")
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// TokenBucket is the in-process Limiter. Limits are per replica.
+type TokenBucket struct {
+	mux       sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewTokenBucket() *TokenBucket {
+	return &TokenBucket{
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// refill returns key's bucket topped up to now. Must be called with mux held.
+func (s *TokenBucket) refill(key string, l Limit, now time.Time) *tokenBucket {
+	b, ok := s.buckets[key]
+	if !ok {
+		return &tokenBucket{tokens: float64(l.Burst), last: now, period: l.Period}
+	}
+	rate := float64(l.Burst) / l.Period.Seconds()
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	return b
+}
+
+func (s *TokenBucket) Allow(_ context.Context, key string, l Limit) (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.refill(key, l, s.now()).tokens >= 1, nil
+}
+
+func (s *TokenBucket) Spend(_ context.Context, key string, l Limit) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := s.now()
+	b := s.refill(key, l, now)
+	b.tokens = math.Max(0, b.tokens-1)
+	s.buckets[key] = b
+	if now.Sub(s.lastSweep) > tokenBucketSweep {
+		s.sweep(now)
+	}
+	return nil
+}
+
+// sweep forgets buckets that had time to refill completely: a missing bucket
+// reads as full anyway.
+func (s *TokenBucket) sweep(now time.Time) {
+	s.lastSweep = now
+	for k, b := range s.buckets {
+		if now.Sub(b.last) >= b.period {
+			delete(s.buckets, k)
+		}
+	}
+}
+
+// tokenBucketScript is the token bucket of TokenBucket evaluated atomically
+// in Redis, using the server clock so replicas agree on refill. ARGV: burst,
+// period (ms), cost. Returns 1 if at least one token is left.
+var tokenBucketScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local v = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(v[1])
+local ts = tonumber(v[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + (now - ts) * burst / period)
+if cost > 0 then
+	tokens = math.max(0, tokens - cost)
+	redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+	redis.call('PEXPIRE', KEYS[1], period)
+end
+if tokens >= 1 then
+	return 1
+end
+return 0
+`)
+
+// RedisTokenBucket is a Limiter shared by every replica using the same Redis.
+// Buckets are namespaced by the Redis key prefix.
+type RedisTokenBucket struct {
+	cl     *cs.RedisClient
+	prefix string
+}
+
+func NewRedisTokenBucket(cl *cs.RedisClient, prefix string) *RedisTokenBucket {
+	return &RedisTokenBucket{cl: cl, prefix: prefix + rateLimitKeyPrefix}
+}
+
+func (s *RedisTokenBucket) run(ctx context.Context, key string, l Limit, cost int) (bool, error) {
+	res, err := tokenBucketScript.Run(ctx, s.cl.Get(), []string{s.prefix + key}, l.Burst, l.Period.Milliseconds(), cost).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+func (s *RedisTokenBucket) Allow(ctx context.Context, key string, l Limit) (bool, error) {
+	return s.run(ctx, key, l, 0)
+}
+
+func (s *RedisTokenBucket) Spend(ctx context.Context, key string, l Limit) error {
+	_, err := s.run(ctx, key, l, 1)
+	return err
+}
+
+var _ Limiter = (*TokenBucket)(nil)
+var _ Limiter = (*RedisTokenBucket)(nil)
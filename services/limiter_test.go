@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := parseRateLimits("pull=10/1m, touch=off ,*=5/30s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := limits["pull"]; l == nil || l.Burst != 10 || l.Period != time.Minute {
+		t.Fatalf("pull = %+v", l)
+	}
+	rl := &RateLimit{limits: limits}
+	if rl.limit("touch") != nil {
+		t.Fatal("off must exempt touch from the default")
+	}
+	if l := rl.limit("files"); l == nil || l.Burst != 5 {
+		t.Fatalf("files = %+v, want * default", l)
+	}
+	for _, raw := range []string{"pull", "pull=10", "pull=0/1m", "pull=10/soon"} {
+		if _, err := parseRateLimits(raw); err == nil {
+			t.Fatalf("%q: expected error", raw)
+		}
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	tb := NewTokenBucket()
+	tb.now = func() time.Time { return now }
+	tb.lastSweep = now
+	l := Limit{Burst: 2, Period: time.Minute}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := tb.Allow(ctx, "k", l); !ok {
+			t.Fatalf("spend %d: bucket must not be empty yet", i)
+		}
+		_ = tb.Spend(ctx, "k", l)
+	}
+	if ok, _ := tb.Allow(ctx, "k", l); ok {
+		t.Fatal("bucket must be empty")
+	}
+	if ok, _ := tb.Allow(ctx, "other", l); !ok {
+		t.Fatal("buckets are per key")
+	}
+	// One token comes back every Period/Burst.
+	now = now.Add(30 * time.Second)
+	if ok, _ := tb.Allow(ctx, "k", l); !ok {
+		t.Fatal("bucket must refill")
+	}
+	now = now.Add(2 * tokenBucketSweep)
+	_ = tb.Spend(ctx, "other", l)
+	if _, ok := tb.buckets["k"]; ok {
+		t.Fatal("refilled bucket must be swept")
+	}
+}
+
+func TestServerPullRateLimitedMisses(t *testing.T) {
+	srv := &Server{
+		s: NewStore([]StoreProvider{newFakeProvider("fast", true)}, nil),
+		rl: &RateLimit{l: NewTokenBucket(), limits: map[string]*Limit{
+			"*": {Burst: 2, Period: time.Minute},
+		}},
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := srv.pull(ctx, "missing", "pull"); status.Code(err) != codes.NotFound {
+			t.Fatalf("miss %d: code = %v, want NotFound", i, status.Code(err))
+		}
+	}
+	if _, err := srv.pull(ctx, "missing", "pull"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("code = %v, want ResourceExhausted", status.Code(err))
+	}
+	// Budgets are per rpc.
+	if _, err := srv.pull(ctx, "missing", "batch_pull"); status.Code(err) != codes.NotFound {
+		t.Fatalf("code = %v, want NotFound", status.Code(err))
+	}
+}
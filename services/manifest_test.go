@@ -73,6 +73,7 @@ type fakeProvider struct {
 	mu            sync.Mutex
 	torrents      map[string][]byte
 	manifests     map[string][]byte
+	verdicts      map[string][]byte
 	pullManiCalls int
 	pushManiCalls int
 }
@@ -83,6 +84,7 @@ func newFakeProvider(name string, supportsMani bool) *fakeProvider {
 		supportsMani: supportsMani,
 		torrents:     map[string][]byte{},
 		manifests:    map[string][]byte{},
+		verdicts:     map[string][]byte{},
 	}
 }
 
@@ -114,6 +116,24 @@ func (f *fakeProvider) Touch(_ context.Context, h string) (bool, error) {
 	return true, nil
 }
 
+func (f *fakeProvider) Exists(_ context.Context, h string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.torrents[h]; !ok {
+		return false, ErrNotFound
+	}
+	return true, nil
+}
+
+func (f *fakeProvider) Delete(_ context.Context, h string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.torrents, h)
+	delete(f.manifests, h)
+	delete(f.verdicts, h)
+	return true, nil
+}
+
 func (f *fakeProvider) PushManifest(_ context.Context, h string, manifest []byte) (bool, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -139,11 +159,31 @@ func (f *fakeProvider) PullManifest(_ context.Context, h string) ([]byte, error)
 	return v, nil
 }
 
+// Verdicts share the manifest opt-out: an S3-like fake holds none.
+func (f *fakeProvider) PushVerdict(_ context.Context, h string, verdict []byte) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.supportsMani {
+		f.verdicts[h] = verdict
+	}
+	return true, nil
+}
+
+func (f *fakeProvider) PullVerdict(_ context.Context, h string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.verdicts[h]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
 func TestStoreManifestBuildOnceAndBackfill(t *testing.T) {
 	fast := newFakeProvider("fast", true)
 	slow := newFakeProvider("slow", true)
 	s3 := newFakeProvider("s3", false)
-	store := NewStore([]StoreProvider{fast, slow, s3})
+	store := NewStore([]StoreProvider{fast, slow, s3}, nil)
 
 	torrent := makeMultiFileTorrent(t, "x", []metainfo.FileInfo{
 		{Path: []string{"a"}, Length: 1},
@@ -185,7 +225,7 @@ func TestStoreManifestBuildOnceAndBackfill(t *testing.T) {
 func TestStorePullManifestBackfillsUpperTier(t *testing.T) {
 	fast := newFakeProvider("fast", true)
 	slow := newFakeProvider("slow", true)
-	store := NewStore([]StoreProvider{fast, slow})
+	store := NewStore([]StoreProvider{fast, slow}, nil)
 
 	const h = "abc123"
 	// Seed only the slow tier.
@@ -206,7 +246,7 @@ func TestStorePullManifestBackfillsUpperTier(t *testing.T) {
 
 func TestStoreManifestServesFromCacheWithoutBuild(t *testing.T) {
 	fast := newFakeProvider("fast", true)
-	store := NewStore([]StoreProvider{fast})
+	store := NewStore([]StoreProvider{fast}, nil)
 
 	const h = "cached1"
 	reply := &pb.FilesReply{Name: "pre"}
@@ -14,9 +14,14 @@ import (
 // does not have the BEP-27 private flag set — adding open trackers to a
 // private torrent gets users banned from the original tracker.
 //
+// URLs the tracker blocklist strips are dropped from both torrents and
+// never appended, so the stored copy loses trackers blocked after it was
+// stored as well.
+//
 // Returns merged bytes and changed=true only when announce-list or
-// url-list actually grew. If nothing changed, returns existing as-is.
-func mergeTorrent(existing, incoming []byte, defaultTrackers []string) ([]byte, bool, error) {
+// url-list actually grew or lost stripped URLs. If nothing changed,
+// returns existing as-is.
+func mergeTorrent(existing, incoming []byte, defaultTrackers []string, tb *TrackerBlocklist) ([]byte, bool, error) {
 	exMi, err := metainfo.Load(bytes.NewReader(existing))
 	if err != nil {
 		return nil, false, err
@@ -31,7 +36,7 @@ func mergeTorrent(existing, incoming []byte, defaultTrackers []string) ([]byte,
 		private = true
 	}
 
-	merged := exMi.UpvertedAnnounceList()
+	merged, changed := stripTiers(exMi.UpvertedAnnounceList(), tb)
 	seen := map[string]struct{}{}
 	for _, tier := range merged {
 		for _, u := range tier {
@@ -39,11 +44,10 @@ func mergeTorrent(existing, incoming []byte, defaultTrackers []string) ([]byte,
 		}
 	}
 
-	changed := false
 	appendTier := func(urls []string) {
 		var tier []string
 		for _, u := range urls {
-			if u == "" {
+			if u == "" || tb.strips(u) {
 				continue
 			}
 			if _, ok := seen[u]; ok {
@@ -65,13 +69,14 @@ func mergeTorrent(existing, incoming []byte, defaultTrackers []string) ([]byte,
 		appendTier(defaultTrackers)
 	}
 
+	mergedUrls, stripped := stripUrls(exMi.UrlList, tb)
+	changed = changed || stripped
 	urlSeen := map[string]struct{}{}
-	for _, u := range exMi.UrlList {
+	for _, u := range mergedUrls {
 		urlSeen[u] = struct{}{}
 	}
-	mergedUrls := append(metainfo.UrlList(nil), exMi.UrlList...)
 	for _, u := range inMi.UrlList {
-		if u == "" {
+		if u == "" || tb.strips(u) {
 			continue
 		}
 		if _, ok := urlSeen[u]; ok {
@@ -87,6 +92,9 @@ func mergeTorrent(existing, incoming []byte, defaultTrackers []string) ([]byte,
 	}
 
 	exMi.AnnounceList = merged
+	if tb.strips(exMi.Announce) {
+		exMi.Announce = ""
+	}
 	if exMi.Announce == "" && len(merged) > 0 && len(merged[0]) > 0 {
 		exMi.Announce = merged[0][0]
 	}
@@ -98,3 +106,71 @@ func mergeTorrent(existing, incoming []byte, defaultTrackers []string) ([]byte,
 	}
 	return buf.Bytes(), true, nil
 }
+
+// stripTrackers drops the URLs the tracker blocklist strips from torrent,
+// for torrents stored without a merge. Returns torrent as-is and
+// changed=false if none is blocked.
+func stripTrackers(torrent []byte, tb *TrackerBlocklist) ([]byte, bool, error) {
+	if tb == nil || !tb.strip {
+		return torrent, false, nil
+	}
+	mi, err := metainfo.Load(bytes.NewReader(torrent))
+	if err != nil {
+		return nil, false, err
+	}
+	tiers, changed := stripTiers(mi.UpvertedAnnounceList(), tb)
+	urls, stripped := stripUrls(mi.UrlList, tb)
+	announce := tb.strips(mi.Announce)
+	if !changed && !stripped && !announce {
+		return torrent, false, nil
+	}
+	mi.AnnounceList = tiers
+	if announce {
+		mi.Announce = ""
+		if len(tiers) > 0 && len(tiers[0]) > 0 {
+			mi.Announce = tiers[0][0]
+		}
+	}
+	mi.UrlList = urls
+	var buf bytes.Buffer
+	if err := mi.Write(&buf); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// stripTiers returns tiers without the URLs tb strips, dropping emptied
+// tiers.
+func stripTiers(tiers metainfo.AnnounceList, tb *TrackerBlocklist) (metainfo.AnnounceList, bool) {
+	var res metainfo.AnnounceList
+	stripped := false
+	for _, tier := range tiers {
+		var kept []string
+		for _, u := range tier {
+			if tb.strips(u) {
+				stripped = true
+				continue
+			}
+			kept = append(kept, u)
+		}
+		if len(kept) > 0 {
+			res = append(res, kept)
+		}
+	}
+	return res, stripped
+}
+
+// stripUrls returns urls without the web seeds tb strips.
+func stripUrls(urls metainfo.UrlList, tb *TrackerBlocklist) (metainfo.UrlList, bool) {
+	res := append(metainfo.UrlList(nil), urls...)
+	if tb == nil || !tb.strip {
+		return res, false
+	}
+	res = res[:0]
+	for _, u := range urls {
+		if !tb.strips(u) {
+			res = append(res, u)
+		}
+	}
+	return res, len(res) != len(urls)
+}
@@ -54,7 +54,7 @@ func TestMergeAddsTrackers(t *testing.T) {
 		metainfo.AnnounceList{{"http://a/announce"}, {"udp://b:80/announce"}},
 		nil, false)
 
-	merged, changed, err := mergeTorrent(existing, incoming, nil)
+	merged, changed, err := mergeTorrent(existing, incoming, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -73,7 +73,7 @@ func TestMergeDedup(t *testing.T) {
 	incoming := makeTorrent(t, "",
 		metainfo.AnnounceList{{"http://a/announce"}, {"udp://c:80/announce"}}, nil, false)
 
-	merged, changed, err := mergeTorrent(existing, incoming, nil)
+	merged, changed, err := mergeTorrent(existing, incoming, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -92,7 +92,7 @@ func TestMergeNoOpWhenSubset(t *testing.T) {
 	incoming := makeTorrent(t, "",
 		metainfo.AnnounceList{{"http://a/announce"}}, nil, false)
 
-	merged, changed, err := mergeTorrent(existing, incoming, nil)
+	merged, changed, err := mergeTorrent(existing, incoming, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -110,7 +110,7 @@ func TestMergeDefaultTrackersNonPrivate(t *testing.T) {
 	incoming := existing
 	defaults := []string{"udp://open.demonii.com:1337/announce", "http://a/announce"}
 
-	merged, changed, err := mergeTorrent(existing, incoming, defaults)
+	merged, changed, err := mergeTorrent(existing, incoming, defaults, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -129,7 +129,7 @@ func TestMergeDefaultTrackersSkippedOnPrivate(t *testing.T) {
 	incoming := existing
 	defaults := []string{"udp://open.demonii.com:1337/announce"}
 
-	merged, changed, err := mergeTorrent(existing, incoming, defaults)
+	merged, changed, err := mergeTorrent(existing, incoming, defaults, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -148,7 +148,7 @@ func TestMergeUrlList(t *testing.T) {
 	incoming := makeTorrent(t, "", metainfo.AnnounceList{{"http://a/announce"}},
 		metainfo.UrlList{"https://web1/", "https://web2/"}, false)
 
-	merged, changed, err := mergeTorrent(existing, incoming, nil)
+	merged, changed, err := mergeTorrent(existing, incoming, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -168,7 +168,7 @@ func TestMergePreservesInfoHash(t *testing.T) {
 	exMi, _ := metainfo.Load(bytes.NewReader(existing))
 	exHash := exMi.HashInfoBytes()
 
-	merged, _, err := mergeTorrent(existing, incoming, nil)
+	merged, _, err := mergeTorrent(existing, incoming, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
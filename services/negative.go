@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	cs "github.com/webtor-io/common-services"
+)
+
+const (
+	NegativeCacheTTLFlag   = "negative-cache-ttl"
+	NegativeCacheRedisFlag = "negative-cache-redis"
+	negativeCacheKeyPrefix = "nf:"
+	negativeCacheGenPrefix = "nfg:"
+	// negativeCacheStripes is the number of push generation counters of
+	// MemoryNegativeCache.
+	negativeCacheStripes = 64
+)
+
+var (
+	negativeCacheHitsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "torrent_store_negative_cache_hits_total",
+		Help: "Lookups answered with not found from the negative cache without walking the tiers.",
+	})
+	negativeCacheMissesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "torrent_store_negative_cache_misses_total",
+		Help: "Lookups not found in the negative cache that walked the tiers.",
+	})
+)
+
+func RegisterNegativeCacheFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.IntFlag{
+			Name:   NegativeCacheTTLFlag,
+			Usage:  "remember missing infoHashes for this long (sec), 0 disables",
+			Value:  0,
+			EnvVar: "NEGATIVE_CACHE_TTL",
+		},
+		cli.BoolFlag{
+			Name:   NegativeCacheRedisFlag,
+			Usage:  "keep the negative cache in redis so it is shared across replicas",
+			EnvVar: "NEGATIVE_CACHE_REDIS",
+		},
+	)
+}
+
+// NegativeCache remembers infoHashes that no tier holds. Store consults it
+// before walking the tiers and drops the entry as soon as h is pushed.
+//
+// Every Remove bumps a push generation of h. Has returns the generation
+// the walk starts at and Add only records h if it is still the same:
+// otherwise the Add could land after the Remove of a push that stored h
+// while the walk was under way, and h would read as missing for the whole
+// ttl.
+type NegativeCache interface {
+	Has(ctx context.Context, h string) (missing bool, gen uint64, err error)
+	Add(ctx context.Context, h string, gen uint64) error
+	Remove(ctx context.Context, h string) error
+}
+
+func NewNegativeCache(c *cli.Context, cl *cs.RedisClient) NegativeCache {
+	ttl := time.Duration(c.Int(NegativeCacheTTLFlag)) * time.Second
+	if ttl <= 0 {
+		return nil
+	}
+	if c.Bool(NegativeCacheRedisFlag) {
+		return NewRedisNegativeCache(cl, c.String(RedisKeyPrefixFlag), ttl)
+	}
+	return NewMemoryNegativeCache(ttl)
+}
+
+// MemoryNegativeCache is the in-process NegativeCache. Each replica only
+// sees its own Pushes, so a torrent pushed through another replica may read
+// as missing here for up to ttl. Push generations are striped by infoHash.
+type MemoryNegativeCache struct {
+	mux       sync.Mutex
+	ttl       time.Duration
+	entries   map[string]time.Time
+	gens      [negativeCacheStripes]uint64
+	lastSweep time.Time
+}
+
+func NewMemoryNegativeCache(ttl time.Duration) *MemoryNegativeCache {
+	return &MemoryNegativeCache{
+		ttl:       ttl,
+		entries:   map[string]time.Time{},
+		lastSweep: time.Now(),
+	}
+}
+
+func negativeCacheStripe(h string) int {
+	var sum uint32 = 2166136261
+	for i := 0; i < len(h); i++ {
+		sum = (sum ^ uint32(h[i])) * 16777619
+	}
+	return int(sum % negativeCacheStripes)
+}
+
+func (s *MemoryNegativeCache) Has(_ context.Context, h string) (bool, uint64, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	gen := s.gens[negativeCacheStripe(h)]
+	exp, ok := s.entries[h]
+	if !ok {
+		return false, gen, nil
+	}
+	if time.Now().After(exp) {
+		delete(s.entries, h)
+		return false, gen, nil
+	}
+	return true, gen, nil
+}
+
+func (s *MemoryNegativeCache) Add(_ context.Context, h string, gen uint64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.gens[negativeCacheStripe(h)] != gen {
+		return nil
+	}
+	now := time.Now()
+	s.entries[h] = now.Add(s.ttl)
+	if now.Sub(s.lastSweep) > s.ttl {
+		s.lastSweep = now
+		for k, exp := range s.entries {
+			if now.After(exp) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryNegativeCache) Remove(_ context.Context, h string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.gens[negativeCacheStripe(h)]++
+	delete(s.entries, h)
+	return nil
+}
+
+// negativeHasScript returns whether KEYS[1] exists and the push generation
+// in KEYS[2].
+var negativeHasScript = redis.NewScript(`
+return {redis.call('EXISTS', KEYS[1]), tonumber(redis.call('GET', KEYS[2]) or '0')}
+`)
+
+// negativeAddScript sets KEYS[1] for ARGV[2] ms unless the push generation
+// in KEYS[2] moved past ARGV[1].
+var negativeAddScript = redis.NewScript(`
+if tonumber(redis.call('GET', KEYS[2]) or '0') ~= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], 1, 'PX', ARGV[2])
+return 1
+`)
+
+// negativeRemoveScript bumps the push generation in KEYS[2], kept for ARGV[1]
+// ms, and deletes KEYS[1].
+var negativeRemoveScript = redis.NewScript(`
+redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[1])
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// RedisNegativeCache shares the negative cache across replicas, so a Push
+// through any of them invalidates it for all. The push generation lives in
+// Redis as well, next to the entry, and is compared and set in one script.
+// A generation is kept for ttl after the last push: a walk outliving it
+// could record a stale miss. Keys are namespaced by the Redis key prefix.
+type RedisNegativeCache struct {
+	cl        *cs.RedisClient
+	prefix    string
+	genPrefix string
+	ttl       time.Duration
+}
+
+func NewRedisNegativeCache(cl *cs.RedisClient, prefix string, ttl time.Duration) *RedisNegativeCache {
+	return &RedisNegativeCache{
+		cl:        cl,
+		prefix:    prefix + negativeCacheKeyPrefix,
+		genPrefix: prefix + negativeCacheGenPrefix,
+		ttl:       ttl,
+	}
+}
+
+func (s *RedisNegativeCache) keys(h string) []string {
+	return []string{s.prefix + h, s.genPrefix + h}
+}
+
+func (s *RedisNegativeCache) Has(ctx context.Context, h string) (bool, uint64, error) {
+	res, err := negativeHasScript.Run(ctx, s.cl.Get(), s.keys(h)).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, errors.Errorf("unexpected negative cache reply %v", res)
+	}
+	return res[0] > 0, uint64(res[1]), nil
+}
+
+func (s *RedisNegativeCache) Add(ctx context.Context, h string, gen uint64) error {
+	return negativeAddScript.Run(ctx, s.cl.Get(), s.keys(h), gen, s.ttl.Milliseconds()).Err()
+}
+
+func (s *RedisNegativeCache) Remove(ctx context.Context, h string) error {
+	return negativeRemoveScript.Run(ctx, s.cl.Get(), s.keys(h), s.ttl.Milliseconds()).Err()
+}
+
+// missingGen is the push generation a walk of the tiers started at. It is
+// unset if the negative cache couldn't be read, and then the walk records
+// nothing.
+type missingGen struct {
+	gen uint64
+	ok  bool
+}
+
+// knownMissing reports whether the negative cache remembers h as missing.
+// A failing cache reads as a miss.
+func (s *Store) knownMissing(ctx context.Context, h string) (bool, missingGen) {
+	if s.negative == nil {
+		return false, missingGen{}
+	}
+	ok, gen, err := s.negative.Has(ctx, h)
+	if err != nil {
+		log.WithField("infohash", h).WithError(err).Warn("failed to read negative cache")
+		return false, missingGen{}
+	}
+	if ok {
+		negativeCacheHitsTotal.Inc()
+		log.WithField("infohash", h).Info("negative cache hit")
+		return true, missingGen{}
+	}
+	negativeCacheMissesTotal.Inc()
+	return false, missingGen{gen: gen, ok: true}
+}
+
+// rememberMissing records h in the negative cache if err confirms that no
+// tier holds it. The cache skips the Add if h was pushed since gen.
+func (s *Store) rememberMissing(ctx context.Context, h string, gen missingGen, err error) {
+	if s.negative == nil || !gen.ok || !errors.Is(err, ErrNotFound) {
+		return
+	}
+	if aerr := s.negative.Add(ctx, h, gen.gen); aerr != nil {
+		log.WithField("infohash", h).WithError(aerr).Warn("failed to write negative cache")
+	}
+}
+
+// forgetMissing drops h from the negative cache once it is stored.
+func (s *Store) forgetMissing(ctx context.Context, h string) {
+	if s.negative == nil {
+		return
+	}
+	if err := s.negative.Remove(ctx, h); err != nil {
+		log.WithField("infohash", h).WithError(err).Warn("failed to invalidate negative cache")
+	}
+}
+
+var _ NegativeCache = (*MemoryNegativeCache)(nil)
+var _ NegativeCache = (*RedisNegativeCache)(nil)
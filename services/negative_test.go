@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// countingProvider counts Pulls reaching the wrapped fakeProvider.
+type countingProvider struct {
+	*fakeProvider
+	pulls atomic.Int32
+}
+
+func (c *countingProvider) Pull(ctx context.Context, h string) ([]byte, error) {
+	c.pulls.Add(1)
+	return c.fakeProvider.Pull(ctx, h)
+}
+
+func TestStoreNegativeCacheSkipsTiersUntilPush(t *testing.T) {
+	s3 := &countingProvider{fakeProvider: newFakeProvider("s3", true)}
+	store := NewStore([]StoreProvider{s3}, &StoreConfig{NegativeCache: NewMemoryNegativeCache(time.Minute)})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := store.Pull(ctx, "h"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("err = %v, want ErrNotFound", err)
+		}
+	}
+	if n := s3.pulls.Load(); n != 1 {
+		t.Fatalf("pulls = %d, want 1", n)
+	}
+	if _, err := store.Check(ctx, "h"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+
+	if _, err := store.Push(ctx, "h", []byte("t")); err != nil {
+		t.Fatal(err)
+	}
+	torrent, err := store.Pull(ctx, "h")
+	if err != nil || string(torrent) != "t" {
+		t.Fatalf("torrent = %q, err = %v; push must invalidate the negative cache", torrent, err)
+	}
+}
+
+func TestStoreNegativeCacheIgnoresUnconfirmedMiss(t *testing.T) {
+	broken := &slowProvider{fakeProvider: newFakeProvider("redis", true)}
+	neg := NewMemoryNegativeCache(time.Minute)
+	store := NewStore([]StoreProvider{broken, newFakeProvider("s3", true)}, &StoreConfig{
+		Guard:         &GuardConfig{Timeouts: map[string]time.Duration{"*": 10 * time.Millisecond}},
+		NegativeCache: neg,
+	})
+	if _, err := store.Pull(context.Background(), "h"); err == nil {
+		t.Fatal("expected error")
+	}
+	if ok, _, _ := neg.Has(context.Background(), "h"); ok {
+		t.Fatal("a miss behind a failing tier must not be cached")
+	}
+}
+
+func TestMemoryNegativeCacheExpires(t *testing.T) {
+	neg := NewMemoryNegativeCache(10 * time.Millisecond)
+	ctx := context.Background()
+	_ = neg.Add(ctx, "h", 0)
+	if ok, _, _ := neg.Has(ctx, "h"); !ok {
+		t.Fatal("expected entry")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, _, _ := neg.Has(ctx, "h"); ok {
+		t.Fatal("entry must expire")
+	}
+}
+
+// gatedProvider answers a Pull with the outcome of the lookup as it was on
+// entry, but only once released.
+type gatedProvider struct {
+	*fakeProvider
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedProvider) Pull(ctx context.Context, h string) ([]byte, error) {
+	torrent, err := g.fakeProvider.Pull(ctx, h)
+	close(g.entered)
+	<-g.release
+	return torrent, err
+}
+
+func TestStoreNegativeCacheSkipsMissRacingPush(t *testing.T) {
+	p := &gatedProvider{
+		fakeProvider: newFakeProvider("s3", true),
+		entered:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	neg := NewMemoryNegativeCache(time.Minute)
+	store := NewStore([]StoreProvider{p}, &StoreConfig{NegativeCache: neg})
+	ctx := context.Background()
+
+	done := make(chan error)
+	go func() {
+		_, err := store.Pull(ctx, "h")
+		done <- err
+	}()
+	<-p.entered
+	if _, err := store.Push(ctx, "h", []byte("t")); err != nil {
+		t.Fatal(err)
+	}
+	close(p.release)
+	if err := <-done; !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want the stale ErrNotFound", err)
+	}
+	if ok, _, _ := neg.Has(ctx, "h"); ok {
+		t.Fatal("a miss racing a push must not be cached")
+	}
+}
+
+func TestMemoryNegativeCacheSkipsStaleGeneration(t *testing.T) {
+	neg := NewMemoryNegativeCache(time.Minute)
+	ctx := context.Background()
+	_, gen, _ := neg.Has(ctx, "h")
+	_ = neg.Remove(ctx, "h")
+	_ = neg.Add(ctx, "h", gen)
+	if ok, _, _ := neg.Has(ctx, "h"); ok {
+		t.Fatal("an add older than the last remove must be skipped")
+	}
+	_, gen, _ = neg.Has(ctx, "h")
+	_ = neg.Add(ctx, "h", gen)
+	if ok, _, _ := neg.Has(ctx, "h"); !ok {
+		t.Fatal("expected entry")
+	}
+}
@@ -2,16 +2,25 @@ package providers
 
 import (
 	"context"
-	"github.com/pkg/errors"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
 	badger "github.com/dgraph-io/badger/v3"
 	"github.com/urfave/cli"
 	ss "github.com/webtor-io/torrent-store/services"
 )
 
 const (
-	BadgerExpireFlag = "badger-expire"
+	BadgerExpireFlag         = "badger-expire"
+	BadgerDirFlag            = "badger-dir"
+	BadgerInMemoryFlag       = "badger-in-memory"
+	BadgerSyncWritesFlag     = "badger-sync-writes"
+	BadgerValueLogSizeFlag   = "badger-value-log-size"
+	BadgerGCIntervalFlag     = "badger-gc-interval"
+	BadgerGCDiscardRatioFlag = "badger-gc-discard-ratio"
 )
 
 func RegisterBadgerFlags(f []cli.Flag) []cli.Flag {
@@ -22,37 +31,117 @@ func RegisterBadgerFlags(f []cli.Flag) []cli.Flag {
 			Value:  3600,
 			EnvVar: "BADGER_EXPIRE",
 		},
+		cli.StringFlag{
+			Name:   BadgerDirFlag,
+			Usage:  "badger data dir",
+			Value:  "/tmp/badger",
+			EnvVar: "BADGER_DIR",
+		},
+		cli.BoolFlag{
+			Name:   BadgerInMemoryFlag,
+			Usage:  "run badger fully in memory (badger-dir is ignored)",
+			EnvVar: "BADGER_IN_MEMORY",
+		},
+		cli.BoolFlag{
+			Name:   BadgerSyncWritesFlag,
+			Usage:  "fsync every badger write",
+			EnvVar: "BADGER_SYNC_WRITES",
+		},
+		cli.IntFlag{
+			Name:   BadgerValueLogSizeFlag,
+			Usage:  "badger value log file size (MB)",
+			Value:  1024,
+			EnvVar: "BADGER_VALUE_LOG_SIZE",
+		},
+		cli.IntFlag{
+			Name:   BadgerGCIntervalFlag,
+			Usage:  "badger value log gc interval (sec), 0 disables gc",
+			Value:  300,
+			EnvVar: "BADGER_GC_INTERVAL",
+		},
+		cli.Float64Flag{
+			Name:   BadgerGCDiscardRatioFlag,
+			Usage:  "badger value log gc discard ratio",
+			Value:  0.7,
+			EnvVar: "BADGER_GC_DISCARD_RATIO",
+		},
 	)
 }
 
 type Badger struct {
-	exp time.Duration
-	db  *badger.DB
+	exp    time.Duration
+	db     *badger.DB
+	closeC chan struct{}
+	wg     sync.WaitGroup
 }
 
 func NewBadger(c *cli.Context) (*Badger, error) {
-	opt := badger.DefaultOptions("/tmp/badger")
+	inMemory := c.Bool(BadgerInMemoryFlag)
+	dir := c.String(BadgerDirFlag)
+	if inMemory {
+		dir = ""
+	}
+	opt := badger.DefaultOptions(dir).
+		WithInMemory(inMemory).
+		WithSyncWrites(c.Bool(BadgerSyncWritesFlag)).
+		WithValueLogFileSize(int64(c.Int(BadgerValueLogSizeFlag)) * 1024 * 1024)
 	db, err := badger.Open(opt)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open badger db")
 	}
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
-		for range ticker.C {
-			if err := db.RunValueLogGC(0.7); err != nil {
+	s := &Badger{
+		exp:    time.Duration(c.Int(BadgerExpireFlag)) * time.Second,
+		db:     db,
+		closeC: make(chan struct{}),
+	}
+	interval := time.Duration(c.Int(BadgerGCIntervalFlag)) * time.Second
+	// Value log GC isn't supported (nor needed) in in-memory mode.
+	if interval > 0 && !inMemory {
+		s.wg.Add(1)
+		go s.gcLoop(interval, c.Float64(BadgerGCDiscardRatioFlag))
+	}
+	return s, nil
+}
+
+// gcLoop runs value log GC every interval until Close. Each round repeats
+// RunValueLogGC while it keeps rewriting files, as recommended by Badger.
+// ErrNoRewrite just means there was nothing worth reclaiming this round and
+// must not stop the loop, otherwise the value log grows unbounded for the
+// rest of the pod's life.
+func (s *Badger) gcLoop(interval time.Duration, ratio float64) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.closeC:
+			return
+		}
+		t := time.Now()
+		rewrites := 0
+		var err error
+		for {
+			if err = s.db.RunValueLogGC(ratio); err != nil {
+				break
+			}
+			rewrites++
+			select {
+			case <-s.closeC:
 				return
+			default:
 			}
 		}
-	}()
-	return &Badger{
-		exp: time.Duration(c.Int(BadgerExpireFlag)) * time.Second,
-		db:  db,
-	}, nil
+		if !errors.Is(err, badger.ErrNoRewrite) && !errors.Is(err, badger.ErrRejected) {
+			log.WithError(err).WithField("duration", time.Since(t)).Warn("badger value log gc failed")
+			continue
+		}
+		log.WithField("rewrites", rewrites).WithField("duration", time.Since(t)).Info("badger value log gc")
+	}
 }
 
 func (s *Badger) Name() string {
-	return "badger"
+	return BadgerName
 }
 
 func (s *Badger) Touch(_ context.Context, h string) (ok bool, err error) {
@@ -105,6 +194,32 @@ func (s *Badger) Pull(_ context.Context, h string) (torrent []byte, err error) {
 	return
 }
 
+func (s *Badger) Exists(_ context.Context, h string) (ok bool, err error) {
+	err = s.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(h))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ss.ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete only needs to drop the torrent key: Badger never stores manifests
+// (see PushManifest below).
+func (s *Badger) Delete(_ context.Context, h string) (ok bool, err error) {
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(h))
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Badger intentionally opts out of manifest caching. The extra read/write
 // volume from manifests on top of the torrent workload tripped a nil-pointer
 // race inside Badger v3's memtable handling under load (a torrent-store pod
@@ -119,7 +234,11 @@ func (s *Badger) PullManifest(_ context.Context, _ string) (manifest []byte, err
 	return nil, ss.ErrNotFound
 }
 
+// Close stops the GC loop before closing the db, so a GC round never runs
+// against a closed value log.
 func (s *Badger) Close() {
+	close(s.closeC)
+	s.wg.Wait()
 	_ = s.db.Close()
 }
 
@@ -0,0 +1,40 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	ss "github.com/webtor-io/torrent-store/services"
+)
+
+func TestBadgerInMemoryRoundTrip(t *testing.T) {
+	b, err := NewBadger(newTestContext(t, "--badger-in-memory"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	ctx := context.Background()
+	if _, err := b.Push(ctx, "h", []byte("t")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Exists(ctx, "h"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Delete(ctx, "h"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Pull(ctx, "h"); !errors.Is(err, ss.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestBadgerCloseStopsGCLoop(t *testing.T) {
+	b, err := NewBadger(newTestContext(t, "--badger-dir", t.TempDir(), "--badger-gc-interval", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Close must return (not hang on the GC goroutine) and leave no GC
+	// round running against the closed db.
+	b.Close()
+}
@@ -0,0 +1,107 @@
+package providers
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+const (
+	ProvidersFlag = "providers"
+)
+
+const (
+	MemoryName = "memory"
+	BadgerName = "badger"
+	RedisName  = "redis"
+	FSName     = "fs"
+	S3Name     = "s3"
+)
+
+func RegisterProvidersFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.StringFlag{
+			Name:   ProvidersFlag,
+			Usage:  "comma-separated provider tiers in lookup order (memory, badger, redis, fs, s3); overrides use-* flags",
+			Value:  "",
+			EnvVar: "PROVIDERS",
+		},
+	)
+}
+
+// ParseProviders returns the provider names in tier order (fastest first).
+// With --providers set, the list is taken verbatim, so any tier (Badger
+// included) can be left out. Without it the legacy chain is kept: memory if
+// enabled, Badger always, then Redis, FS and S3 behind their use-* flags.
+// Every resulting name is validated, so a misconfigured chain fails at
+// startup rather than on the first request.
+func ParseProviders(c *cli.Context) ([]string, error) {
+	raw := strings.TrimSpace(c.String(ProvidersFlag))
+	var names []string
+	if raw == "" {
+		if c.Bool(MemoryUseFlag) {
+			names = append(names, MemoryName)
+		}
+		names = append(names, BadgerName)
+		if c.Bool(RedisUseFlag) {
+			names = append(names, RedisName)
+		}
+		if c.Bool(FSUseFlag) {
+			names = append(names, FSName)
+		}
+		if c.Bool(S3UseFlag) {
+			names = append(names, S3Name)
+		}
+	} else {
+		seen := map[string]struct{}{}
+		for _, part := range strings.Split(raw, ",") {
+			name := strings.ToLower(strings.TrimSpace(part))
+			if name == "" {
+				continue
+			}
+			if _, dup := seen[name]; dup {
+				return nil, errors.Errorf("provider %q listed twice in --%v", name, ProvidersFlag)
+			}
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return nil, errors.Errorf("--%v lists no providers", ProvidersFlag)
+		}
+	}
+	for _, name := range names {
+		if err := validateProvider(c, name); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// validateProvider checks that name is known and that the settings it can't
+// work without are present.
+func validateProvider(c *cli.Context, name string) error {
+	switch name {
+	case MemoryName:
+		if c.Int(MemoryMaxSizeFlag) <= 0 {
+			return errors.Errorf("provider %v requires --%v > 0", name, MemoryMaxSizeFlag)
+		}
+	case BadgerName:
+		if c.String(BadgerDirFlag) == "" && !c.Bool(BadgerInMemoryFlag) {
+			return errors.Errorf("provider %v requires --%v or --%v", name, BadgerDirFlag, BadgerInMemoryFlag)
+		}
+	case RedisName:
+		// The redis client flags all have working defaults.
+	case FSName:
+		if c.String(FSDirFlag) == "" {
+			return errors.Errorf("provider %v requires --%v", name, FSDirFlag)
+		}
+	case S3Name:
+		if c.String(AWSBucketFlag) == "" {
+			return errors.Errorf("provider %v requires --%v", name, AWSBucketFlag)
+		}
+	default:
+		return errors.Errorf("unknown provider %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/urfave/cli"
+	cs "github.com/webtor-io/common-services"
+)
+
+func newTestContext(t *testing.T, args ...string) *cli.Context {
+	t.Helper()
+	var flags []cli.Flag
+	flags = cs.RegisterRedisClientFlags(flags)
+	flags = RegisterProvidersFlags(flags)
+	flags = RegisterMemoryFlags(flags)
+	flags = RegisterBadgerFlags(flags)
+	flags = RegisterRedisFlags(flags)
+	flags = RegisterFSFlags(flags)
+	flags = RegisterS3Flags(flags)
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range flags {
+		f.Apply(set)
+	}
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestParseProvidersLegacyChain(t *testing.T) {
+	names, err := ParseProviders(newTestContext(t, "--use-redis", "--use-s3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{BadgerName, RedisName, S3Name}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+}
+
+func TestParseProvidersExplicitOrderWithoutBadger(t *testing.T) {
+	names, err := ParseProviders(newTestContext(t, "--providers", " memory, S3 ,redis"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{MemoryName, S3Name, RedisName}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+}
+
+func TestParseProvidersRejectsInvalidChains(t *testing.T) {
+	for _, args := range [][]string{
+		{"--providers", "redis,redis"},
+		{"--providers", "memcached"},
+		{"--providers", ","},
+		{"--providers", "s3", "--aws-bucket", ""},
+	} {
+		if _, err := ParseProviders(newTestContext(t, args...)); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	ss "github.com/webtor-io/torrent-store/services"
+)
+
+const (
+	CompressFlag        = "compress"
+	CompressLevelFlag   = "compress-level"
+	CompressMinSizeFlag = "compress-min-size"
+)
+
+// compressMagic prefixes every compressed value. Neither a bencoded torrent
+// (always starting with 'd') nor a protobuf manifest can start with a zero
+// byte, so values written before compression was enabled still read as is.
+// The last byte is the format version.
+var compressMagic = []byte{0x00, 't', 's', 'z', 0x01}
+
+var (
+	compressRatio = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "torrent_store_compression_ratio",
+		Help:    "Compressed to raw size ratio of written values, labelled by provider.",
+		Buckets: []float64{0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+	}, []string{"provider"})
+	compressSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "torrent_store_compression_seconds",
+		Help:    "Time spent compressing and decompressing values, labelled by provider and op (compress, decompress).",
+		Buckets: prometheus.ExponentialBuckets(0.00005, 4, 10),
+	}, []string{"provider", "op"})
+)
+
+func RegisterCompressFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.StringFlag{
+			Name:   CompressFlag,
+			Usage:  "comma-separated provider tiers storing values zstd-compressed (memory, badger, redis, fs, s3)",
+			Value:  "",
+			EnvVar: "COMPRESS",
+		},
+		cli.StringFlag{
+			Name:   CompressLevelFlag,
+			Usage:  "zstd compression level (fastest, default, better, best)",
+			Value:  "default",
+			EnvVar: "COMPRESS_LEVEL",
+		},
+		cli.IntFlag{
+			Name:   CompressMinSizeFlag,
+			Usage:  "values smaller than this are stored uncompressed (bytes)",
+			Value:  512,
+			EnvVar: "COMPRESS_MIN_SIZE",
+		},
+	)
+}
+
+// Compressor holds the shared zstd encoder and decoder and the set of tiers
+// to compress. Both EncodeAll and DecodeAll are safe for concurrent use.
+type Compressor struct {
+	tiers   map[string]struct{}
+	minSize int
+	enc     *zstd.Encoder
+	dec     *zstd.Decoder
+}
+
+func NewCompressor(c *cli.Context) (*Compressor, error) {
+	tiers := map[string]struct{}{}
+	for _, part := range strings.Split(c.String(CompressFlag), ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		switch name {
+		case MemoryName, BadgerName, RedisName, FSName, S3Name:
+		default:
+			return nil, errors.Errorf("unknown provider %q in --%v", name, CompressFlag)
+		}
+		tiers[name] = struct{}{}
+	}
+	if len(tiers) == 0 {
+		return nil, nil
+	}
+	ok, level := zstd.EncoderLevelFromString(c.String(CompressLevelFlag))
+	if !ok {
+		return nil, errors.Errorf("unknown --%v %q", CompressLevelFlag, c.String(CompressLevelFlag))
+	}
+	return newCompressor(tiers, level, c.Int(CompressMinSizeFlag))
+}
+
+func newCompressor(tiers map[string]struct{}, level zstd.EncoderLevel, minSize int) (*Compressor, error) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init zstd encoder")
+	}
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init zstd decoder")
+	}
+	return &Compressor{
+		tiers:   tiers,
+		minSize: minSize,
+		enc:     enc,
+		dec:     dec,
+	}, nil
+}
+
+// Wrap returns p storing compressed values if its tier is configured for
+// compression, and p itself otherwise. A nil Compressor wraps nothing.
+func (s *Compressor) Wrap(p ss.StoreProvider) ss.StoreProvider {
+	if s == nil {
+		return p
+	}
+	if _, ok := s.tiers[p.Name()]; !ok {
+		return p
+	}
+	log.WithField("provider", p.Name()).Info("use compression for provider")
+	return &Compressed{StoreProvider: p, c: s}
+}
+
+func (s *Compressor) Close() {
+	if s == nil {
+		return
+	}
+	_ = s.enc.Close()
+	s.dec.Close()
+}
+
+func (s *Compressor) compress(name string, raw []byte) []byte {
+	if len(raw) < s.minSize {
+		return raw
+	}
+	t := time.Now()
+	out := s.enc.EncodeAll(raw, append([]byte(nil), compressMagic...))
+	compressSeconds.WithLabelValues(name, "compress").Observe(time.Since(t).Seconds())
+	if len(out) >= len(raw) {
+		// Incompressible: storing it raw is smaller and cheaper to read.
+		return raw
+	}
+	compressRatio.WithLabelValues(name).Observe(float64(len(out)) / float64(len(raw)))
+	return out
+}
+
+func (s *Compressor) decompress(name string, val []byte) ([]byte, error) {
+	if !bytes.HasPrefix(val, compressMagic) {
+		return val, nil
+	}
+	t := time.Now()
+	out, err := s.dec.DecodeAll(val[len(compressMagic):], nil)
+	compressSeconds.WithLabelValues(name, "decompress").Observe(time.Since(t).Seconds())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress")
+	}
+	return out, nil
+}
+
+// Compressed is a StoreProvider decorator keeping torrents and manifests
+// zstd-compressed in the wrapped tier. Reads accept both compressed and
+// plain values, so compression can be turned on for a tier with data in it.
+type Compressed struct {
+	ss.StoreProvider
+	c *Compressor
+}
+
+func (s *Compressed) Push(ctx context.Context, h string, torrent []byte) (ok bool, err error) {
+	return s.StoreProvider.Push(ctx, h, s.c.compress(s.Name(), torrent))
+}
+
+func (s *Compressed) Pull(ctx context.Context, h string) (torrent []byte, err error) {
+	torrent, err = s.StoreProvider.Pull(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	return s.c.decompress(s.Name(), torrent)
+}
+
+func (s *Compressed) PushManifest(ctx context.Context, h string, manifest []byte) (ok bool, err error) {
+	return s.StoreProvider.PushManifest(ctx, h, s.c.compress(s.Name(), manifest))
+}
+
+func (s *Compressed) PullManifest(ctx context.Context, h string) (manifest []byte, err error) {
+	manifest, err = s.StoreProvider.PullManifest(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	return s.c.decompress(s.Name(), manifest)
+}
+
+// PushVerdict stores verdicts as they are: they are a few dozen bytes of
+// JSON, below any sensible --compress-min-size.
+func (s *Compressed) PushVerdict(ctx context.Context, h string, verdict []byte) (ok bool, err error) {
+	vp, ok := s.StoreProvider.(ss.VerdictProvider)
+	if !ok {
+		return true, nil
+	}
+	return vp.PushVerdict(ctx, h, verdict)
+}
+
+func (s *Compressed) PullVerdict(ctx context.Context, h string) (verdict []byte, err error) {
+	vp, ok := s.StoreProvider.(ss.VerdictProvider)
+	if !ok {
+		return nil, ss.ErrNotFound
+	}
+	return vp.PullVerdict(ctx, h)
+}
+
+// PullStream decodes on the fly when the wrapped tier can stream, peeking at
+// the header to tell compressed values from plain ones.
+func (s *Compressed) PullStream(ctx context.Context, h string) (r io.ReadCloser, err error) {
+	sp, ok := s.StoreProvider.(ss.StreamPuller)
+	if !ok {
+		torrent, err := s.Pull(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(torrent)), nil
+	}
+	body, err := sp.PullStream(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(body)
+	head, _ := br.Peek(len(compressMagic))
+	if !bytes.Equal(head, compressMagic) {
+		return &compressedStream{Reader: br, body: body}, nil
+	}
+	_, _ = br.Discard(len(compressMagic))
+	dec, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		_ = body.Close()
+		return nil, errors.Wrap(err, "failed to init zstd decoder")
+	}
+	return &compressedStream{Reader: dec, body: body, dec: dec}, nil
+}
+
+func (s *Compressed) Durable() bool {
+	d, ok := s.StoreProvider.(ss.DurableProvider)
+	return ok && d.Durable()
+}
+
+type compressedStream struct {
+	io.Reader
+	body io.Closer
+	dec  *zstd.Decoder
+}
+
+func (s *compressedStream) Close() error {
+	if s.dec != nil {
+		s.dec.Close()
+	}
+	return s.body.Close()
+}
+
+var _ ss.StoreProvider = (*Compressed)(nil)
+var _ ss.StreamPuller = (*Compressed)(nil)
+var _ ss.DurableProvider = (*Compressed)(nil)
+var _ ss.VerdictProvider = (*Compressed)(nil)
@@ -0,0 +1,128 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	ss "github.com/webtor-io/torrent-store/services"
+)
+
+func newTestCompressor(t *testing.T, tiers ...string) *Compressor {
+	set := map[string]struct{}{}
+	for _, name := range tiers {
+		set[name] = struct{}{}
+	}
+	c, err := newCompressor(set, zstd.SpeedDefault, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// packTorrent mimics a large pack: thousands of similar file paths.
+func packTorrent() []byte {
+	var b bytes.Buffer
+	b.WriteString("d4:info")
+	for i := 0; i < 2000; i++ {
+		b.WriteString("l6:Season5:Disc 12:Episode.mkve")
+	}
+	b.WriteString("e")
+	return b.Bytes()
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	mem := newMemory(64*1024*1024, time.Minute)
+	p := newTestCompressor(t, MemoryName).Wrap(mem)
+	ctx := context.Background()
+	torrent := packTorrent()
+
+	if _, err := p.Push(ctx, "h", torrent); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := mem.Pull(ctx, "h")
+	if !bytes.HasPrefix(stored, compressMagic) || len(stored) >= len(torrent)/10 {
+		t.Fatalf("stored %d bytes of %d, want compressed with header", len(stored), len(torrent))
+	}
+	got, err := p.Pull(ctx, "h")
+	if err != nil || !bytes.Equal(got, torrent) {
+		t.Fatalf("round trip mismatch, err = %v", err)
+	}
+
+	if _, err = p.PushManifest(ctx, "h", torrent); err != nil {
+		t.Fatal(err)
+	}
+	got, err = p.PullManifest(ctx, "h")
+	if err != nil || !bytes.Equal(got, torrent) {
+		t.Fatalf("manifest round trip mismatch, err = %v", err)
+	}
+}
+
+func TestCompressedReadsLegacyAndSmallValues(t *testing.T) {
+	mem := newMemory(1024*1024, time.Minute)
+	p := newTestCompressor(t, MemoryName).Wrap(mem)
+	ctx := context.Background()
+
+	// Written before compression was enabled.
+	legacy := packTorrent()
+	_, _ = mem.Push(ctx, "old", legacy)
+	if got, err := p.Pull(ctx, "old"); err != nil || !bytes.Equal(got, legacy) {
+		t.Fatalf("legacy read mismatch, err = %v", err)
+	}
+
+	small := []byte("d4:infod4:name1:xee")
+	_, _ = p.Push(ctx, "small", small)
+	if stored, _ := mem.Pull(ctx, "small"); !bytes.Equal(stored, small) {
+		t.Fatal("values below min size must be stored raw")
+	}
+	if _, err := p.Pull(ctx, "missing"); err != ss.ErrNotFound {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCompressedWrapOnlyConfiguredTiers(t *testing.T) {
+	c := newTestCompressor(t, S3Name)
+	mem := newMemory(1024, time.Minute)
+	if c.Wrap(mem) != ss.StoreProvider(mem) {
+		t.Fatal("unconfigured tier must not be wrapped")
+	}
+	var nilC *Compressor
+	if nilC.Wrap(mem) != ss.StoreProvider(mem) {
+		t.Fatal("nil compressor must not wrap")
+	}
+}
+
+func TestCompressedPullStream(t *testing.T) {
+	fs := newTestFS(t, 0)
+	p := newTestCompressor(t, FSName).Wrap(fs).(*Compressed)
+	ctx := context.Background()
+	const h = "abcdef0123456789"
+	torrent := packTorrent()
+	_, _ = p.Push(ctx, h, torrent)
+
+	r, err := p.PullStream(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil || !bytes.Equal(got, torrent) {
+		t.Fatalf("stream mismatch, err = %v", err)
+	}
+
+	// A plain value streams through untouched.
+	_, _ = fs.Push(ctx, h, []byte("d4:infoe"))
+	r, err = p.PullStream(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = io.ReadAll(r)
+	_ = r.Close()
+	if string(got) != "d4:infoe" {
+		t.Fatalf("got %q", got)
+	}
+}
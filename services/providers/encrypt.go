@@ -0,0 +1,403 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	ss "github.com/webtor-io/torrent-store/services"
+)
+
+const (
+	EncryptFlag          = "encrypt"
+	EncryptKeysFlag      = "encrypt-keys"
+	EncryptActiveKeyFlag = "encrypt-active-key"
+	// encryptDataKeySize is the size of the per-value AES-256 data key.
+	encryptDataKeySize = 32
+)
+
+// encryptMagic prefixes every encrypted value, see compressMagic for why a
+// leading zero byte is safe. The last byte is the format version.
+var encryptMagic = []byte{0x00, 't', 's', 'e', 0x01}
+
+var (
+	ErrUnknownKey = errors.New("store: value encrypted with unknown key")
+)
+
+func RegisterEncryptFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.StringFlag{
+			Name:   EncryptFlag,
+			Usage:  "comma-separated provider tiers storing values encrypted (redis, s3)",
+			Value:  "",
+			EnvVar: "ENCRYPT",
+		},
+		cli.StringFlag{
+			Name:   EncryptKeysFlag,
+			Usage:  "comma-separated master keys as id:base64 (16, 24 or 32 bytes); keep retired keys listed until re-encrypted",
+			Value:  "",
+			EnvVar: "ENCRYPT_KEYS",
+		},
+		cli.StringFlag{
+			Name:   EncryptActiveKeyFlag,
+			Usage:  "id of the master key used for new writes (default: first of --encrypt-keys)",
+			Value:  "",
+			EnvVar: "ENCRYPT_ACTIVE_KEY",
+		},
+	)
+}
+
+// Encryptor does envelope encryption: every value is sealed with a fresh
+// random data key using AES-GCM, and the data key is sealed with the active
+// master key. The master key id travels with the value, so any listed key
+// can read it and keys can be rotated without rewriting everything at once.
+//
+// Stored layout: magic | id length (1 byte) | id | sealed data key | nonce |
+// sealed value. The value is bound to its infoHash and kind through the AAD,
+// so a blob copied under another infoHash or kind fails to decrypt.
+type Encryptor struct {
+	tiers  map[string]struct{}
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+func NewEncryptor(c *cli.Context) (*Encryptor, error) {
+	tiers := map[string]struct{}{}
+	for _, part := range strings.Split(c.String(EncryptFlag), ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		if name != RedisName && name != S3Name {
+			return nil, errors.Errorf("provider %q in --%v does not support encryption", name, EncryptFlag)
+		}
+		tiers[name] = struct{}{}
+	}
+	if len(tiers) == 0 {
+		return nil, nil
+	}
+	keys, first, err := parseEncryptKeys(c.String(EncryptKeysFlag))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse --%v", EncryptKeysFlag)
+	}
+	active := c.String(EncryptActiveKeyFlag)
+	if active == "" {
+		active = first
+	}
+	return newEncryptor(tiers, keys, active)
+}
+
+func newEncryptor(tiers map[string]struct{}, keys map[string][]byte, active string) (*Encryptor, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, errors.Errorf("active key %q is not among the configured keys", active)
+	}
+	aeads := map[string]cipher.AEAD{}
+	for id, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad key %q", id)
+		}
+		aeads[id] = aead
+	}
+	return &Encryptor{tiers: tiers, keys: aeads, active: active}, nil
+}
+
+// parseEncryptKeys reads "id:base64" entries, returning the keys and the
+// first id.
+func parseEncryptKeys(raw string) (map[string][]byte, string, error) {
+	keys := map[string][]byte{}
+	first := ""
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 || kv[0] == "" || len(kv[0]) > 255 {
+			return nil, "", errors.New("malformed entry, want id:base64")
+		}
+		if _, dup := keys[kv[0]]; dup {
+			return nil, "", errors.Errorf("key %q listed twice", kv[0])
+		}
+		key, err := base64.StdEncoding.DecodeString(kv[1])
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to decode key %q", kv[0])
+		}
+		keys[kv[0]] = key
+		if first == "" {
+			first = kv[0]
+		}
+	}
+	if len(keys) == 0 {
+		return nil, "", errors.New("no keys configured")
+	}
+	return keys, first, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
+
+// Tiers returns the names of the encrypted tiers in chain order.
+func (s *Encryptor) Tiers() []string {
+	var names []string
+	for _, name := range []string{RedisName, S3Name} {
+		if _, ok := s.tiers[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Wrap returns p storing encrypted values if its tier is configured for
+// encryption, and p itself otherwise. A nil Encryptor wraps nothing.
+func (s *Encryptor) Wrap(p ss.StoreProvider) ss.StoreProvider {
+	if s == nil {
+		return p
+	}
+	if _, ok := s.tiers[p.Name()]; !ok {
+		return p
+	}
+	log.WithField("provider", p.Name()).WithField("key", s.active).Info("use encryption for provider")
+	return &Encrypted{StoreProvider: p, e: s}
+}
+
+func (s *Encryptor) seal(plain []byte, aad string) ([]byte, error) {
+	kek := s.keys[s.active]
+	dek := make([]byte, encryptDataKeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, errors.Wrap(err, "failed to generate data key")
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte(nil), encryptMagic...)
+	out = append(out, byte(len(s.active)))
+	out = append(out, s.active...)
+	kNonce := make([]byte, kek.NonceSize())
+	if _, err = rand.Read(kNonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	out = append(out, kNonce...)
+	out = kek.Seal(out, kNonce, dek, []byte(s.active))
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plain, []byte(aad)), nil
+}
+
+// keyID returns the master key id of an encrypted value, or false for a
+// plain one.
+func keyID(val []byte) (string, bool) {
+	if !bytes.HasPrefix(val, encryptMagic) || len(val) <= len(encryptMagic) {
+		return "", false
+	}
+	n := int(val[len(encryptMagic)])
+	start := len(encryptMagic) + 1
+	if len(val) < start+n {
+		return "", false
+	}
+	return string(val[start : start+n]), true
+}
+
+// open decrypts val. Values without the header were written before
+// encryption was enabled and are returned as is.
+func (s *Encryptor) open(val []byte, aad string) ([]byte, error) {
+	id, ok := keyID(val)
+	if !ok {
+		if bytes.HasPrefix(val, encryptMagic) {
+			return nil, errors.New("failed to decrypt: truncated header")
+		}
+		return val, nil
+	}
+	kek, ok := s.keys[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownKey, "key=%v", id)
+	}
+	rest := val[len(encryptMagic)+1+len(id):]
+	kSize := kek.NonceSize() + encryptDataKeySize + kek.Overhead()
+	if len(rest) < kSize {
+		return nil, errors.New("failed to decrypt: truncated data key")
+	}
+	dek, err := kek.Open(nil, rest[:kek.NonceSize()], rest[kek.NonceSize():kSize], []byte(id))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unseal data key")
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[kSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("failed to decrypt: truncated value")
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(aad))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt")
+	}
+	return plain, nil
+}
+
+func torrentAAD(h string) string {
+	return "t:" + h
+}
+
+func manifestAAD(h string) string {
+	return "m:" + h
+}
+
+func verdictAAD(h string) string {
+	return "v:" + h
+}
+
+// Encrypted is a StoreProvider decorator keeping torrents, manifests and
+// verdicts encrypted in the wrapped tier. Plain values still read, so encryption can
+// be turned on for a tier with data in it and Rotate catches them up.
+type Encrypted struct {
+	ss.StoreProvider
+	e *Encryptor
+}
+
+func (s *Encrypted) Push(ctx context.Context, h string, torrent []byte) (ok bool, err error) {
+	val, err := s.e.seal(torrent, torrentAAD(h))
+	if err != nil {
+		return false, err
+	}
+	return s.StoreProvider.Push(ctx, h, val)
+}
+
+func (s *Encrypted) Pull(ctx context.Context, h string) (torrent []byte, err error) {
+	val, err := s.StoreProvider.Pull(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	return s.e.open(val, torrentAAD(h))
+}
+
+func (s *Encrypted) PushManifest(ctx context.Context, h string, manifest []byte) (ok bool, err error) {
+	val, err := s.e.seal(manifest, manifestAAD(h))
+	if err != nil {
+		return false, err
+	}
+	return s.StoreProvider.PushManifest(ctx, h, val)
+}
+
+func (s *Encrypted) PullManifest(ctx context.Context, h string) (manifest []byte, err error) {
+	val, err := s.StoreProvider.PullManifest(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	return s.e.open(val, manifestAAD(h))
+}
+
+func (s *Encrypted) PushVerdict(ctx context.Context, h string, verdict []byte) (ok bool, err error) {
+	vp, ok := s.StoreProvider.(ss.VerdictProvider)
+	if !ok {
+		return true, nil
+	}
+	val, err := s.e.seal(verdict, verdictAAD(h))
+	if err != nil {
+		return false, err
+	}
+	return vp.PushVerdict(ctx, h, val)
+}
+
+func (s *Encrypted) PullVerdict(ctx context.Context, h string) (verdict []byte, err error) {
+	vp, ok := s.StoreProvider.(ss.VerdictProvider)
+	if !ok {
+		return nil, ss.ErrNotFound
+	}
+	val, err := vp.PullVerdict(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	return s.e.open(val, verdictAAD(h))
+}
+
+func (s *Encrypted) Durable() bool {
+	d, ok := s.StoreProvider.(ss.DurableProvider)
+	return ok && d.Durable()
+}
+
+// List passes through to the wrapped tier so maintenance jobs can walk it.
+func (s *Encrypted) List(ctx context.Context, fn func(h string) error) error {
+	l, ok := s.StoreProvider.(ss.Lister)
+	if !ok {
+		return errors.Errorf("provider %v can't list", s.Name())
+	}
+	return l.List(ctx, fn)
+}
+
+// Rotate rewrites the torrent, manifest and verdict of h under the active
+// key if they are plain or sealed with another key. Reports whether anything
+// was rewritten.
+func (s *Encrypted) Rotate(ctx context.Context, h string) (rotated bool, err error) {
+	val, err := s.StoreProvider.Pull(ctx, h)
+	if err == nil && s.stale(val) {
+		var torrent []byte
+		if torrent, err = s.e.open(val, torrentAAD(h)); err != nil {
+			return false, err
+		}
+		if _, err = s.Push(ctx, h, torrent); err != nil {
+			return false, err
+		}
+		rotated = true
+	} else if err != nil && !errors.Is(err, ss.ErrNotFound) {
+		return false, err
+	}
+	val, err = s.StoreProvider.PullManifest(ctx, h)
+	if err == nil && s.stale(val) {
+		var manifest []byte
+		if manifest, err = s.e.open(val, manifestAAD(h)); err != nil {
+			return rotated, err
+		}
+		if _, err = s.PushManifest(ctx, h, manifest); err != nil {
+			return rotated, err
+		}
+		rotated = true
+	} else if err != nil && !errors.Is(err, ss.ErrNotFound) {
+		return rotated, err
+	}
+	vp, ok := s.StoreProvider.(ss.VerdictProvider)
+	if !ok {
+		return rotated, nil
+	}
+	val, err = vp.PullVerdict(ctx, h)
+	if err == nil && s.stale(val) {
+		var verdict []byte
+		if verdict, err = s.e.open(val, verdictAAD(h)); err != nil {
+			return rotated, err
+		}
+		if _, err = s.PushVerdict(ctx, h, verdict); err != nil {
+			return rotated, err
+		}
+		rotated = true
+	} else if err != nil && !errors.Is(err, ss.ErrNotFound) {
+		return rotated, err
+	}
+	return rotated, nil
+}
+
+func (s *Encrypted) stale(val []byte) bool {
+	id, ok := keyID(val)
+	return !ok || id != s.e.active
+}
+
+var _ ss.StoreProvider = (*Encrypted)(nil)
+var _ ss.DurableProvider = (*Encrypted)(nil)
+var _ ss.Lister = (*Encrypted)(nil)
+var _ ss.VerdictProvider = (*Encrypted)(nil)
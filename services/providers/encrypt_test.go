@@ -0,0 +1,162 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	ss "github.com/webtor-io/torrent-store/services"
+)
+
+// listedMemory lets a Memory tier pose as the listable Redis tier.
+type listedMemory struct {
+	*Memory
+	hashes []string
+}
+
+func (l *listedMemory) Name() string { return RedisName }
+
+func (l *listedMemory) List(_ context.Context, fn func(h string) error) error {
+	for _, h := range l.hashes {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestEncryptor(t *testing.T, active string) *Encryptor {
+	keys := map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	}
+	e, err := newEncryptor(map[string]struct{}{RedisName: {}}, keys, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestParseEncryptKeys(t *testing.T) {
+	keys, first, err := parseEncryptKeys("old:AQIDBA==, new:BQYHCA==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != "old" || len(keys) != 2 || !bytes.Equal(keys["new"], []byte{5, 6, 7, 8}) {
+		t.Fatalf("keys = %v, first = %q", keys, first)
+	}
+	for _, raw := range []string{"", "nokey", "a:!!", "a:AQ==,a:AQ=="} {
+		if _, _, err := parseEncryptKeys(raw); err == nil {
+			t.Fatalf("%q: expected error", raw)
+		}
+	}
+	if _, err := newEncryptor(nil, map[string][]byte{"a": {1, 2, 3}}, "a"); err == nil {
+		t.Fatal("expected error for a key of invalid size")
+	}
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	inner := &listedMemory{Memory: newMemory(1024*1024, time.Minute)}
+	p := newTestEncryptor(t, "k1").Wrap(inner)
+	ctx := context.Background()
+	torrent := []byte("d4:infod4:name4:testee")
+
+	if _, err := p.Push(ctx, "h", torrent); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := inner.Pull(ctx, "h")
+	if id, ok := keyID(stored); !ok || id != "k1" || bytes.Contains(stored, []byte("name4:test")) {
+		t.Fatalf("stored value must be sealed with k1, got %q", stored)
+	}
+	got, err := p.Pull(ctx, "h")
+	if err != nil || !bytes.Equal(got, torrent) {
+		t.Fatalf("round trip mismatch, err = %v", err)
+	}
+
+	// A blob moved under another infoHash or kind must not decrypt.
+	_, _ = inner.Push(ctx, "other", stored)
+	if _, err = p.Pull(ctx, "other"); err == nil {
+		t.Fatal("expected AAD mismatch")
+	}
+	_, _ = inner.PushManifest(ctx, "h", stored)
+	if _, err = p.PullManifest(ctx, "h"); err == nil {
+		t.Fatal("expected AAD mismatch")
+	}
+	_, _ = inner.PushVerdict(ctx, "h", stored)
+	if _, err = p.(ss.VerdictProvider).PullVerdict(ctx, "h"); err == nil {
+		t.Fatal("expected AAD mismatch")
+	}
+
+	// Plain values written before encryption was enabled still read.
+	_, _ = inner.Push(ctx, "plain", torrent)
+	if got, err = p.Pull(ctx, "plain"); err != nil || !bytes.Equal(got, torrent) {
+		t.Fatalf("plain read mismatch, err = %v", err)
+	}
+}
+
+func TestEncryptedUnknownKey(t *testing.T) {
+	inner := &listedMemory{Memory: newMemory(1024*1024, time.Minute)}
+	ctx := context.Background()
+	_, _ = newTestEncryptor(t, "k2").Wrap(inner).Push(ctx, "h", []byte("d4:infoe"))
+
+	only, err := newEncryptor(map[string]struct{}{RedisName: {}}, map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = only.Wrap(inner).Pull(ctx, "h"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestEncryptedRotate(t *testing.T) {
+	inner := &listedMemory{Memory: newMemory(1024*1024, time.Minute), hashes: []string{"old", "plain", "fresh", "gone"}}
+	ctx := context.Background()
+	torrent := []byte("d4:infod4:name4:testee")
+	manifest := []byte{0x0a, 0x01, 'x'}
+	verdict := []byte(`{"version":"v1","found":false}`)
+
+	oldP := newTestEncryptor(t, "k1").Wrap(inner).(*Encrypted)
+	_, _ = oldP.Push(ctx, "old", torrent)
+	_, _ = oldP.PushManifest(ctx, "old", manifest)
+	_, _ = oldP.PushVerdict(ctx, "old", verdict)
+	_, _ = inner.Push(ctx, "plain", torrent)
+
+	p := newTestEncryptor(t, "k2").Wrap(inner).(*Encrypted)
+	_, _ = p.Push(ctx, "fresh", torrent)
+
+	rotated := map[string]bool{}
+	err := p.List(ctx, func(h string) error {
+		ok, err := p.Rotate(ctx, h)
+		rotated[h] = ok
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rotated["old"] || !rotated["plain"] || rotated["fresh"] || rotated["gone"] {
+		t.Fatalf("rotated = %v", rotated)
+	}
+	for _, h := range []string{"old", "plain", "fresh"} {
+		stored, _ := inner.Pull(ctx, h)
+		if id, _ := keyID(stored); id != "k2" {
+			t.Fatalf("%v: key = %q, want k2", h, id)
+		}
+		if got, err := p.Pull(ctx, h); err != nil || !bytes.Equal(got, torrent) {
+			t.Fatalf("%v: read mismatch, err = %v", h, err)
+		}
+	}
+	if got, err := p.PullManifest(ctx, "old"); err != nil || !bytes.Equal(got, manifest) {
+		t.Fatalf("manifest mismatch, err = %v", err)
+	}
+	stored, _ := inner.PullVerdict(ctx, "old")
+	if id, _ := keyID(stored); id != "k2" {
+		t.Fatalf("verdict key = %q, want k2", id)
+	}
+	if got, err := p.PullVerdict(ctx, "old"); err != nil || !bytes.Equal(got, verdict) {
+		t.Fatalf("verdict mismatch, err = %v", err)
+	}
+}
+
+var _ ss.Lister = (*listedMemory)(nil)
@@ -0,0 +1,330 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	ss "github.com/webtor-io/torrent-store/services"
+)
+
+const (
+	FSUseFlag           = "use-fs"
+	FSDirFlag           = "fs-dir"
+	FSExpireFlag        = "fs-expire"
+	FSSweepIntervalFlag = "fs-sweep-interval"
+)
+
+const (
+	fsTorrentExt  = ".torrent"
+	fsManifestExt = ".manifest"
+	fsTempMarker  = ".tmp-"
+	// fsTempMaxAge is how long an orphaned temp file (left by a crash
+	// between create and rename) survives before the expiry sweeper removes
+	// it. Files orphaned by a previous run are removed at startup.
+	fsTempMaxAge = time.Hour
+)
+
+func RegisterFSFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.BoolFlag{
+			Name:   FSUseFlag,
+			Usage:  "use local filesystem",
+			EnvVar: "USE_FS",
+		},
+		cli.StringFlag{
+			Name:   FSDirFlag,
+			Usage:  "local filesystem store directory",
+			Value:  "/data/torrent-store",
+			EnvVar: "FS_DIR",
+		},
+		cli.IntFlag{
+			Name:   FSExpireFlag,
+			Usage:  "local filesystem expire (sec), 0 keeps files forever",
+			Value:  0,
+			EnvVar: "FS_EXPIRE",
+		},
+		cli.IntFlag{
+			Name:   FSSweepIntervalFlag,
+			Usage:  "local filesystem expired files sweep interval (sec), only used with fs-expire",
+			Value:  600,
+			EnvVar: "FS_SWEEP_INTERVAL",
+		},
+	)
+}
+
+// FS stores torrents and manifests as plain files sharded by the first two
+// byte pairs of the infoHash (ab/cd/abcd….torrent) so no single directory
+// grows huge. Writes go to a temp file in the target directory and are
+// renamed into place, so readers never see a partial torrent. Expiry is
+// mtime based: Touch bumps mtime, reads treat stale files as missing and a
+// background sweeper deletes them. Without expiry nothing is swept, only temp
+// files left by a previous run are removed once at startup.
+type FS struct {
+	dir    string
+	exp    time.Duration
+	closeC chan struct{}
+}
+
+func NewFS(c *cli.Context) (*FS, error) {
+	dir := c.String(FSDirFlag)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create fs store dir %v", dir)
+	}
+	s := &FS{
+		dir:    dir,
+		exp:    time.Duration(c.Int(FSExpireFlag)) * time.Second,
+		closeC: make(chan struct{}),
+	}
+	go s.sweepTemp(time.Now())
+	interval := time.Duration(c.Int(FSSweepIntervalFlag)) * time.Second
+	if s.exp > 0 && interval > 0 {
+		go s.sweepLoop(interval)
+	}
+	return s, nil
+}
+
+func (s *FS) Durable() bool {
+	return true
+}
+
+func (s *FS) Name() string {
+	return FSName
+}
+
+// path maps an infoHash to its sharded file path. Anything that isn't a
+// plain alphanumeric hash is rejected so client input can never escape dir.
+func (s *FS) path(h string, ext string) (string, bool) {
+	if len(h) < 4 {
+		return "", false
+	}
+	for _, r := range h {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return "", false
+		}
+	}
+	return filepath.Join(s.dir, h[0:2], h[2:4], h+ext), true
+}
+
+func (s *FS) expired(mt time.Time) bool {
+	return s.exp > 0 && time.Since(mt) > s.exp
+}
+
+// stat returns ErrNotFound for missing or already expired files.
+func (s *FS) stat(h string, ext string) (string, error) {
+	p, ok := s.path(h, ext)
+	if !ok {
+		return "", ss.ErrNotFound
+	}
+	fi, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ss.ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+	if s.expired(fi.ModTime()) {
+		return "", ss.ErrNotFound
+	}
+	return p, nil
+}
+
+func (s *FS) write(h string, ext string, data []byte) error {
+	p, ok := s.path(h, ext)
+	if !ok {
+		return errors.Errorf("invalid infohash %q", h)
+	}
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create dir %v", dir)
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(p)+fsTempMarker+"*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp file")
+	}
+	tmp := f.Name()
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, p)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrapf(err, "failed to write %v", p)
+	}
+	return nil
+}
+
+func (s *FS) read(h string, ext string) ([]byte, error) {
+	p, err := s.stat(h, ext)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Swept between stat and read.
+		return nil, ss.ErrNotFound
+	}
+	return b, err
+}
+
+func (s *FS) Touch(_ context.Context, h string) (ok bool, err error) {
+	p, err := s.stat(h, fsTorrentExt)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if err = os.Chtimes(p, now, now); err != nil {
+		return false, err
+	}
+	// Keep the manifest alive together with its torrent.
+	if mp, err := s.stat(h, fsManifestExt); err == nil {
+		_ = os.Chtimes(mp, now, now)
+	}
+	return true, nil
+}
+
+func (s *FS) Push(_ context.Context, h string, torrent []byte) (ok bool, err error) {
+	if err = s.write(h, fsTorrentExt, torrent); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FS) Pull(_ context.Context, h string) (torrent []byte, err error) {
+	return s.read(h, fsTorrentExt)
+}
+
+func (s *FS) PullStream(_ context.Context, h string) (torrent io.ReadCloser, err error) {
+	p, err := s.stat(h, fsTorrentExt)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ss.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *FS) Exists(_ context.Context, h string) (ok bool, err error) {
+	if _, err = s.stat(h, fsTorrentExt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FS) PushManifest(_ context.Context, h string, manifest []byte) (ok bool, err error) {
+	if err = s.write(h, fsManifestExt, manifest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FS) PullManifest(_ context.Context, h string) (manifest []byte, err error) {
+	return s.read(h, fsManifestExt)
+}
+
+func (s *FS) Delete(_ context.Context, h string) (ok bool, err error) {
+	for _, ext := range []string{fsTorrentExt, fsManifestExt} {
+		p, valid := s.path(h, ext)
+		if !valid {
+			return true, nil
+		}
+		if err = os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (s *FS) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// sweep removes expired torrents/manifests and orphaned temp files.
+func (s *FS) sweep() {
+	s.removeFiles("expired", func(name string, mt time.Time) bool {
+		if strings.Contains(name, fsTempMarker) {
+			return time.Since(mt) > fsTempMaxAge
+		}
+		return s.expired(mt)
+	})
+}
+
+// sweepTemp removes the temp files written before start: no write of this
+// process is behind them, so they were orphaned by a crash of a previous
+// run.
+func (s *FS) sweepTemp(start time.Time) {
+	s.removeFiles("temp", func(name string, mt time.Time) bool {
+		return strings.Contains(name, fsTempMarker) && mt.Before(start)
+	})
+}
+
+// removeFiles walks the store and removes the files stale reports, stopping
+// early on Close. Errors on individual entries are logged and skipped so one
+// bad file can't stall it.
+func (s *FS) removeFiles(kind string, stale func(name string, mt time.Time) bool) {
+	t := time.Now()
+	removed := 0
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		select {
+		case <-s.closeC:
+			return filepath.SkipAll
+		default:
+		}
+		if err != nil {
+			log.WithError(err).WithField("path", p).Warn("failed to walk fs store")
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if !stale(d.Name(), fi.ModTime()) {
+			return nil
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.WithError(err).WithField("path", p).Warnf("failed to remove %v file", kind)
+			return nil
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).Warn("failed to sweep fs store")
+	}
+	log.WithField("kind", kind).WithField("removed", removed).WithField("duration", time.Since(t)).Info("fs store swept")
+}
+
+func (s *FS) Close() {
+	close(s.closeC)
+}
+
+var _ ss.StoreProvider = (*FS)(nil)
+var _ ss.DurableProvider = (*FS)(nil)
+var _ ss.StreamPuller = (*FS)(nil)
@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	ss "github.com/webtor-io/torrent-store/services"
+)
+
+const fsTestHash = "abcdef0123456789abcdef0123456789abcdef01"
+
+func newTestFS(t *testing.T, exp time.Duration) *FS {
+	t.Helper()
+	return &FS{dir: t.TempDir(), exp: exp, closeC: make(chan struct{})}
+}
+
+func TestFSPushPullShardedLayout(t *testing.T) {
+	s := newTestFS(t, 0)
+	ctx := context.Background()
+	if _, err := s.Push(ctx, fsTestHash, []byte("torrent")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, "ab", "cd", fsTestHash+".torrent")); err != nil {
+		t.Fatalf("torrent not at sharded path: %v", err)
+	}
+	b, err := s.Pull(ctx, fsTestHash)
+	if err != nil || string(b) != "torrent" {
+		t.Fatalf("pull = %q, %v", b, err)
+	}
+	if _, err := s.Delete(ctx, fsTestHash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Exists(ctx, fsTestHash); !errors.Is(err, ss.ErrNotFound) {
+		t.Fatalf("exists after delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFSRejectsPathTraversal(t *testing.T) {
+	s := newTestFS(t, 0)
+	if _, err := s.Push(context.Background(), "../../etc/passwd", []byte("x")); err == nil {
+		t.Fatal("push with traversal hash must fail")
+	}
+	if _, err := s.Pull(context.Background(), "../../etc/passwd"); !errors.Is(err, ss.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFSExpiryTouchAndSweep(t *testing.T) {
+	s := newTestFS(t, time.Minute)
+	ctx := context.Background()
+	_, _ = s.Push(ctx, fsTestHash, []byte("t"))
+	p, _ := s.path(fsTestHash, fsTorrentExt)
+	old := time.Now().Add(-2 * time.Minute)
+	_ = os.Chtimes(p, old, old)
+
+	if _, err := s.Pull(ctx, fsTestHash); !errors.Is(err, ss.ErrNotFound) {
+		t.Fatalf("expired pull: err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Touch(ctx, fsTestHash); !errors.Is(err, ss.ErrNotFound) {
+		t.Fatalf("expired touch: err = %v, want ErrNotFound", err)
+	}
+	s.sweep()
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Fatalf("expired file not swept: %v", err)
+	}
+}
+
+func TestFSSweepTempKeepsFilesOfThisRun(t *testing.T) {
+	s := newTestFS(t, 0)
+	ctx := context.Background()
+	_, _ = s.Push(ctx, fsTestHash, []byte("t"))
+	p, _ := s.path(fsTestHash, fsTorrentExt)
+	orphan := p + fsTempMarker + "1"
+	fresh := p + fsTempMarker + "2"
+	for _, f := range []string{orphan, fresh} {
+		if err := os.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	old := start.Add(-time.Minute)
+	_ = os.Chtimes(orphan, old, old)
+	later := start.Add(time.Second)
+	_ = os.Chtimes(fresh, later, later)
+
+	s.sweepTemp(start)
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Fatalf("orphaned temp file not swept: %v", err)
+	}
+	for _, f := range []string{fresh, p} {
+		if _, err := os.Stat(f); err != nil {
+			t.Fatalf("%v must be kept: %v", f, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package providers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	KeyLayoutFlat    = "flat"
+	KeyLayoutSharded = "sharded"
+)
+
+// keyLayout maps an infoHash to the key it is stored under: an optional
+// namespace prefix, then either the bare hash or, when sharded, the hash
+// nested under its first two byte pairs ("ab/cd/abcd...") so object stores
+// that partition by key prefix spread the load.
+type keyLayout struct {
+	prefix  string
+	sharded bool
+}
+
+func newKeyLayout(prefix string, layout string) (keyLayout, error) {
+	switch layout {
+	case "", KeyLayoutFlat:
+		return keyLayout{prefix: prefix}, nil
+	case KeyLayoutSharded:
+		return keyLayout{prefix: prefix, sharded: true}, nil
+	}
+	return keyLayout{}, errors.Errorf("unknown key layout %q (want %v or %v)", layout, KeyLayoutFlat, KeyLayoutSharded)
+}
+
+func (s keyLayout) key(h string) string {
+	if s.sharded && len(h) >= 4 {
+		return s.prefix + h[0:2] + "/" + h[2:4] + "/" + h
+	}
+	return s.prefix + h
+}
+
+// hash is the inverse of key for listings. It rejects keys outside the
+// layout and keys that aren't an infoHash, e.g. other services' keys under
+// the same bucket root or Redis DB. With an empty prefix the flat layout
+// shares the keyspace with everything else, so listings feeding
+// migrate-keys, rescan and reencrypt must never yield a key they don't own.
+func (s keyLayout) hash(key string) (string, bool) {
+	if !strings.HasPrefix(key, s.prefix) {
+		return "", false
+	}
+	h := key[len(s.prefix):]
+	if s.sharded {
+		h = h[strings.LastIndex(h, "/")+1:]
+	}
+	if !isInfoHash(h) || s.key(h) != key {
+		return "", false
+	}
+	return h, true
+}
+
+// isInfoHash tells whether h is a hex-encoded v1 infoHash as stored by the
+// server: 40 lowercase hex digits.
+func isInfoHash(h string) bool {
+	if len(h) != 40 {
+		return false
+	}
+	for i := 0; i < len(h); i++ {
+		c := h[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// globEscaper escapes the metacharacters of Redis glob patterns.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// scanPattern is the SCAN MATCH pattern of every key under the prefix.
+func (s keyLayout) scanPattern() string {
+	return globEscaper.Replace(s.prefix) + "*"
+}
+
+// KeyMigrator is implemented by tiers that can read from an old key layout
+// while their values are moved to the current one.
+type KeyMigrator interface {
+	// ListLegacy lists the infoHashes still stored under the old layout.
+	ListLegacy(ctx context.Context, fn func(h string) error) error
+	// MoveKey moves the torrent and manifest of h to the current layout and
+	// reports whether anything was moved.
+	MoveKey(ctx context.Context, h string) (moved bool, err error)
+}
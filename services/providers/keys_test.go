@@ -0,0 +1,62 @@
+package providers
+
+import "testing"
+
+func TestKeyLayout(t *testing.T) {
+	const h = "08ada5a7a6183aae1e09d831df6748d566095a10"
+	for _, tc := range []struct {
+		prefix, layout, key string
+	}{
+		{"", KeyLayoutFlat, h},
+		{"ts:", "", "ts:" + h},
+		{"torrents/", KeyLayoutSharded, "torrents/08/ad/" + h},
+	} {
+		l, err := newKeyLayout(tc.prefix, tc.layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := l.key(h); got != tc.key {
+			t.Fatalf("key = %q, want %q", got, tc.key)
+		}
+		if got, ok := l.hash(tc.key); !ok || got != h {
+			t.Fatalf("hash(%q) = %q, %v", tc.key, got, ok)
+		}
+	}
+	if _, err := newKeyLayout("", "nested"); err == nil {
+		t.Fatal("expected error for an unknown layout")
+	}
+}
+
+func TestKeyLayoutHashRejectsForeignKeys(t *testing.T) {
+	flat := keyLayout{prefix: "ts:"}
+	bare := keyLayout{}
+	sharded := keyLayout{prefix: "torrents/", sharded: true}
+	for _, tc := range []struct {
+		l   keyLayout
+		key string
+	}{
+		{flat, "other:abcd"},
+		{flat, "ts:"},
+		{flat, "ts:ab/cd/abcd"},
+		{flat, "ts:abcd"},
+		{flat, "ts:08ADA5A7A6183AAE1E09D831DF6748D566095A10"},
+		{flat, "ts:08ada5a7a6183aae1e09d831df6748d566095a10:manifest"},
+		{bare, "al"},
+		{bare, "rl:pull:08ada5a7a6183aae1e09d831df6748d566095a10"},
+		{bare, "08ada5a7a6183aae1e09d831df6748d566095a1z"},
+		{sharded, "abcd"},
+		{sharded, "torrents/abcd"},
+		{sharded, "torrents/ab/ce/abcd"},
+		{sharded, "torrents/08/ae/08ada5a7a6183aae1e09d831df6748d566095a10"},
+	} {
+		if h, ok := tc.l.hash(tc.key); ok {
+			t.Fatalf("hash(%q) = %q, want rejected", tc.key, h)
+		}
+	}
+}
+
+func TestKeyLayoutScanPattern(t *testing.T) {
+	if got, want := (keyLayout{prefix: `t[s]?*\:`}).scanPattern(), `t\[s\]\?\*\\:*`; got != want {
+		t.Fatalf("scanPattern = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,242 @@
+package providers
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/urfave/cli"
+	ss "github.com/webtor-io/torrent-store/services"
+)
+
+const (
+	MemoryUseFlag     = "use-memory"
+	MemoryMaxSizeFlag = "memory-max-size"
+	MemoryExpireFlag  = "memory-expire"
+)
+
+var (
+	memoryHitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_memory_hits_total",
+		Help: "In-process memory tier lookups that found a live entry, labelled by kind (torrent, manifest, verdict).",
+	}, []string{"kind"})
+	memoryMissesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_memory_misses_total",
+		Help: "In-process memory tier lookups that found nothing or an expired entry, labelled by kind (torrent, manifest, verdict).",
+	}, []string{"kind"})
+	memoryEvictionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_memory_evictions_total",
+		Help: "Entries dropped from the in-process memory tier, labelled by reason (size, expired).",
+	}, []string{"reason"})
+	memoryBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "torrent_store_memory_bytes",
+		Help: "Bytes currently held by the in-process memory tier.",
+	})
+)
+
+func RegisterMemoryFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.BoolFlag{
+			Name:   MemoryUseFlag,
+			Usage:  "use in-process memory cache",
+			EnvVar: "USE_MEMORY",
+		},
+		cli.IntFlag{
+			Name:   MemoryMaxSizeFlag,
+			Usage:  "memory cache max size (MB)",
+			Value:  256,
+			EnvVar: "MEMORY_MAX_SIZE",
+		},
+		cli.IntFlag{
+			Name:   MemoryExpireFlag,
+			Usage:  "memory cache expire (sec)",
+			Value:  600,
+			EnvVar: "MEMORY_EXPIRE",
+		},
+	)
+}
+
+type memoryEntry struct {
+	key     string
+	val     []byte
+	expires time.Time
+}
+
+// Memory is an in-process LRU tier bounded by the total size of stored
+// values rather than the entry count, since torrents range from a few KB to
+// tens of MB. Entries also carry a TTL so a hot torrent is re-read from the
+// shared tiers now and then. Expired entries are dropped lazily on access or
+// when they reach the LRU tail.
+type Memory struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	exp     time.Duration
+	ll      *list.List
+	items   map[string]*list.Element
+}
+
+func NewMemory(c *cli.Context) *Memory {
+	return newMemory(int64(c.Int(MemoryMaxSizeFlag))*1024*1024, time.Duration(c.Int(MemoryExpireFlag))*time.Second)
+}
+
+func newMemory(maxSize int64, exp time.Duration) *Memory {
+	return &Memory{
+		maxSize: maxSize,
+		exp:     exp,
+		ll:      list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+func (s *Memory) Name() string {
+	return MemoryName
+}
+
+// memoryManifestKey mirrors the Redis "m:" namespace so a manifest never
+// shadows the raw torrent stored under the bare infoHash.
+func memoryManifestKey(h string) string {
+	return "m:" + h
+}
+
+// memoryVerdictKey mirrors the Redis "v:" namespace of stoplist verdicts.
+func memoryVerdictKey(h string) string {
+	return "v:" + h
+}
+
+func (s *Memory) removeElement(e *list.Element, reason string) {
+	en := e.Value.(*memoryEntry)
+	s.ll.Remove(e)
+	delete(s.items, en.key)
+	s.size -= int64(len(en.val))
+	memoryBytes.Set(float64(s.size))
+	if reason != "" {
+		memoryEvictionsTotal.WithLabelValues(reason).Inc()
+	}
+}
+
+// lookup returns the live element for key, dropping it if it has expired.
+// Must be called with mu held.
+func (s *Memory) lookup(key string) *list.Element {
+	e, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	if s.exp > 0 && time.Now().After(e.Value.(*memoryEntry).expires) {
+		s.removeElement(e, "expired")
+		return nil
+	}
+	return e
+}
+
+func (s *Memory) get(key string, kind string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(key)
+	if e == nil {
+		memoryMissesTotal.WithLabelValues(kind).Inc()
+		return nil, ss.ErrNotFound
+	}
+	memoryHitsTotal.WithLabelValues(kind).Inc()
+	s.ll.MoveToFront(e)
+	return e.Value.(*memoryEntry).val, nil
+}
+
+// set stores val under key and evicts from the LRU tail until the cache fits
+// maxSize again. A value larger than the whole cache is not stored at all.
+func (s *Memory) set(key string, val []byte) bool {
+	if int64(len(val)) > s.maxSize {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.removeElement(e, "")
+	}
+	s.items[key] = s.ll.PushFront(&memoryEntry{
+		key:     key,
+		val:     val,
+		expires: time.Now().Add(s.exp),
+	})
+	s.size += int64(len(val))
+	for s.size > s.maxSize {
+		tail := s.ll.Back()
+		reason := "size"
+		if s.exp > 0 && time.Now().After(tail.Value.(*memoryEntry).expires) {
+			reason = "expired"
+		}
+		s.removeElement(tail, reason)
+	}
+	memoryBytes.Set(float64(s.size))
+	return true
+}
+
+func (s *Memory) Touch(_ context.Context, h string) (ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(h)
+	if e == nil {
+		return false, ss.ErrNotFound
+	}
+	expires := time.Now().Add(s.exp)
+	e.Value.(*memoryEntry).expires = expires
+	s.ll.MoveToFront(e)
+	for _, key := range []string{memoryManifestKey(h), memoryVerdictKey(h)} {
+		if de := s.lookup(key); de != nil {
+			de.Value.(*memoryEntry).expires = expires
+		}
+	}
+	return true, nil
+}
+
+func (s *Memory) Push(_ context.Context, h string, torrent []byte) (ok bool, err error) {
+	return s.set(h, torrent), nil
+}
+
+func (s *Memory) Pull(_ context.Context, h string) (torrent []byte, err error) {
+	return s.get(h, "torrent")
+}
+
+func (s *Memory) Exists(_ context.Context, h string) (ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lookup(h) == nil {
+		return false, ss.ErrNotFound
+	}
+	return true, nil
+}
+
+func (s *Memory) PushManifest(_ context.Context, h string, manifest []byte) (ok bool, err error) {
+	return s.set(memoryManifestKey(h), manifest), nil
+}
+
+func (s *Memory) PullManifest(_ context.Context, h string) (manifest []byte, err error) {
+	return s.get(memoryManifestKey(h), "manifest")
+}
+
+// PushVerdict keeps the verdict in the LRU with torrents and manifests, so it
+// is bounded by the same size and expiry.
+func (s *Memory) PushVerdict(_ context.Context, h string, verdict []byte) (ok bool, err error) {
+	return s.set(memoryVerdictKey(h), verdict), nil
+}
+
+func (s *Memory) PullVerdict(_ context.Context, h string) (verdict []byte, err error) {
+	return s.get(memoryVerdictKey(h), "verdict")
+}
+
+func (s *Memory) Delete(_ context.Context, h string) (ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range []string{h, memoryManifestKey(h), memoryVerdictKey(h)} {
+		if e, found := s.items[key]; found {
+			s.removeElement(e, "")
+		}
+	}
+	return true, nil
+}
+
+var _ ss.StoreProvider = (*Memory)(nil)
+var _ ss.VerdictProvider = (*Memory)(nil)
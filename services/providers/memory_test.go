@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	ss "github.com/webtor-io/torrent-store/services"
+)
+
+func TestMemoryEvictsLeastRecentlyUsedBySize(t *testing.T) {
+	s := newMemory(10, time.Minute)
+	ctx := context.Background()
+	_, _ = s.Push(ctx, "a", []byte("1234"))
+	_, _ = s.Push(ctx, "b", []byte("1234"))
+	// Touch "a" so "b" becomes the LRU tail.
+	if _, err := s.Pull(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.Push(ctx, "c", []byte("1234"))
+
+	if _, err := s.Pull(ctx, "b"); !errors.Is(err, ss.ErrNotFound) {
+		t.Fatalf("b: err = %v, want evicted", err)
+	}
+	for _, h := range []string{"a", "c"} {
+		if _, err := s.Pull(ctx, h); err != nil {
+			t.Fatalf("%v: %v", h, err)
+		}
+	}
+	if s.size != 8 {
+		t.Fatalf("size = %d, want 8", s.size)
+	}
+}
+
+func TestMemorySkipsOversizedValue(t *testing.T) {
+	s := newMemory(4, time.Minute)
+	ok, err := s.Push(context.Background(), "a", []byte("12345"))
+	if err != nil || ok {
+		t.Fatalf("ok = %v, err = %v; want not stored", ok, err)
+	}
+	if s.size != 0 {
+		t.Fatalf("size = %d, want 0", s.size)
+	}
+}
+
+func TestMemoryExpiresEntries(t *testing.T) {
+	s := newMemory(100, time.Millisecond)
+	ctx := context.Background()
+	_, _ = s.Push(ctx, "a", []byte("x"))
+	_, _ = s.PushManifest(ctx, "a", []byte("m"))
+	time.Sleep(5 * time.Millisecond)
+	if _, err := s.Pull(ctx, "a"); !errors.Is(err, ss.ErrNotFound) {
+		t.Fatalf("err = %v, want expired", err)
+	}
+	if _, err := s.PullManifest(ctx, "a"); !errors.Is(err, ss.ErrNotFound) {
+		t.Fatalf("manifest err = %v, want expired", err)
+	}
+	if s.size != 0 {
+		t.Fatalf("size = %d, want 0", s.size)
+	}
+}
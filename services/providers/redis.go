@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli"
@@ -12,8 +13,11 @@ import (
 )
 
 const (
-	RedisExpireFlag = "redis-expire"
-	RedisUseFlag    = "use-redis"
+	RedisExpireFlag       = "redis-expire"
+	RedisUseFlag          = "use-redis"
+	RedisKeyPrefixFlag    = ss.RedisKeyPrefixFlag
+	RedisOldKeyPrefixFlag = "redis-old-key-prefix"
+	RedisKeyFallbackFlag  = "redis-key-fallback"
 )
 
 func RegisterRedisFlags(f []cli.Flag) []cli.Flag {
@@ -29,81 +33,238 @@ func RegisterRedisFlags(f []cli.Flag) []cli.Flag {
 			Usage:  "use redis",
 			EnvVar: "USE_REDIS",
 		},
+		cli.StringFlag{
+			Name:   RedisKeyPrefixFlag,
+			Usage:  "redis key prefix, e.g. \"ts:\"",
+			Value:  "",
+			EnvVar: "REDIS_KEY_PREFIX",
+		},
+		cli.StringFlag{
+			Name:   RedisOldKeyPrefixFlag,
+			Usage:  "redis key prefix to fall back to and migrate from",
+			Value:  "",
+			EnvVar: "REDIS_OLD_KEY_PREFIX",
+		},
+		cli.BoolFlag{
+			Name:   RedisKeyFallbackFlag,
+			Usage:  "read keys missing under the current prefix from the old prefix",
+			EnvVar: "REDIS_KEY_FALLBACK",
+		},
 	)
 }
 
 type Redis struct {
-	cl  *cs.RedisClient
-	exp time.Duration
+	cl     *cs.RedisClient
+	exp    time.Duration
+	layout keyLayout
+	// old is read when a key is missing under layout, nil unless fallback
+	// is enabled.
+	old *keyLayout
 }
 
 func NewRedis(c *cli.Context, cl *cs.RedisClient) *Redis {
-	if !c.Bool(RedisUseFlag) {
-		return nil
+	s := &Redis{
+		exp:    time.Duration(c.Int(RedisExpireFlag)) * time.Second,
+		cl:     cl,
+		layout: keyLayout{prefix: c.String(RedisKeyPrefixFlag)},
 	}
-	return &Redis{
-		exp: time.Duration(c.Int(RedisExpireFlag)) * time.Second,
-		cl:  cl,
+	old := keyLayout{prefix: c.String(RedisOldKeyPrefixFlag)}
+	if c.Bool(RedisKeyFallbackFlag) && old != s.layout {
+		s.old = &old
 	}
+	return s
 }
 
 func (s *Redis) Name() string {
-	return "redis"
+	return RedisName
 }
 
-func (s *Redis) Touch(ctx context.Context, h string) (ok bool, err error) {
-	cl := s.cl.Get()
+// manifestKey namespaces derived manifests so they never collide with the
+// raw .torrent stored under the bare infoHash.
+func manifestKey(l keyLayout, h string) string {
+	return l.key("m:" + h)
+}
 
-	res, err := cl.Expire(ctx, h, s.exp).Result()
+// verdictKey namespaces cached stoplist verdicts the same way.
+func verdictKey(l keyLayout, h string) string {
+	return l.key("v:" + h)
+}
 
-	if err != nil {
-		return false, err
+// layouts returns the layouts to read in order: the current one, then the
+// old one while keys are being migrated.
+func (s *Redis) layouts() []keyLayout {
+	if s.old == nil {
+		return []keyLayout{s.layout}
 	}
-	if !res {
-		return false, ss.ErrNotFound
+	return []keyLayout{s.layout, *s.old}
+}
+
+func (s *Redis) Touch(ctx context.Context, h string) (ok bool, err error) {
+	cl := s.cl.Get()
+	for _, l := range s.layouts() {
+		res, err := cl.Expire(ctx, l.key(h), s.exp).Result()
+		if err != nil {
+			return false, err
+		}
+		if res {
+			return true, nil
+		}
 	}
-	return true, nil
+	return false, ss.ErrNotFound
 }
 
 func (s *Redis) Push(ctx context.Context, h string, torrent []byte) (ok bool, err error) {
 	cl := s.cl.Get()
-	err = cl.Set(ctx, h, torrent, s.exp).Err()
+	err = cl.Set(ctx, s.layout.key(h), torrent, s.exp).Err()
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func (s *Redis) Pull(ctx context.Context, h string) (torrent []byte, err error) {
+func (s *Redis) get(ctx context.Context, key func(l keyLayout) string) ([]byte, error) {
 	cl := s.cl.Get()
-	torrent, err = cl.Get(ctx, h).Bytes()
-	if errors.Is(err, redis.Nil) {
-		return nil, ss.ErrNotFound
+	for _, l := range s.layouts() {
+		val, err := cl.Get(ctx, key(l)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		return val, err
 	}
-	return
+	return nil, ss.ErrNotFound
 }
 
-// manifestKey namespaces derived manifests so they never collide with the
-// raw .torrent stored under the bare infoHash.
-func manifestKey(h string) string {
-	return "m:" + h
+func (s *Redis) Pull(ctx context.Context, h string) (torrent []byte, err error) {
+	return s.get(ctx, func(l keyLayout) string { return l.key(h) })
+}
+
+func (s *Redis) Exists(ctx context.Context, h string) (ok bool, err error) {
+	cl := s.cl.Get()
+	for _, l := range s.layouts() {
+		n, err := cl.Exists(ctx, l.key(h)).Result()
+		if err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+	return false, ss.ErrNotFound
 }
 
 func (s *Redis) PushManifest(ctx context.Context, h string, manifest []byte) (ok bool, err error) {
 	cl := s.cl.Get()
-	if err = cl.Set(ctx, manifestKey(h), manifest, s.exp).Err(); err != nil {
+	if err = cl.Set(ctx, manifestKey(s.layout, h), manifest, s.exp).Err(); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
 func (s *Redis) PullManifest(ctx context.Context, h string) (manifest []byte, err error) {
+	return s.get(ctx, func(l keyLayout) string { return manifestKey(l, h) })
+}
+
+func (s *Redis) PushVerdict(ctx context.Context, h string, verdict []byte) (ok bool, err error) {
+	cl := s.cl.Get()
+	if err = cl.Set(ctx, verdictKey(s.layout, h), verdict, s.exp).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Redis) PullVerdict(ctx context.Context, h string) (verdict []byte, err error) {
+	return s.get(ctx, func(l keyLayout) string { return verdictKey(l, h) })
+}
+
+// Delete removes h under the old prefix too, so the fallback read can't bring
+// a deleted torrent back mid-migration.
+func (s *Redis) Delete(ctx context.Context, h string) (ok bool, err error) {
+	cl := s.cl.Get()
+	var keys []string
+	for _, l := range s.layouts() {
+		keys = append(keys, l.key(h), manifestKey(l, h), verdictKey(l, h))
+	}
+	if err = cl.Del(ctx, keys...).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// list scans the keyspace under the prefix of l. Torrents live under the bare
+// infoHash; anything else (manifests and other services' keys) is skipped.
+func (s *Redis) list(ctx context.Context, l keyLayout, fn func(h string) error) error {
+	cl := s.cl.Get()
+	it := cl.Scan(ctx, 0, l.scanPattern(), 1000).Iterator()
+	for it.Next(ctx) {
+		h, ok := l.hash(it.Val())
+		if !ok {
+			continue
+		}
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+func (s *Redis) List(ctx context.Context, fn func(h string) error) error {
+	return s.list(ctx, s.layout, fn)
+}
+
+func (s *Redis) ListLegacy(ctx context.Context, fn func(h string) error) error {
+	if s.old == nil {
+		return errors.Errorf("nothing to migrate, set --%v", RedisKeyFallbackFlag)
+	}
+	return s.list(ctx, *s.old, fn)
+}
+
+// MoveKey copies keys with DUMP/RESTORE, which keeps the TTL and, unlike
+// RENAME, works across cluster slots. A key that already exists under the
+// new prefix was pushed after the migration started and is kept. The old and
+// new keys may live on different cluster slots, so the steps can't run as
+// one script: a key that expires between DUMP and PTTL is skipped rather
+// than restored without a TTL.
+func (s *Redis) MoveKey(ctx context.Context, h string) (moved bool, err error) {
+	if s.old == nil {
+		return false, errors.Errorf("nothing to migrate, set --%v", RedisKeyFallbackFlag)
+	}
 	cl := s.cl.Get()
-	manifest, err = cl.Get(ctx, manifestKey(h)).Bytes()
-	if errors.Is(err, redis.Nil) {
-		return nil, ss.ErrNotFound
+	for _, k := range [][2]string{
+		{s.old.key(h), s.layout.key(h)},
+		{manifestKey(*s.old, h), manifestKey(s.layout, h)},
+		{verdictKey(*s.old, h), verdictKey(s.layout, h)},
+	} {
+		from, to := k[0], k[1]
+		dump, err := cl.Dump(ctx, from).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			return moved, err
+		}
+		ttl, err := cl.PTTL(ctx, from).Result()
+		if err != nil {
+			return moved, err
+		}
+		if ttl == -2 {
+			// Expired since the DUMP, there is nothing left to move.
+			continue
+		} else if ttl < 0 {
+			// -1: the key has no TTL, which RESTORE takes as 0.
+			ttl = 0
+		}
+		err = cl.Restore(ctx, to, ttl, dump).Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYKEY") {
+			return moved, errors.Wrapf(err, "failed to restore key=%v", to)
+		}
+		if err = cl.Del(ctx, from).Err(); err != nil {
+			return moved, err
+		}
+		moved = true
 	}
-	return
+	return moved, nil
 }
 
 var _ ss.StoreProvider = (*Redis)(nil)
+var _ ss.Lister = (*Redis)(nil)
+var _ ss.VerdictProvider = (*Redis)(nil)
+var _ KeyMigrator = (*Redis)(nil)
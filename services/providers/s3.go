@@ -8,15 +8,23 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
 	"github.com/urfave/cli"
 	cs "github.com/webtor-io/common-services"
 	ss "github.com/webtor-io/torrent-store/services"
 	"io"
+	"net/url"
+	"strings"
 )
 
 const (
-	AWSBucketFlag = "aws-bucket"
-	S3UseFlag     = "use-s3"
+	AWSBucketFlag      = "aws-bucket"
+	S3UseFlag          = "use-s3"
+	S3KeyPrefixFlag    = "s3-key-prefix"
+	S3KeyLayoutFlag    = "s3-key-layout"
+	S3OldKeyPrefixFlag = "s3-old-key-prefix"
+	S3OldKeyLayoutFlag = "s3-old-key-layout"
+	S3KeyFallbackFlag  = "s3-key-fallback"
 )
 
 func RegisterS3Flags(f []cli.Flag) []cli.Flag {
@@ -32,43 +40,117 @@ func RegisterS3Flags(f []cli.Flag) []cli.Flag {
 			Usage:  "use s3",
 			EnvVar: "USE_S3",
 		},
+		cli.StringFlag{
+			Name:   S3KeyPrefixFlag,
+			Usage:  "s3 key prefix, e.g. \"torrents/\"",
+			Value:  "",
+			EnvVar: "S3_KEY_PREFIX",
+		},
+		cli.StringFlag{
+			Name:   S3KeyLayoutFlag,
+			Usage:  "s3 key layout: flat (<hash>) or sharded (ab/cd/<hash>)",
+			Value:  KeyLayoutFlat,
+			EnvVar: "S3_KEY_LAYOUT",
+		},
+		cli.StringFlag{
+			Name:   S3OldKeyPrefixFlag,
+			Usage:  "s3 key prefix to fall back to and migrate from",
+			Value:  "",
+			EnvVar: "S3_OLD_KEY_PREFIX",
+		},
+		cli.StringFlag{
+			Name:   S3OldKeyLayoutFlag,
+			Usage:  "s3 key layout to fall back to and migrate from",
+			Value:  KeyLayoutFlat,
+			EnvVar: "S3_OLD_KEY_LAYOUT",
+		},
+		cli.BoolFlag{
+			Name:   S3KeyFallbackFlag,
+			Usage:  "read objects missing under the current layout from the old layout",
+			EnvVar: "S3_KEY_FALLBACK",
+		},
 	)
 }
 
 type S3 struct {
 	bucket string
 	cl     *cs.S3Client
+	layout keyLayout
+	// old is read when an object is missing under layout, nil unless
+	// fallback is enabled.
+	old *keyLayout
 }
 
-func NewS3(c *cli.Context, cl *cs.S3Client) *S3 {
-	if !c.Bool(S3UseFlag) {
-		return nil
+func NewS3(c *cli.Context, cl *cs.S3Client) (*S3, error) {
+	layout, err := newKeyLayout(c.String(S3KeyPrefixFlag), c.String(S3KeyLayoutFlag))
+	if err != nil {
+		return nil, errors.Wrapf(err, "bad --%v", S3KeyLayoutFlag)
 	}
-	return &S3{
+	s := &S3{
 		bucket: c.String(AWSBucketFlag),
 		cl:     cl,
+		layout: layout,
 	}
+	if c.Bool(S3KeyFallbackFlag) {
+		old, err := newKeyLayout(c.String(S3OldKeyPrefixFlag), c.String(S3OldKeyLayoutFlag))
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad --%v", S3OldKeyLayoutFlag)
+		}
+		if old != layout {
+			s.old = &old
+		}
+	}
+	return s, nil
+}
+
+func (s *S3) Durable() bool {
+	return true
 }
 
 func (s *S3) Name() string {
-	return "s3"
+	return S3Name
 }
 
-func (s *S3) Touch(ctx context.Context, h string) (ok bool, err error) {
+// layouts returns the layouts to read in order: the current one, then the
+// old one while objects are being migrated.
+func (s *S3) layouts() []keyLayout {
+	if s.old == nil {
+		return []keyLayout{s.layout}
+	}
+	return []keyLayout{s.layout, *s.old}
+}
+
+func isS3NotFound(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && (awsErr.Code() == s3.ErrCodeNoSuchKey || awsErr.Code() == "NotFound")
+}
+
+// get opens the object under the first layout holding it.
+func (s *S3) get(ctx context.Context, key func(l keyLayout) string) (io.ReadCloser, error) {
 	cl := s.cl.Get()
-	r, err := cl.GetObjectWithContext(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(h),
-	})
-	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
-			return false, ss.ErrNotFound
+	for _, l := range s.layouts() {
+		r, err := cl.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key(l)),
+		})
+		if isS3NotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
 		}
+		return r.Body, nil
+	}
+	return nil, ss.ErrNotFound
+}
+
+func (s *S3) Touch(ctx context.Context, h string) (ok bool, err error) {
+	r, err := s.get(ctx, func(l keyLayout) string { return l.key(h) })
+	if err != nil {
 		return false, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
-	}(r.Body)
+	}(r)
 	return true, nil
 }
 
@@ -83,7 +165,7 @@ func (s *S3) Push(ctx context.Context, h string, torrent []byte) (ok bool, err e
 	_, err = cl.PutObjectWithContext(ctx,
 		&s3.PutObjectInput{
 			Bucket:     aws.String(s.bucket),
-			Key:        aws.String(h),
+			Key:        aws.String(s.layout.key(h)),
 			Body:       bytes.NewReader(torrent),
 			ContentMD5: s.makeAWSMD5(torrent),
 		})
@@ -94,37 +176,65 @@ func (s *S3) Push(ctx context.Context, h string, torrent []byte) (ok bool, err e
 }
 
 func (s *S3) Pull(ctx context.Context, h string) (torrent []byte, err error) {
+	r, err := s.PullStream(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	defer func(r io.ReadCloser) {
+		_ = r.Close()
+	}(r)
+	return io.ReadAll(r)
+}
+
+// PullStream hands out the GetObject body as is, so large torrents are never
+// fully buffered on the provider side. The caller must close it.
+func (s *S3) PullStream(ctx context.Context, h string) (torrent io.ReadCloser, err error) {
+	return s.get(ctx, func(l keyLayout) string { return l.key(h) })
+}
+
+// Exists probes the object with HeadObject, so no body is transferred.
+// HEAD responses carry no error document, which is why S3 reports a missing
+// key as a bare "NotFound" code instead of NoSuchKey.
+func (s *S3) Exists(ctx context.Context, h string) (ok bool, err error) {
+	for _, l := range s.layouts() {
+		ok, err = s.head(ctx, l.key(h))
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, ss.ErrNotFound
+}
+
+func (s *S3) head(ctx context.Context, key string) (ok bool, err error) {
 	cl := s.cl.Get()
-	r, err := cl.GetObjectWithContext(ctx, &s3.GetObjectInput{
+	_, err = cl.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(h),
+		Key:    aws.String(key),
 	})
-	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
-			return nil, ss.ErrNotFound
-		}
-		return nil, err
+	if isS3NotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
 	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(r.Body)
-	return io.ReadAll(r.Body)
+	return true, nil
 }
 
 // s3ManifestKey namespaces derived manifests with a .manifest suffix so they
 // sit next to the raw .torrent object (stored under the bare infoHash) without
 // colliding. Manifests are immutable and rebuildable, so they live without an
 // expiry — the durable bottom tier that survives Badger/Redis eviction.
-func s3ManifestKey(h string) string {
-	return h + ".manifest"
+func s3ManifestKey(l keyLayout, h string) string {
+	return l.key(h) + s3ManifestSuffix
 }
 
+const s3ManifestSuffix = ".manifest"
+
 func (s *S3) PushManifest(ctx context.Context, h string, manifest []byte) (ok bool, err error) {
 	cl := s.cl.Get()
 	_, err = cl.PutObjectWithContext(ctx,
 		&s3.PutObjectInput{
 			Bucket:     aws.String(s.bucket),
-			Key:        aws.String(s3ManifestKey(h)),
+			Key:        aws.String(s3ManifestKey(s.layout, h)),
 			Body:       bytes.NewReader(manifest),
 			ContentMD5: s.makeAWSMD5(manifest),
 		})
@@ -135,21 +245,136 @@ func (s *S3) PushManifest(ctx context.Context, h string, manifest []byte) (ok bo
 }
 
 func (s *S3) PullManifest(ctx context.Context, h string) (manifest []byte, err error) {
+	r, err := s.get(ctx, func(l keyLayout) string { return s3ManifestKey(l, h) })
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r)
+	return io.ReadAll(r)
+}
+
+// Delete removes the torrent and its manifest object in one request, under
+// the old layout too so the fallback read can't bring them back. S3
+// DeleteObjects reports per-key failures in the response body rather than
+// as a request error, so those are surfaced explicitly.
+func (s *S3) Delete(ctx context.Context, h string) (ok bool, err error) {
 	cl := s.cl.Get()
-	r, err := cl.GetObjectWithContext(ctx, &s3.GetObjectInput{
+	var objects []*s3.ObjectIdentifier
+	for _, l := range s.layouts() {
+		objects = append(objects,
+			&s3.ObjectIdentifier{Key: aws.String(l.key(h))},
+			&s3.ObjectIdentifier{Key: aws.String(s3ManifestKey(l, h))},
+		)
+	}
+	r, err := cl.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(s3ManifestKey(h)),
+		Delete: &s3.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
 	})
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
-			return nil, ss.ErrNotFound
+		return false, err
+	}
+	if len(r.Errors) > 0 {
+		e := r.Errors[0]
+		return false, errors.Errorf("failed to delete key=%v code=%v: %v", aws.StringValue(e.Key), aws.StringValue(e.Code), aws.StringValue(e.Message))
+	}
+	return true, nil
+}
+
+// list pages through the objects under the prefix of l. Manifest objects are
+// skipped: they are reached through the infoHash of their torrent. So are
+// keys that don't fit the layout.
+func (s *S3) list(ctx context.Context, l keyLayout, fn func(h string) error) error {
+	cl := s.cl.Get()
+	var ferr error
+	err := cl.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(l.prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, o := range page.Contents {
+			key := aws.StringValue(o.Key)
+			if strings.HasSuffix(key, s3ManifestSuffix) {
+				continue
+			}
+			h, ok := l.hash(key)
+			if !ok {
+				continue
+			}
+			if ferr = fn(h); ferr != nil {
+				return false
+			}
 		}
-		return nil, err
+		return true
+	})
+	if ferr != nil {
+		return ferr
 	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(r.Body)
-	return io.ReadAll(r.Body)
+	return err
+}
+
+func (s *S3) List(ctx context.Context, fn func(h string) error) error {
+	return s.list(ctx, s.layout, fn)
+}
+
+func (s *S3) ListLegacy(ctx context.Context, fn func(h string) error) error {
+	if s.old == nil {
+		return errors.Errorf("nothing to migrate, set --%v", S3KeyFallbackFlag)
+	}
+	return s.list(ctx, *s.old, fn)
+}
+
+// MoveKey copies the objects server-side and deletes the originals. An object
+// that already exists under the new layout was pushed after the migration
+// started and is kept.
+func (s *S3) MoveKey(ctx context.Context, h string) (moved bool, err error) {
+	if s.old == nil {
+		return false, errors.Errorf("nothing to migrate, set --%v", S3KeyFallbackFlag)
+	}
+	cl := s.cl.Get()
+	for _, k := range [][2]string{
+		{s.old.key(h), s.layout.key(h)},
+		{s3ManifestKey(*s.old, h), s3ManifestKey(s.layout, h)},
+	} {
+		from, to := k[0], k[1]
+		exists, err := s.head(ctx, from)
+		if err != nil {
+			return moved, err
+		}
+		if !exists {
+			continue
+		}
+		done, err := s.head(ctx, to)
+		if err != nil {
+			return moved, err
+		}
+		if !done {
+			_, err = cl.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+				Bucket:     aws.String(s.bucket),
+				Key:        aws.String(to),
+				CopySource: aws.String((&url.URL{Path: s.bucket + "/" + from}).EscapedPath()),
+			})
+			if err != nil {
+				return moved, errors.Wrapf(err, "failed to copy key=%v", from)
+			}
+		}
+		_, err = cl.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(from),
+		})
+		if err != nil {
+			return moved, errors.Wrapf(err, "failed to delete key=%v", from)
+		}
+		moved = true
+	}
+	return moved, nil
 }
 
 var _ ss.StoreProvider = (*S3)(nil)
+var _ ss.Lister = (*S3)(nil)
+var _ ss.DurableProvider = (*S3)(nil)
+var _ ss.StreamPuller = (*S3)(nil)
+var _ KeyMigrator = (*S3)(nil)
@@ -0,0 +1,8 @@
+package services
+
+// RedisKeyPrefixFlag namespaces the keys of a Redis DB shared with other
+// services. It is registered along with the Redis tier, which stores the
+// torrents under it; the caches, rate limiter and allowlist keep their own
+// keys under it as well, so that nothing this service writes lands in the
+// bare keyspace.
+const RedisKeyPrefixFlag = "redis-key-prefix"
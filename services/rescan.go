@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// RescanMatch is a stored torrent the stoplist matches, as reported by a
+// rescan.
+type RescanMatch struct {
+	InfoHash string   `json:"infoHash"`
+	Name     string   `json:"name,omitempty"`
+	Version  string   `json:"version"`
+	Stack    []string `json:"stack"`
+	Purged   bool     `json:"purged"`
+	// PurgeErrors maps the tiers that failed to purge to their error.
+	PurgeErrors map[string]string `json:"purgeErrors,omitempty"`
+}
+
+// RescanStats sums up a rescan.
+type RescanStats struct {
+	Scanned     int64
+	Matched     int64
+	Allowlisted int64
+	Purged      int64
+	Failed      int64
+}
+
+// Rescan checks every torrent of a listable tier against the current
+// stoplist. Torrents stored before a rule landed are otherwise only
+// re-checked lazily on Pull, while manifests cached before it keep being
+// served by Files.
+type Rescan struct {
+	src         StoreProvider
+	l           Lister
+	sl          *Stoplist
+	al          *Allowlist
+	purge       *Store
+	concurrency int
+}
+
+// NewRescan scans the torrents listed by l and read from src: l is usually
+// the bare tier and src the same tier wrapped for compression and
+// encryption. Allowlisted torrents are skipped. If purge is set, matches
+// are deleted from its tiers along with their manifests and verdicts.
+func NewRescan(src StoreProvider, l Lister, sl *Stoplist, al *Allowlist, purge *Store, concurrency int) *Rescan {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Rescan{
+		src:         src,
+		l:           l,
+		sl:          sl,
+		al:          al,
+		purge:       purge,
+		concurrency: concurrency,
+	}
+}
+
+// Run scans the tier with bounded parallelism and calls fn for every match.
+// Calls of fn are serialized; returning an error from it stops the scan. A
+// single torrent failing to read or check is logged and counted, not fatal.
+func (s *Rescan) Run(ctx context.Context, fn func(m *RescanMatch) error) (RescanStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	t := time.Now()
+	rLog := log.WithField("provider", s.src.Name())
+	rLog.WithField("version", s.sl.Version()).Info("rescanning provider")
+	var checked, matched, allowlisted, purged atomic.Int64
+	var mu sync.Mutex
+	var fnErr error
+	ks, err := ForEachStoredKey(ctx, s.l, s.concurrency, func(ctx context.Context, h string) error {
+		if n := checked.Add(1); n%1000 == 0 {
+			rLog.WithField("scanned", n).WithField("matched", matched.Load()).Info("rescan progress")
+		}
+		m, allowed, err := s.check(ctx, h)
+		if err != nil {
+			rLog.WithField("infohash", h).WithError(err).Warn("failed to rescan")
+			return err
+		}
+		if allowed {
+			allowlisted.Add(1)
+		}
+		if m == nil {
+			return nil
+		}
+		matched.Add(1)
+		if m.Purged {
+			purged.Add(1)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if fnErr == nil {
+			if fnErr = fn(m); fnErr != nil {
+				cancel()
+			}
+		}
+		return nil
+	})
+	st := RescanStats{
+		Scanned:     ks.Scanned,
+		Matched:     matched.Load(),
+		Allowlisted: allowlisted.Load(),
+		Purged:      purged.Load(),
+		Failed:      ks.Failed,
+	}
+	rLog = rLog.WithField("scanned", st.Scanned).
+		WithField("matched", st.Matched).
+		WithField("allowlisted", st.Allowlisted).
+		WithField("purged", st.Purged).
+		WithField("failed", st.Failed).
+		WithField("duration", time.Since(t))
+	if fnErr != nil {
+		rLog.WithError(fnErr).Error("failed to report rescan match")
+		return st, errors.Wrap(fnErr, "failed to report rescan match")
+	}
+	if err != nil {
+		rLog.WithError(err).Error("failed to list provider")
+		return st, errors.Wrapf(err, "failed to list provider %v", s.src.Name())
+	}
+	rLog.Info("provider rescanned")
+	return st, nil
+}
+
+// check reads h and checks it against the stoplist, purging it on a match.
+// A torrent deleted since it was listed is no match.
+func (s *Rescan) check(ctx context.Context, h string) (*RescanMatch, bool, error) {
+	if _, ok := s.al.AllowedHash(h); ok {
+		return nil, true, nil
+	}
+	torrent, err := s.src.Pull(ctx, h)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, errors.Wrap(err, "failed to pull torrent")
+	}
+	cr, version, err := s.sl.CheckVersion(torrent)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to check stoplist")
+	}
+	if !cr.Found {
+		return nil, false, nil
+	}
+	if _, ok := s.al.AllowedName(torrent); ok {
+		return nil, true, nil
+	}
+	m := &RescanMatch{
+		InfoHash: h,
+		Name:     torrentName(torrent),
+		Version:  version,
+		Stack:    cr.Stack,
+	}
+	hLog := log.WithField("infohash", h).WithField("version", version)
+	if s.purge == nil {
+		hLog.Warnf("found in stoplist %v", cr.String())
+		return m, false, nil
+	}
+	m.Purged = true
+	for _, r := range s.purge.Delete(ctx, h) {
+		if r.Err != nil {
+			if m.PurgeErrors == nil {
+				m.PurgeErrors = map[string]string{}
+			}
+			m.PurgeErrors[r.Provider] = r.Err.Error()
+			m.Purged = false
+		}
+	}
+	hLog.WithField("purged", m.Purged).Warnf("found in stoplist %v", cr.String())
+	return m, false, nil
+}
+
+// torrentName returns the name of the torrent, or "" if it can't be parsed.
+func torrentName(torrent []byte) string {
+	mi, err := metainfo.Load(bytes.NewReader(torrent))
+	if err != nil {
+		return ""
+	}
+	i, err := mi.UnmarshalInfo()
+	if err != nil {
+		return ""
+	}
+	return i.Name
+}
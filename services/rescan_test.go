@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type listedFake struct {
+	*fakeProvider
+}
+
+func (f listedFake) List(_ context.Context, fn func(h string) error) error {
+	f.mu.Lock()
+	var hashes []string
+	for h := range f.torrents {
+		hashes = append(hashes, h)
+	}
+	f.mu.Unlock()
+	sort.Strings(hashes)
+	for _, h := range hashes {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRescanPurge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - apple\n")
+	st, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	al, _ := newTestAllowlist(t)
+	ctx := context.Background()
+	if _, err = al.Add(ctx, AllowlistEntry{Kind: AllowlistKindName, Value: "audiobook$", Author: "mod", Reason: "false positive"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := newFakeProvider("redis", true)
+	src := newFakeProvider("s3", false)
+	hashes := map[string]string{}
+	for _, name := range []string{"ripe banana", "green apple", "apple audiobook"} {
+		torrent := namedTorrent(t, name)
+		mi, err := metainfo.Load(bytes.NewReader(torrent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := mi.HashInfoBytes().HexString()
+		hashes[name] = h
+		for _, p := range []*fakeProvider{cache, src} {
+			_, _ = p.Push(ctx, h, torrent)
+			_, _ = p.PushManifest(ctx, h, []byte("manifest"))
+		}
+	}
+
+	purge := NewStore([]StoreProvider{cache, src}, nil)
+	var matches []*RescanMatch
+	stats, err := NewRescan(src, listedFake{src}, st, al, purge, 2).Run(ctx, func(m *RescanMatch) error {
+		matches = append(matches, m)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (RescanStats{Scanned: 3, Matched: 1, Allowlisted: 1, Purged: 1}) {
+		t.Fatalf("stats = %+v", stats)
+	}
+	bad := hashes["green apple"]
+	if len(matches) != 1 || matches[0].InfoHash != bad || matches[0].Name != "green apple" ||
+		!matches[0].Purged || matches[0].Version != st.Version() || len(matches[0].Stack) == 0 {
+		t.Fatalf("matches = %+v", matches)
+	}
+	for _, p := range []*fakeProvider{cache, src} {
+		if _, err = p.Pull(ctx, bad); err != ErrNotFound {
+			t.Fatalf("%v: match must be purged, err = %v", p.Name(), err)
+		}
+		if _, err = p.PullManifest(ctx, bad); err != ErrNotFound {
+			t.Fatalf("%v: manifest must be purged, err = %v", p.Name(), err)
+		}
+		for _, name := range []string{"ripe banana", "apple audiobook"} {
+			if _, err = p.Pull(ctx, hashes[name]); err != nil {
+				t.Fatalf("%v: %q must be kept, err = %v", p.Name(), name, err)
+			}
+		}
+	}
+}
+
+func TestRescanReportOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - apple\n")
+	st, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+	src := newFakeProvider("s3", false)
+	torrent := namedTorrent(t, "green apple")
+	_, _ = src.Push(ctx, "h1", torrent)
+
+	stats, err := NewRescan(src, listedFake{src}, st, nil, nil, 1).Run(ctx, func(m *RescanMatch) error {
+		if m.Purged {
+			t.Error("nothing must be purged without a purge store")
+		}
+		return nil
+	})
+	if err != nil || stats.Matched != 1 || stats.Purged != 0 {
+		t.Fatalf("stats = %+v, err = %v", stats, err)
+	}
+	if _, err = src.Pull(ctx, "h1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFilesRechecksStoplistOnManifestHit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - apple\n")
+	st, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	local := newFakeProvider("memory", true)
+	srv := &Server{s: NewStore([]StoreProvider{local}, &StoreConfig{Verdicts: true}), sl: st}
+	ctx := context.Background()
+	hashes := map[string]string{}
+	for _, name := range []string{"ripe banana", "red cherry"} {
+		torrent := namedTorrent(t, name)
+		mi, err := metainfo.Load(bytes.NewReader(torrent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := mi.HashInfoBytes().HexString()
+		hashes[name] = h
+		_, _ = local.Push(ctx, h, torrent)
+		if _, err = srv.files(ctx, h, "files"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A torrent matching the reloaded stoplist is refused although its
+	// manifest is cached.
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - banana\n")
+	if err = st.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = srv.files(ctx, hashes["ripe banana"], "files"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied", err)
+	}
+	if _, err = srv.files(ctx, hashes["red cherry"], "files"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A torrent purged by a rescan elsewhere isn't listed from a manifest
+	// left in a pod-local tier.
+	h := hashes["red cherry"]
+	local.mu.Lock()
+	delete(local.torrents, h)
+	delete(local.verdicts, h)
+	local.mu.Unlock()
+	srv.s.pullm.Drop(h)
+	if _, err = srv.files(ctx, h, "files"); status.Code(err) != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound", err)
+	}
+}
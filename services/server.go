@@ -10,23 +10,52 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	sl "github.com/webtor-io/stoplist"
 	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 )
 
-const defaultTrackersFlag = "default-trackers"
+const (
+	defaultTrackersFlag  = "default-trackers"
+	batchMaxSizeFlag     = "batch-max-size"
+	batchConcurrencyFlag = "batch-concurrency"
+	streamMaxSizeFlag    = "stream-max-size"
+)
 
 // RegisterServerFlags adds the default-trackers flag used by Server to
-// inject extra trackers into pushed torrents (respecting BEP-27 private).
+// inject extra trackers into pushed torrents (respecting BEP-27 private),
+// plus the limits applied to BatchPull/BatchFiles and PushStream.
 func RegisterServerFlags(f []cli.Flag) []cli.Flag {
-	return append(f, cli.StringFlag{
-		Name:   defaultTrackersFlag,
-		Usage:  "comma-separated tracker URLs appended to non-private torrents on Push (dedup'd against existing)",
-		Value:  "",
-		EnvVar: "DEFAULT_TRACKERS",
-	})
+	return append(f,
+		cli.StringFlag{
+			Name:   defaultTrackersFlag,
+			Usage:  "comma-separated tracker URLs appended to non-private torrents on Push (dedup'd against existing)",
+			Value:  "",
+			EnvVar: "DEFAULT_TRACKERS",
+		},
+		cli.IntFlag{
+			Name:   batchMaxSizeFlag,
+			Usage:  "max number of infoHashes accepted by a single batch request",
+			Value:  100,
+			EnvVar: "BATCH_MAX_SIZE",
+		},
+		cli.IntFlag{
+			Name:   batchConcurrencyFlag,
+			Usage:  "max number of batch items resolved concurrently per request",
+			Value:  8,
+			EnvVar: "BATCH_CONCURRENCY",
+		},
+		cli.IntFlag{
+			Name:   streamMaxSizeFlag,
+			Usage:  "max size of a torrent accepted by PushStream (MB)",
+			Value:  512,
+			EnvVar: "STREAM_MAX_SIZE",
+		},
+	)
 }
 
 // ParseDefaultTrackers reads --default-trackers (comma- or whitespace-separated) into a slice.
@@ -46,106 +75,298 @@ func ParseDefaultTrackers(c *cli.Context) []string {
 
 type Server struct {
 	pb.UnimplementedTorrentStoreServer
-	s               *Store
-	a               *Abuse
-	sl              *Stoplist
-	defaultTrackers []string
+	s                *Store
+	a                *Abuse
+	sl               *Stoplist
+	xsl              []*Stoplist
+	tb               *TrackerBlocklist
+	al               *Allowlist
+	rl               *RateLimit
+	defaultTrackers  []string
+	batchMaxSize     int
+	batchConcurrency int
+	streamMaxSize    int64
 }
 
-func NewServer(s *Store, a *Abuse, sl *Stoplist, defaultTrackers []string) *Server {
+func NewServer(c *cli.Context, s *Store, a *Abuse, sl *Stoplist, xsl []*Stoplist, tb *TrackerBlocklist, al *Allowlist, rl *RateLimit) *Server {
 	return &Server{
-		s:               s,
-		a:               a,
-		sl:              sl,
-		defaultTrackers: defaultTrackers,
+		s:                s,
+		a:                a,
+		sl:               sl,
+		xsl:              xsl,
+		tb:               tb,
+		al:               al,
+		rl:               rl,
+		defaultTrackers:  ParseDefaultTrackers(c),
+		batchMaxSize:     c.Int(batchMaxSizeFlag),
+		batchConcurrency: c.Int(batchConcurrencyFlag),
+		streamMaxSize:    int64(c.Int(streamMaxSizeFlag)) * 1024 * 1024,
 	}
 }
 
 func (s *Server) Pull(ctx context.Context, in *pb.PullRequest) (*pb.PullReply, error) {
+	torrent, err := s.pull(ctx, in.GetInfoHash(), "pull")
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PullReply{Torrent: torrent}, nil
+}
+
+// pull runs the abuse gate, the store lookup and the stoplist gate for a
+// single infoHash. Shared by Pull and BatchPull; method only labels logs.
+func (s *Server) pull(ctx context.Context, infoHash string, method string) ([]byte, error) {
 	t := time.Now()
 
-	hLog := log.WithField("infoHash", in.GetInfoHash()).WithField("method", "pull")
+	hLog := log.WithField("infoHash", infoHash).WithField("method", method)
 	hLog.Info("pull torrent request")
 
-	abused, err := s.isAbused(ctx, in.GetInfoHash())
-	if err != nil {
-		hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to check abuse")
-		return nil, errors.Wrapf(err, "failed to check abuse infoHash=%v", in.GetInfoHash())
+	if err := s.checkAbuse(ctx, hLog, t, infoHash); err != nil {
+		return nil, err
 	}
-	if abused {
-		hLog.WithField("duration", time.Since(t)).Warn("abused")
-		return nil, status.Errorf(codes.PermissionDenied, "restricted by the rightholder infoHash=%v", in.GetInfoHash())
+	if err := s.checkRateLimit(ctx, hLog, method, infoHash); err != nil {
+		return nil, err
 	}
-	torrent, err := s.s.Pull(ctx, in.GetInfoHash())
+	torrent, err := s.s.Pull(ctx, infoHash)
 	if errors.Is(err, ErrNotFound) {
+		s.rl.Spend(ctx, method, infoHash)
 		hLog.WithField("duration", time.Since(t)).Info("torrent not found")
-		return nil, status.Errorf(codes.NotFound, "unable to find torrent for infoHash=%v", in.GetInfoHash())
+		return nil, status.Errorf(codes.NotFound, "unable to find torrent for infoHash=%v", infoHash)
 	} else if err != nil {
 		hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to pull")
-		return nil, errors.Wrapf(err, "failed to pull torrent infoHash=%v", in.GetInfoHash())
+		return nil, errors.Wrapf(err, "failed to pull torrent infoHash=%v", infoHash)
 	}
-	err = s.checkStoplist(torrent, hLog, t, in.GetInfoHash())
+	_, err = s.checkStoplist(ctx, torrent, hLog, t, infoHash, true)
 	if err != nil {
 		return nil, err
 	}
 	hLog.WithField("len", len(torrent)).WithField("duration", time.Since(t)).Info("sending torrent response")
-	return &pb.PullReply{Torrent: []byte(torrent)}, nil
+	return torrent, nil
+}
+
+// checkStoplist rejects torrents matching the stoplist unless they are
+// allowlisted, then runs the stoplists of --stoplists. An allowlisted
+// infoHash skips the check altogether; name patterns are only consulted on
+// a match, so the hot path doesn't pay for parsing the torrent twice.
+// stored tells whether torrent is the stored copy of hash (pull, files)
+// rather than an incoming one (push), see stoplistVerdict. The returned
+// verdict is the one of the main stoplist, nil if it wasn't checked.
+func (s *Server) checkStoplist(ctx context.Context, torrent []byte, log *log.Entry, t time.Time, hash string, stored bool) (*StoplistVerdict, error) {
+	if s.sl == nil && len(s.xsl) == 0 {
+		return nil, nil
+	}
+	if e, ok := s.al.AllowedHash(hash); ok {
+		allowlistOverridesTotal.WithLabelValues(AllowlistKindHash).Inc()
+		log.WithField("author", e.Author).WithField("reason", e.Reason).Info("infoHash allowlisted, skipping stoplist")
+		return nil, nil
+	}
+	var v *StoplistVerdict
+	if s.sl != nil {
+		var err error
+		v, err = s.checkMainStoplist(ctx, torrent, log, t, hash, stored)
+		if err != nil || v.Found {
+			return v, err
+		}
+	}
+	return v, s.checkExtraStoplists(ctx, torrent, log, t, hash)
 }
 
-func (s *Server) checkStoplist(torrent []byte, log *log.Entry, t time.Time, hash string) error {
+// checkMainStoplist rejects torrent if the main stoplist matches it and its
+// name isn't allowlisted.
+func (s *Server) checkMainStoplist(ctx context.Context, torrent []byte, log *log.Entry, t time.Time, hash string, stored bool) (*StoplistVerdict, error) {
+	v, err := s.stoplistVerdict(ctx, torrent, hash, stored)
+	if err != nil {
+		log.WithField("duration", time.Since(t)).WithError(err).Error("failed to check stoplist")
+		return nil, errors.Wrapf(err, "failed to check stoplist infoHash=%v", hash)
+	}
+	if !v.Found {
+		return v, nil
+	}
+	cr := v.result()
+	if s.allowedName(torrent, log, cr) {
+		return v, nil
+	}
+	stoplistBlocksTotal.WithLabelValues(v.Rule, v.Field).Inc()
+	log.WithField("duration", time.Since(t)).Warnf("found in stoplist %v", cr.String())
+	return v, status.Errorf(codes.PermissionDenied, "found in stoplist infoHash=%v: %s", hash, cr.String())
+}
+
+// recheckStoplist runs the main stoplist again on a manifest cache hit: the
+// manifest may have been built under an older stoplist version, and pod-local
+// tiers keep serving it after a rescan purged the torrent elsewhere. A cached
+// clean verdict of the current version settles it without reading the
+// torrent. Otherwise the stored torrent is pulled and checked, so a torrent
+// purged from every tier reads as not found. The stoplists of --stoplists
+// only run when the manifest is built.
+func (s *Server) recheckStoplist(ctx context.Context, log *log.Entry, t time.Time, hash string) error {
 	if s.sl == nil {
 		return nil
 	}
-	cr, err := s.sl.Check(torrent)
+	if v, ok := s.s.Verdict(ctx, hash, s.sl.Version()); ok && !v.Found {
+		return nil
+	}
+	if _, ok := s.al.AllowedHash(hash); ok {
+		return nil
+	}
+	torrent, err := s.s.Pull(ctx, hash)
 	if err != nil {
-		log.WithField("duration", time.Since(t)).WithError(err).Error("failed to check stoplist")
-		return errors.Wrapf(err, "failed to check stoplist infoHash=%v", hash)
+		return err
 	}
-	if cr.Found {
-		log.WithField("duration", time.Since(t)).Warnf("found in stoplist %v", cr.String())
-		return status.Errorf(codes.PermissionDenied, "found in stoplist infoHash=%v: %s", hash, cr.String())
+	_, err = s.checkMainStoplist(ctx, torrent, log, t, hash, true)
+	return err
+}
+
+// allowedName tells whether a torrent a stoplist matched is allowlisted by
+// its name.
+func (s *Server) allowedName(torrent []byte, log *log.Entry, cr *sl.CheckResult) bool {
+	e, ok := s.al.AllowedName(torrent)
+	if !ok {
+		return false
+	}
+	allowlistOverridesTotal.WithLabelValues(AllowlistKindName).Inc()
+	log.WithField("pattern", e.Value).
+		WithField("author", e.Author).
+		WithField("reason", e.Reason).
+		Warnf("found in stoplist %v, allowed by name", cr.String())
+	return true
+}
+
+// checkExtraStoplists runs the stoplists of --stoplists in order. Their
+// verdicts aren't cached: they are meant for trying rules out on live
+// traffic. A flag or shadow stoplist failing to check the torrent never
+// fails the call. Flagged torrents are let through and the stoplists that
+// flagged them are listed in the x-stoplist-flags header of the reply.
+func (s *Server) checkExtraStoplists(ctx context.Context, torrent []byte, log *log.Entry, t time.Time, hash string) error {
+	var flags []string
+	for _, l := range s.xsl {
+		lLog := log.WithField("list", l.name).WithField("action", l.action)
+		cr, err := l.Check(torrent)
+		if err != nil {
+			lLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to check stoplist")
+			if l.action == StoplistActionBlock {
+				return errors.Wrapf(err, "failed to check stoplist %v infoHash=%v", l.name, hash)
+			}
+			continue
+		}
+		if !cr.Found || s.allowedName(torrent, lLog, cr) {
+			continue
+		}
+		switch l.action {
+		case StoplistActionBlock:
+			lLog.WithField("duration", time.Since(t)).Warnf("found in stoplist %v", cr.String())
+			return status.Errorf(codes.PermissionDenied, "found in stoplist %v infoHash=%v: %s", l.name, hash, cr.String())
+		case StoplistActionFlag:
+			lLog.Warnf("flagged by stoplist %v", cr.String())
+			flags = append(flags, l.name)
+		default:
+			lLog.Infof("found in shadow stoplist %v", cr.String())
+		}
+	}
+	if len(flags) > 0 {
+		// Fails outside of a gRPC call, there is no reply to mark then.
+		_ = grpc.SetHeader(ctx, metadata.Pairs(stoplistFlagHeader, strings.Join(flags, ",")))
+	}
+	return nil
+}
+
+// checkTrackers screens the tracker and web seed hosts of torrent against
+// the tracker blocklist. In reject mode a torrent announcing to a blocked
+// tracker is rejected. In strip mode push drops the blocked trackers itself
+// and a stored torrent is left as it is: the manifest carries no trackers,
+// and rewriting the stored copy here would race the read-merge-write of a
+// concurrent push.
+func (s *Server) checkTrackers(torrent []byte, log *log.Entry, t time.Time, hash string) error {
+	blocked, err := s.tb.blockedURLs(torrent)
+	if err != nil {
+		log.WithField("duration", time.Since(t)).WithError(err).Error("failed to check tracker blocklist")
+		return errors.Wrapf(err, "failed to check tracker blocklist infoHash=%v", hash)
+	}
+	if len(blocked) == 0 {
+		return nil
+	}
+	if !s.tb.strip {
+		trackerBlocklistTotal.WithLabelValues(TrackerActionReject).Inc()
+		log.WithField("duration", time.Since(t)).WithField("trackers", blocked).Warn("announces to blocked trackers")
+		return status.Errorf(codes.PermissionDenied, "announces to blocked trackers infoHash=%v: %v", hash, strings.Join(blocked, " "))
 	}
+	trackerBlocklistTotal.WithLabelValues(TrackerActionStrip).Inc()
+	log.WithField("trackers", blocked).Info("stripping blocked trackers")
 	return nil
 }
 
+// stoplistVerdict checks torrent against the stoplist. The verdict of a
+// stored torrent never changes for a given stoplist version: merges only add
+// trackers, which aren't screened. So with the verdict cache enabled it is
+// looked up in the cache first and cached after a check. An incoming
+// torrent may carry another comment than the stored copy and is always
+// checked.
+func (s *Server) stoplistVerdict(ctx context.Context, torrent []byte, hash string, stored bool) (*StoplistVerdict, error) {
+	if stored {
+		if v, ok := s.s.Verdict(ctx, hash, s.sl.Version()); ok {
+			return v, nil
+		}
+	}
+	v, err := s.sl.verdict(torrent)
+	if err != nil {
+		return nil, err
+	}
+	if stored {
+		s.s.PushVerdict(ctx, hash, v)
+	}
+	return v, nil
+}
+
 func (s *Server) Push(ctx context.Context, in *pb.PushRequest) (*pb.PushReply, error) {
+	infoHash, err := s.push(ctx, in.GetTorrent(), "push")
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PushReply{InfoHash: infoHash}, nil
+}
+
+// push gates, merges and stores a complete .torrent, returning its
+// infoHash. Shared by Push and PushStream; method only labels logs.
+func (s *Server) push(ctx context.Context, torrent []byte, method string) (string, error) {
 	t := time.Now()
-	reader := bytes.NewReader(in.GetTorrent())
+	reader := bytes.NewReader(torrent)
 	mi, err := metainfo.Load(reader)
 	if err != nil {
 		log.WithError(err).Error("failed to read torrent")
-		return nil, err
+		return "", err
 	}
 	infoHash := mi.HashInfoBytes().HexString()
-	hLog := log.WithField("infoHash", infoHash).WithField("method", "push")
+	hLog := log.WithField("infoHash", infoHash).WithField("method", method)
 	hLog.Info("push torrent request")
 
-	err = s.checkStoplist(in.GetTorrent(), hLog, t, infoHash)
+	verdict, err := s.checkStoplist(ctx, torrent, hLog, t, infoHash, false)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	abused, err := s.isAbused(ctx, infoHash)
+	err = s.checkTrackers(torrent, hLog, t, infoHash)
 	if err != nil {
-		hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to check abuse")
-		return nil, errors.Wrapf(err, "failed to check abuse infoHash=%v", infoHash)
+		return "", err
 	}
-	if abused {
-		hLog.WithField("duration", time.Since(t)).Warn("abused")
-		return nil, status.Errorf(codes.PermissionDenied, "restricted by the rightholder infoHash=%v", infoHash)
+
+	err = s.checkAbuse(ctx, hLog, t, infoHash)
+	if err != nil {
+		return "", err
 	}
 
-	payload := in.GetTorrent()
+	payload, _, err := stripTrackers(torrent, s.tb)
+	if err != nil {
+		hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to strip blocked trackers")
+		return "", errors.Wrapf(err, "failed to strip blocked trackers infoHash=%v", infoHash)
+	}
 	existing, err := s.s.pull(ctx, infoHash, 0)
 	if err != nil && !errors.Is(err, ErrNotFound) {
 		hLog.WithField("duration", time.Since(t)).WithError(err).Warn("failed to read existing for merge; pushing as-is")
 	} else if err == nil {
-		merged, changed, mErr := mergeTorrent(existing, payload, s.defaultTrackers)
+		merged, changed, mErr := mergeTorrent(existing, payload, s.defaultTrackers, s.tb)
 		if mErr != nil {
 			hLog.WithField("duration", time.Since(t)).WithError(mErr).Warn("failed to merge; pushing incoming as-is")
 		} else if !changed {
 			hLog.WithField("len", len(payload)).WithField("duration", time.Since(t)).Info("torrent already present, no new announces — skipping push")
-			return &pb.PushReply{InfoHash: infoHash}, nil
+			return infoHash, nil
 		} else {
 			payload = merged
 			hLog.WithField("merged_len", len(merged)).Info("merged announces from existing torrent")
@@ -155,36 +376,50 @@ func (s *Server) Push(ctx context.Context, in *pb.PushRequest) (*pb.PushReply, e
 	_, err = s.s.Push(ctx, infoHash, payload)
 	if err != nil {
 		hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to push")
-		return nil, errors.Wrapf(err, "failed to push torrent infoHash=%v", infoHash)
+		return "", errors.Wrapf(err, "failed to push torrent infoHash=%v", infoHash)
 	}
 	s.s.pullm.Drop(infoHash)
+	// A merge keeps the creation metadata of the stored copy, so its cached
+	// verdict still holds. Otherwise the intake verdict is the verdict of
+	// the stored copy now.
+	if verdict != nil && bytes.Equal(payload, torrent) {
+		s.s.PushVerdict(ctx, infoHash, verdict)
+	}
 
 	hLog.WithField("len", len(payload)).WithField("duration", time.Since(t)).Info("torrent succesfully pushed")
-	return &pb.PushReply{InfoHash: infoHash}, nil
+	return infoHash, nil
 }
 
 func (s *Server) Files(ctx context.Context, in *pb.FilesRequest) (*pb.FilesReply, error) {
+	return s.files(ctx, in.GetInfoHash(), "files")
+}
+
+// files resolves the cached manifest for a single infoHash behind the abuse
+// and stoplist gates. Shared by Files and BatchFiles; method only labels logs.
+func (s *Server) files(ctx context.Context, infoHash string, method string) (*pb.FilesReply, error) {
 	t := time.Now()
-	infoHash := in.GetInfoHash()
-	hLog := log.WithField("infoHash", infoHash).WithField("method", "files")
+	hLog := log.WithField("infoHash", infoHash).WithField("method", method)
 	hLog.Info("files manifest request")
 
 	// Abuse is the hard legal gate (CSAM etc.) and is checked on every call,
 	// including manifest cache hits, so a torrent banned after its manifest
 	// was cached stops being listable immediately.
-	abused, err := s.isAbused(ctx, infoHash)
-	if err != nil {
-		hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to check abuse")
-		return nil, errors.Wrapf(err, "failed to check abuse infoHash=%v", infoHash)
+	if err := s.checkAbuse(ctx, hLog, t, infoHash); err != nil {
+		return nil, err
 	}
-	if abused {
-		hLog.WithField("duration", time.Since(t)).Warn("abused")
-		return nil, status.Errorf(codes.PermissionDenied, "restricted by the rightholder infoHash=%v", infoHash)
+	if err := s.checkRateLimit(ctx, hLog, method, infoHash); err != nil {
+		return nil, err
 	}
 
+	built := false
 	manifest, err := s.s.Manifest(ctx, infoHash, func(torrent []byte) ([]byte, error) {
-		// Stoplist is enforced at build time, when we have the torrent bytes.
-		if serr := s.checkStoplist(torrent, hLog, t, infoHash); serr != nil {
+		built = true
+		// The stoplists are enforced at build time, when we have the torrent
+		// bytes; cache hits only re-check the main one, see recheckStoplist.
+		if _, serr := s.checkStoplist(ctx, torrent, hLog, t, infoHash, true); serr != nil {
+			return nil, serr
+		}
+		if serr := s.checkTrackers(torrent, hLog, t, infoHash); serr != nil {
 			return nil, serr
 		}
 		reply, berr := buildManifest(torrent)
@@ -193,7 +428,11 @@ func (s *Server) Files(ctx context.Context, in *pb.FilesRequest) (*pb.FilesReply
 		}
 		return proto.Marshal(reply)
 	})
+	if err == nil && !built {
+		err = s.recheckStoplist(ctx, hLog, t, infoHash)
+	}
 	if errors.Is(err, ErrNotFound) {
+		s.rl.Spend(ctx, method, infoHash)
 		hLog.WithField("duration", time.Since(t)).Info("torrent not found")
 		return nil, status.Errorf(codes.NotFound, "unable to find torrent for infoHash=%v", infoHash)
 	} else if st, ok := status.FromError(err); ok && st.Code() != codes.OK {
@@ -214,6 +453,31 @@ func (s *Server) Files(ctx context.Context, in *pb.FilesRequest) (*pb.FilesReply
 	return reply, nil
 }
 
+// checkAbuse is the hard legal gate (CSAM etc.): a torrent reported to the
+// abuse store is refused with PermissionDenied.
+func (s *Server) checkAbuse(ctx context.Context, log *log.Entry, t time.Time, hash string) error {
+	abused, err := s.isAbused(ctx, hash)
+	if err != nil {
+		log.WithField("duration", time.Since(t)).WithError(err).Error("failed to check abuse")
+		return errors.Wrapf(err, "failed to check abuse infoHash=%v", hash)
+	}
+	if abused {
+		log.WithField("duration", time.Since(t)).Warn("abused")
+		return status.Errorf(codes.PermissionDenied, "restricted by the rightholder infoHash=%v", hash)
+	}
+	return nil
+}
+
+// checkRateLimit refuses a lookup with ResourceExhausted once infoHash has
+// used up its miss budget for rpc.
+func (s *Server) checkRateLimit(ctx context.Context, log *log.Entry, rpc string, hash string) error {
+	if s.rl.Allow(ctx, rpc, hash) {
+		return nil
+	}
+	log.Warn("rate limited")
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded infoHash=%v", hash)
+}
+
 func (s *Server) isAbused(ctx context.Context, h string) (bool, error) {
 	if s.a == nil {
 		return false, nil
@@ -227,8 +491,12 @@ func (s *Server) Touch(ctx context.Context, in *pb.TouchRequest) (*pb.TouchReply
 	hLog := log.WithField("infoHash", infoHash).WithField("method", "touch")
 	hLog.Info("touch torrent request")
 
+	if err := s.checkRateLimit(ctx, hLog, "touch", infoHash); err != nil {
+		return nil, err
+	}
 	_, err := s.s.Touch(ctx, infoHash)
 	if errors.Is(err, ErrNotFound) {
+		s.rl.Spend(ctx, "touch", infoHash)
 		hLog.WithField("duration", time.Since(t)).Info("torrent not found")
 		return nil, status.Errorf(codes.NotFound, "torrent not found infoHash=%v", infoHash)
 	} else if err != nil {
@@ -239,3 +507,59 @@ func (s *Server) Touch(ctx context.Context, in *pb.TouchRequest) (*pb.TouchReply
 	hLog.WithField("duration", time.Since(t)).Info("sending touch reply")
 	return &pb.TouchReply{}, nil
 }
+
+func (s *Server) Check(ctx context.Context, in *pb.CheckRequest) (*pb.CheckReply, error) {
+	t := time.Now()
+	infoHash := in.GetInfoHash()
+	hLog := log.WithField("infoHash", infoHash).WithField("method", "check")
+	hLog.Info("check torrent request")
+
+	if err := s.checkAbuse(ctx, hLog, t, infoHash); err != nil {
+		return nil, err
+	}
+	if err := s.checkRateLimit(ctx, hLog, "check", infoHash); err != nil {
+		return nil, err
+	}
+
+	provider, err := s.s.Check(ctx, infoHash)
+	if errors.Is(err, ErrNotFound) {
+		s.rl.Spend(ctx, "check", infoHash)
+		hLog.WithField("duration", time.Since(t)).Info("torrent not found")
+		return &pb.CheckReply{Exists: false}, nil
+	} else if err != nil {
+		hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to check")
+		return nil, errors.Wrapf(err, "failed to check torrent infoHash=%v", infoHash)
+	}
+
+	hLog.WithField("provider", provider).WithField("duration", time.Since(t)).Info("sending check reply")
+	return &pb.CheckReply{Exists: true, Provider: provider}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteReply, error) {
+	t := time.Now()
+	infoHash := in.GetInfoHash()
+	hLog := log.WithField("infoHash", infoHash).WithField("method", "delete").WithField("author", adminIdentity(ctx))
+	hLog.Info("delete torrent request")
+
+	if infoHash == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "infoHash is required")
+	}
+
+	reply := &pb.DeleteReply{}
+	failed := 0
+	for _, r := range s.s.Delete(ctx, infoHash) {
+		tr := &pb.DeleteTierResult{Provider: r.Provider, Ok: r.Err == nil}
+		if r.Err != nil {
+			tr.Error = r.Err.Error()
+			failed++
+		}
+		reply.Tiers = append(reply.Tiers, tr)
+	}
+
+	if failed > 0 {
+		hLog.WithField("failed", failed).WithField("duration", time.Since(t)).Warn("torrent partially deleted")
+	} else {
+		hLog.WithField("duration", time.Since(t)).Info("torrent succesfully deleted")
+	}
+	return reply, nil
+}
@@ -2,20 +2,27 @@ package services
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"os"
 	"regexp"
 	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unicode/utf8"
 
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 	sl "github.com/webtor-io/stoplist"
+	"gopkg.in/yaml.v3"
 )
 
 // maxCommentRunes caps how much of the Comment field is fed into the
@@ -35,32 +42,55 @@ import (
 // tight enough to make the composite-FP very rare.
 const maxCommentRunes = 300
 
+// stoplistVersionLen is how many hex digits of the file SHA-256 identify a
+// stoplist version.
+const stoplistVersionLen = 16
+
 var (
 	re1 = regexp.MustCompile(`[^\p{L}\d]+`)
 	re2 = regexp.MustCompile(`(\d+)`)
 	re3 = regexp.MustCompile(`\s+`)
 
-	// stoplistBlocksTotal counts torrents rejected at intake by the
-	// abuse stoplist, labelled by which main-rule line fired. Pairs
-	// with the helmfile-managed stoplist.yaml — if `main:` rule order
-	// changes there, update mainRuleLabels below.
+	// stoplistBlocksTotal counts torrents rejected by the main stoplist,
+	// labelled by which main-rule line fired and by the torrent field it
+	// fired on. Matches that don't end in a rejection (allowlisted names,
+	// rescans) are only counted in stoplistActionMatchesTotal.
 	stoplistBlocksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "torrent_store_stoplist_blocks_total",
-		Help: "Torrents rejected at intake by the abuse stoplist, labelled by which main-rule line fired.",
-	}, []string{"rule"})
+		Help: "Torrents rejected by the main stoplist, labelled by which main-rule line fired and the torrent field it fired on.",
+	}, []string{"rule", "field"})
 
-	// mainRuleLabels maps the library's "line index N" Stack[0] to a
-	// human-readable Prometheus label. Index order MUST match the
-	// `main:` list in helmfile/values/torrent-store/stoplist.yaml.
-	mainRuleLabels = []string{
-		"stopwords",  // line 0: {stopwords}
-		"age_sexual", // line 1: {age}+{sexual}
-		"age_name",   // line 2: {age}+{name}
-	}
+	// stoplistPrefilterTotal counts data strings by prefilter outcome:
+	// miss (skipped), hit (confirmed by the checker) or false_hit (the
+	// checker found nothing), which quantifies the false-prefilter rate.
+	stoplistPrefilterTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_stoplist_prefilter_total",
+		Help: "Data strings run through the stoplist prefilter, labelled by outcome (miss, hit, false_hit).",
+	}, []string{"result"})
+	prefilterMisses    = stoplistPrefilterTotal.WithLabelValues("miss")
+	prefilterHits      = stoplistPrefilterTotal.WithLabelValues("hit")
+	prefilterFalseHits = stoplistPrefilterTotal.WithLabelValues("false_hit")
+
+	stoplistVersionInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "torrent_store_stoplist_version_info",
+		Help: "Always 1, labelled by stoplist and the checksum of its active file.",
+	}, []string{"list", "version"})
+
+	stoplistReloadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_stoplist_reloads_total",
+		Help: "Stoplist reloads after a file change, labelled by stoplist and outcome (ok, failed).",
+	}, []string{"list", "result"})
+
+	ruleRefRe   = regexp.MustCompile(`\{([^}]+)\}`)
+	ruleLabelRe = regexp.MustCompile(`[^a-z0-9]+`)
 )
 
 const (
-	StoplistPathFlag = "stoplist-path"
+	StoplistPathFlag           = "stoplist-path"
+	StoplistReloadIntervalFlag = "stoplist-reload-interval"
+	StoplistVerdictCacheFlag   = "stoplist-verdict-cache"
+	StoplistMatchLogFlag       = "stoplist-match-log"
+	StoplistsFlag              = "stoplists"
 )
 
 func RegisterStoplistFlags(f []cli.Flag) []cli.Flag {
@@ -71,12 +101,57 @@ func RegisterStoplistFlags(f []cli.Flag) []cli.Flag {
 			EnvVar: "STOPLIST_PATH",
 			Value:  "",
 		},
+		cli.IntFlag{
+			Name:   StoplistReloadIntervalFlag,
+			Usage:  "stoplist file check interval (sec), 0 disables reloading",
+			EnvVar: "STOPLIST_RELOAD_INTERVAL",
+			Value:  30,
+		},
+		cli.BoolFlag{
+			Name:   StoplistVerdictCacheFlag,
+			Usage:  "cache stoplist verdicts of stored torrents next to their manifests in the memory and redis tiers",
+			EnvVar: "STOPLIST_VERDICT_CACHE",
+		},
+		cli.StringFlag{
+			Name:   StoplistMatchLogFlag,
+			Usage:  "sample matches into the log at most N/period per rule, off disables",
+			EnvVar: "STOPLIST_MATCH_LOG",
+			Value:  "10/1m",
+		},
+		cli.StringFlag{
+			Name:   StoplistsFlag,
+			Usage:  "additional stoplists as name=action:path, action being block, flag or shadow",
+			EnvVar: "STOPLISTS",
+		},
 	)
 }
 
-type Stoplist struct {
+// stoplistRules is one compiled version of the stoplist file. It is never
+// mutated once built, so a reload just swaps the pointer and in-flight
+// checks finish against the version they started with.
+type stoplistRules struct {
 	c  sl.Checker
 	pf *prefilter
+	// labels names the main-rule lines, see mainRuleLabels.
+	labels []string
+	// version is a short checksum of the file the rules were built from.
+	version string
+}
+
+type Stoplist struct {
+	// name and action tell the stoplists of --stoplists apart from the main
+	// one, see NewExtraStoplists.
+	name   string
+	action string
+	path   string
+	rules  atomic.Pointer[stoplistRules]
+	// rejected is the version of the last file that failed to compile, so a
+	// broken file is reported once rather than on every poll.
+	rejected string
+	closeC   chan struct{}
+	wg       sync.WaitGroup
+	// samples limits the matches logged for auditors, nil logs none.
+	samples *matchSampler
 }
 
 func NewStoplist(c *cli.Context) (*Stoplist, error) {
@@ -84,23 +159,204 @@ func NewStoplist(c *cli.Context) (*Stoplist, error) {
 	if path == "" {
 		return nil, nil
 	}
-	ch, err := sl.NewRuleFromYamlFile(path)
+	samples, err := newMatchSamplerFromFlags(c)
 	if err != nil {
 		return nil, err
 	}
-	pf, err := newPrefilter(path)
+	s, err := newNamedStoplist(StoplistMainName, StoplistActionBlock, path, time.Duration(c.Int(StoplistReloadIntervalFlag))*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	s.samples = samples
+	return s, nil
+}
+
+func newStoplist(path string, interval time.Duration) (*Stoplist, error) {
+	return newNamedStoplist(StoplistMainName, StoplistActionBlock, path, interval)
+}
+
+func newNamedStoplist(name, action, path string, interval time.Duration) (*Stoplist, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read stoplist %v %q", name, path)
+	}
+	r, err := compileStoplist(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load stoplist %v", name)
+	}
+	s := &Stoplist{
+		name:   name,
+		action: action,
+		path:   path,
+		closeC: make(chan struct{}),
+	}
+	s.swap(r)
+	log.WithField("list", name).WithField("action", action).WithField("version", r.version).Info("stoplist loaded")
+	if interval > 0 {
+		s.wg.Add(1)
+		go s.watch(interval)
+	}
+	return s, nil
+}
+
+// newStaticStoplist wraps already compiled rules, for benchmarks and tests.
+func newStaticStoplist(c sl.Checker, pf *prefilter) *Stoplist {
+	s := &Stoplist{name: StoplistMainName, action: StoplistActionBlock, closeC: make(chan struct{})}
+	s.rules.Store(&stoplistRules{c: c, pf: pf})
+	return s
+}
+
+func compileStoplist(raw []byte) (*stoplistRules, error) {
+	sum := sha256.Sum256(raw)
+	version := hex.EncodeToString(sum[:])[:stoplistVersionLen]
+	c, err := sl.NewRuleFromYaml(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compile stoplist version=%v", version)
+	}
+	labels, err := mainRuleLabels(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compile stoplist version=%v", version)
+	}
+	pf, err := parsePrefilter(raw)
 	if err != nil {
 		// Prefilter failure is non-fatal — the slow path still
 		// works correctly, we just don't get the speedup.
+		log.WithError(err).WithField("version", version).Warn("failed to build stoplist prefilter")
 		pf = nil
 	}
-	return &Stoplist{
-		c:  ch,
-		pf: pf,
-	}, nil
+	return &stoplistRules{c: c, pf: pf, labels: labels, version: version}, nil
+}
+
+// mainRuleLabels derives a Prometheus label for every `main:` line from the
+// sections it references, so `{age}+{sexual}` is labelled age_sexual and
+// reordering or adding lines needs no code change. A line without
+// references is labelled line_N, as is the second of two lines referencing
+// the same sections.
+func mainRuleLabels(raw []byte) ([]string, error) {
+	var sections struct {
+		Main []string `yaml:"main"`
+	}
+	if err := yaml.Unmarshal(raw, &sections); err != nil {
+		return nil, errors.Wrap(err, "failed to parse stoplist yaml for rule labels")
+	}
+	labels := make([]string, len(sections.Main))
+	seen := map[string]struct{}{}
+	for i, line := range sections.Main {
+		var refs []string
+		for _, m := range ruleRefRe.FindAllStringSubmatch(line, -1) {
+			refs = append(refs, m[1])
+		}
+		label := strings.Trim(ruleLabelRe.ReplaceAllString(strings.ToLower(strings.Join(refs, "_")), "_"), "_")
+		if _, dup := seen[label]; dup || label == "" {
+			label = fmt.Sprintf("line_%d", i)
+		}
+		seen[label] = struct{}{}
+		labels[i] = label
+	}
+	return labels, nil
+}
+
+func (s *Stoplist) swap(r *stoplistRules) {
+	s.rules.Store(r)
+	stoplistVersionInfo.DeletePartialMatch(prometheus.Labels{"list": s.name})
+	stoplistVersionInfo.WithLabelValues(s.name, r.version).Set(1)
+}
+
+// watch polls the file checksum rather than relying on inotify: the
+// Kubernetes ConfigMap mounts the stoplist ships in are updated by swapping
+// a symlinked directory, which file watches easily lose track of.
+func (s *Stoplist) watch(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.closeC:
+			return
+		}
+		_ = s.reload()
+	}
+}
+
+// reload recompiles the stoplist if the file changed. An unreadable or
+// invalid file is rejected and the current version stays active.
+func (s *Stoplist) reload() error {
+	raw, err := os.ReadFile(s.path)
+	if err != nil {
+		stoplistReloadsTotal.WithLabelValues(s.name, "failed").Inc()
+		log.WithError(err).WithField("list", s.name).WithField("version", s.Version()).Error("failed to read stoplist, keeping current version")
+		return errors.Wrapf(err, "failed to read stoplist %q", s.path)
+	}
+	sum := sha256.Sum256(raw)
+	version := hex.EncodeToString(sum[:])[:stoplistVersionLen]
+	if version == s.Version() || version == s.rejected {
+		return nil
+	}
+	t := time.Now()
+	r, err := compileStoplist(raw)
+	if err != nil {
+		s.rejected = version
+		stoplistReloadsTotal.WithLabelValues(s.name, "failed").Inc()
+		log.WithError(err).WithField("list", s.name).WithField("version", s.Version()).Error("rejected stoplist, keeping current version")
+		return err
+	}
+	old := s.Version()
+	s.swap(r)
+	s.rejected = ""
+	stoplistReloadsTotal.WithLabelValues(s.name, "ok").Inc()
+	log.WithField("list", s.name).
+		WithField("version", r.version).
+		WithField("previous", old).
+		WithField("duration", time.Since(t)).
+		Info("stoplist reloaded")
+	return nil
+}
+
+// Version returns the checksum of the active stoplist file.
+func (s *Stoplist) Version() string {
+	if s == nil {
+		return ""
+	}
+	return s.rules.Load().version
+}
+
+// Close stops watching the stoplist file.
+func (s *Stoplist) Close() {
+	if s == nil {
+		return
+	}
+	close(s.closeC)
+	s.wg.Wait()
+}
+
+// Torrent fields a stoplist data string is taken from.
+const (
+	StoplistFieldName      = "name"
+	StoplistFieldPath      = "path"
+	StoplistFieldComment   = "comment"
+	StoplistFieldCreatedBy = "createdBy"
+)
+
+// stoplistData is a data string along with the torrent field it came from.
+type stoplistData struct {
+	field string
+	text  string
 }
 
 func (s *Stoplist) getData(b []byte) ([]string, error) {
+	fields, err := s.getFields(b)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]string, len(fields))
+	for i, f := range fields {
+		data[i] = f.text
+	}
+	return data, nil
+}
+
+func (s *Stoplist) getFields(b []byte) ([]stoplistData, error) {
 	reader := bytes.NewReader(b)
 	mi, err := metainfo.Load(reader)
 	if err != nil {
@@ -110,14 +366,14 @@ func (s *Stoplist) getData(b []byte) ([]string, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to unmarshal torrent info")
 	}
-	var data []string
-	data = append(data, i.Name)
+	var data []stoplistData
+	data = append(data, stoplistData{StoplistFieldName, i.Name})
 	for _, file := range i.Files {
 		path := file.PathUtf8
 		if path == nil {
 			path = file.Path
 		}
-		data = append(data, strings.Join(path, " "))
+		data = append(data, stoplistData{StoplistFieldPath, strings.Join(path, " ")})
 	}
 	// Comment + creator — CSAM-distribution torrents often have
 	// neutral filenames but advertise the source forum through the
@@ -135,10 +391,10 @@ func (s *Stoplist) getData(b []byte) ([]string, error) {
 	// tracker list was the only signal; all matches fired on
 	// name/paths/comment.
 	if mi.Comment != "" {
-		data = append(data, truncateRunes(mi.Comment, maxCommentRunes))
+		data = append(data, stoplistData{StoplistFieldComment, truncateRunes(mi.Comment, maxCommentRunes)})
 	}
 	if mi.CreatedBy != "" {
-		data = append(data, mi.CreatedBy)
+		data = append(data, stoplistData{StoplistFieldCreatedBy, mi.CreatedBy})
 	}
 	return data, nil
 }
@@ -174,35 +430,81 @@ func truncateRunes(s string, max int) string {
 // found/not-found and a Prometheus rule-label, so the indeterminism
 // is acceptable.
 func (s *Stoplist) Check(b []byte) (*sl.CheckResult, error) {
-	data, err := s.getData(b)
+	cr, _, err := s.CheckVersion(b)
+	return cr, err
+}
+
+// CheckVersion is Check that also reports the version of the stoplist the
+// verdict was reached with, as a reload may swap it at any moment.
+func (s *Stoplist) CheckVersion(b []byte) (*sl.CheckResult, string, error) {
+	v, err := s.verdict(b)
+	if err != nil {
+		return nil, "", err
+	}
+	return v.result(), v.Version, nil
+}
+
+// verdict checks b and labels a match with its main rule and field, so
+// that the caller can count the block once it actually rejects the
+// torrent.
+func (s *Stoplist) verdict(b []byte) (*StoplistVerdict, error) {
+	r := s.rules.Load()
+	data, err := s.getFields(b)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get torrent text data")
 	}
 	if len(data) == 0 {
-		return &sl.CheckResult{}, nil
+		return &StoplistVerdict{Version: r.version}, nil
 	}
+	var h *stoplistHit
 	if len(data) == 1 {
 		// One-shot: skip the goroutine overhead.
-		return s.checkOne(data[0]), nil
+		h = s.checkOne(r, data[0])
+	} else {
+		h = s.checkParallel(r, data)
 	}
-	return s.checkParallel(data), nil
+	if h == nil {
+		return &StoplistVerdict{Version: r.version}, nil
+	}
+	rule := r.ruleLabel(h.cr)
+	s.countMatch(r, h, rule)
+	return &StoplistVerdict{
+		Version: r.version,
+		Found:   true,
+		Stack:   h.cr.Stack,
+		Rule:    rule,
+		Field:   h.d.field,
+	}, nil
+}
+
+// stoplistHit is the data string a check fired on.
+type stoplistHit struct {
+	d    stoplistData
+	norm string
+	cr   *sl.CheckResult
 }
 
 // checkOne runs the cheap prefilter (one combined RE2 regex over all
 // leaf patterns) and only falls through to the expensive sl.Checker
 // on a hit. Shared between the one-shot fast path and the parallel
-// worker.
-func (s *Stoplist) checkOne(d string) *sl.CheckResult {
-	norm := s.normalize(d)
-	if !s.pf.check(norm) {
-		return &sl.CheckResult{}
+// worker. Returns nil if no rule fires.
+func (s *Stoplist) checkOne(r *stoplistRules, d stoplistData) *stoplistHit {
+	norm := s.normalize(d.text)
+	if !r.pf.check(norm) {
+		prefilterMisses.Inc()
+		return nil
+	}
+	cr := r.c.Check(norm)
+	if !cr.Found {
+		if r.pf != nil {
+			prefilterFalseHits.Inc()
+		}
+		return nil
 	}
-	cr := s.c.Check(norm)
-	if cr.Found {
-		stoplistBlocksTotal.WithLabelValues(ruleLabel(cr)).Inc()
-		return cr
+	if r.pf != nil {
+		prefilterHits.Inc()
 	}
-	return &sl.CheckResult{}
+	return &stoplistHit{d: d, norm: norm, cr: cr}
 }
 
 // checkParallel spawns a worker pool sized to GOMAXPROCS (bounded by
@@ -211,19 +513,19 @@ func (s *Stoplist) checkOne(d string) *sl.CheckResult {
 // positive match closes `done`, every other worker sees the flag and
 // returns. The result is written to a buffered channel so the winning
 // worker never blocks.
-func (s *Stoplist) checkParallel(data []string) *sl.CheckResult {
+func (s *Stoplist) checkParallel(r *stoplistRules, data []stoplistData) *stoplistHit {
 	workers := runtime.GOMAXPROCS(0)
 	if workers > len(data) {
 		workers = len(data)
 	}
-	jobs := make(chan string, len(data))
+	jobs := make(chan stoplistData, len(data))
 	for _, d := range data {
 		jobs <- d
 	}
 	close(jobs)
 
 	var done atomic.Bool
-	result := make(chan *sl.CheckResult, 1)
+	result := make(chan *stoplistHit, 1)
 	var wg sync.WaitGroup
 	wg.Add(workers)
 	for i := 0; i < workers; i++ {
@@ -233,14 +535,9 @@ func (s *Stoplist) checkParallel(data []string) *sl.CheckResult {
 				if done.Load() {
 					return
 				}
-				norm := s.normalize(d)
-				if !s.pf.check(norm) {
-					continue
-				}
-				cr := s.c.Check(norm)
-				if cr.Found {
+				if h := s.checkOne(r, d); h != nil {
 					if done.CompareAndSwap(false, true) {
-						result <- cr
+						result <- h
 					}
 					return
 				}
@@ -249,30 +546,151 @@ func (s *Stoplist) checkParallel(data []string) *sl.CheckResult {
 	}
 	wg.Wait()
 	select {
-	case cr := <-result:
-		stoplistBlocksTotal.WithLabelValues(ruleLabel(cr)).Inc()
-		return cr
+	case h := <-result:
+		return h
 	default:
-		return &sl.CheckResult{}
+		return nil
+	}
+}
+
+// countMatch counts the match and samples it into the log for auditors,
+// within the --stoplist-match-log budget of its rule. Whether a match of a
+// block stoplist ends in a rejection is up to the caller, see
+// stoplistBlocksTotal.
+func (s *Stoplist) countMatch(r *stoplistRules, h *stoplistHit, rule string) {
+	stoplistActionMatchesTotal.WithLabelValues(s.name, s.action, rule, h.d.field).Inc()
+	if !s.samples.sample(rule) {
+		return
 	}
+	log.WithField("list", s.name).
+		WithField("action", s.action).
+		WithField("version", r.version).
+		WithField("rule", rule).
+		WithField("field", h.d.field).
+		WithField("data", h.d.text).
+		WithField("normalized", h.norm).
+		WithField("sections", ruleSections(h.cr)).
+		WithField("stack", h.cr.Stack).
+		Info("stoplist match sample")
 }
 
 // ruleLabel extracts a human-readable Prometheus label from the
 // stoplist library's CheckResult. Stack[0] for a main-rule match is
 // always "line index N" (see github.com/webtor-io/stoplist lineRule
-// implementation); we map N to a friendly label via mainRuleLabels.
-func ruleLabel(cr *sl.CheckResult) string {
+// implementation), unless `main:` has a single line; we map N to its
+// label derived by mainRuleLabels.
+func (r *stoplistRules) ruleLabel(cr *sl.CheckResult) string {
 	if cr == nil || !cr.Found || len(cr.Stack) == 0 {
 		return "unknown"
 	}
 	var idx int
 	if _, err := fmt.Sscanf(cr.Stack[0], "line index %d", &idx); err != nil {
+		if len(r.labels) == 1 {
+			return r.labels[0]
+		}
 		return "unknown"
 	}
-	if idx < 0 || idx >= len(mainRuleLabels) {
+	if idx < 0 || idx >= len(r.labels) {
 		return fmt.Sprintf("line_%d", idx)
 	}
-	return mainRuleLabels[idx]
+	return r.labels[idx]
+}
+
+// matchSampler lets through a few matches per rule and period.
+type matchSampler struct {
+	tb *TokenBucket
+	l  Limit
+}
+
+// newMatchSamplerFromFlags returns the sampler configured by
+// --stoplist-match-log, nil if sampling is off.
+func newMatchSamplerFromFlags(c *cli.Context) (*matchSampler, error) {
+	v := c.String(StoplistMatchLogFlag)
+	if v == "" || v == "off" {
+		return nil, nil
+	}
+	l, err := parseLimit(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse --%v", StoplistMatchLogFlag)
+	}
+	return newMatchSampler(*l), nil
+}
+
+func newMatchSampler(l Limit) *matchSampler {
+	return &matchSampler{tb: NewTokenBucket(), l: l}
+}
+
+func (s *matchSampler) sample(rule string) bool {
+	if s == nil {
+		return false
+	}
+	ok, _ := s.tb.Allow(context.Background(), rule, s.l)
+	if ok {
+		_ = s.tb.Spend(context.Background(), rule, s.l)
+	}
+	return ok
+}
+
+// StoplistMatch is a single data string of a torrent matching the stoplist.
+type StoplistMatch struct {
+	Field      string
+	Data       string
+	Normalized string
+	// Rule is the main-rule label, as in torrent_store_stoplist_blocks_total.
+	Rule string
+	// Sections lists the YAML sections referenced on the way to the match,
+	// outermost first.
+	Sections []string
+	Stack    []string
+}
+
+// Explain runs every data string of the torrent through the full rule tree
+// and returns all matches along with the stoplist version used. Unlike
+// Check it doesn't stop at the first match nor skip strings the prefilter
+// rejects, and doesn't count blocks, so it is meant for moderators rather
+// than the hot path.
+func (s *Stoplist) Explain(b []byte) ([]StoplistMatch, string, error) {
+	data, err := s.getFields(b)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to get torrent text data")
+	}
+	r := s.rules.Load()
+	var matches []StoplistMatch
+	for _, d := range data {
+		norm := s.normalize(d.text)
+		cr := r.c.Check(norm)
+		if !cr.Found {
+			continue
+		}
+		matches = append(matches, StoplistMatch{
+			Field:      d.field,
+			Data:       d.text,
+			Normalized: norm,
+			Rule:       r.ruleLabel(cr),
+			Sections:   ruleSections(cr),
+			Stack:      cr.Stack,
+		})
+	}
+	return matches, r.version, nil
+}
+
+// ruleSections extracts the section names from the `reference "x"` entries
+// of the stack.
+func ruleSections(cr *sl.CheckResult) []string {
+	var sections []string
+	seen := map[string]struct{}{}
+	for _, e := range cr.Stack {
+		var name string
+		if _, err := fmt.Sscanf(e, "reference %q", &name); err != nil {
+			continue
+		}
+		if _, dup := seen[name]; dup {
+			continue
+		}
+		seen[name] = struct{}{}
+		sections = append(sections, name)
+	}
+	return sections
 }
 
 func (s *Stoplist) normalize(str string) string {
@@ -31,7 +31,7 @@ func loadBenchStoplist(b *testing.B) *Stoplist {
 	if err != nil {
 		b.Fatalf("load prefilter: %v", err)
 	}
-	return &Stoplist{c: c, pf: pf}
+	return newStaticStoplist(c, pf)
 }
 
 func loadBenchTorrent(b *testing.B) []byte {
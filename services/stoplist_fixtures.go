@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// StoplistFixture is a stoplist rule test case: a text or a .torrent file
+// along with the verdict the stoplist is expected to reach on it.
+type StoplistFixture struct {
+	Name string `yaml:"name"`
+	// Text is checked as a single data string, like a torrent name.
+	Text string `yaml:"text"`
+	// Torrent is the path of a .torrent file, relative to the fixtures
+	// file. All of its data strings are checked.
+	Torrent string `yaml:"torrent"`
+	Found   bool   `yaml:"found"`
+	// Rule is the expected main-rule label of a match, as in
+	// torrent_store_stoplist_blocks_total. Empty skips the comparison.
+	Rule string `yaml:"rule"`
+}
+
+func (f *StoplistFixture) label() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	if f.Torrent != "" {
+		return f.Torrent
+	}
+	return fmt.Sprintf("%q", f.Text)
+}
+
+// StoplistFixtureFailure is a fixture the stoplist got wrong.
+type StoplistFixtureFailure struct {
+	Fixture StoplistFixture
+	Found   bool
+	Rule    string
+	// PrefilterMiss is a data string the prefilter skips although the
+	// full checker matches it, so the hot path lets it through.
+	PrefilterMiss string
+	Err           error
+}
+
+func (f *StoplistFixtureFailure) String() string {
+	switch {
+	case f.Err != nil:
+		return fmt.Sprintf("ERROR %v: %v", f.Fixture.label(), f.Err)
+	case f.PrefilterMiss != "":
+		return fmt.Sprintf("PREFILTER MISS %v: %q matches but is skipped by the prefilter", f.Fixture.label(), f.PrefilterMiss)
+	default:
+		return fmt.Sprintf("FAIL %v: want found=%v rule=%q, got found=%v rule=%q",
+			f.Fixture.label(), f.Fixture.Found, f.Fixture.Rule, f.Found, f.Rule)
+	}
+}
+
+// LoadStoplistFixtures reads a YAML list of fixtures. Torrent paths are
+// resolved against the directory of the fixtures file.
+func LoadStoplistFixtures(path string) ([]StoplistFixture, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read fixtures %q", path)
+	}
+	var fixtures []StoplistFixture
+	if err = yaml.Unmarshal(raw, &fixtures); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse fixtures %q", path)
+	}
+	for i := range fixtures {
+		f := &fixtures[i]
+		if (f.Text == "") == (f.Torrent == "") {
+			return nil, errors.Errorf("fixture %v of %q needs either text or torrent", i, path)
+		}
+		if f.Torrent != "" && !filepath.IsAbs(f.Torrent) {
+			f.Torrent = filepath.Join(filepath.Dir(path), f.Torrent)
+		}
+	}
+	return fixtures, nil
+}
+
+// TestFixtures runs every fixture through the same getData, normalize,
+// prefilter and checker pipeline as Check, and returns the fixtures whose
+// verdict or rule differ from the expected ones. Every data string is also
+// run through the checker alone, so a prefilter miss is reported even if
+// another string still gets the torrent blocked. Unlike Check, data strings
+// are checked in order, so the reported rule is deterministic, and blocks
+// aren't counted.
+func (s *Stoplist) TestFixtures(fixtures []StoplistFixture) []StoplistFixtureFailure {
+	r := s.rules.Load()
+	var failures []StoplistFixtureFailure
+	for _, f := range fixtures {
+		data := []string{f.Text}
+		if f.Torrent != "" {
+			b, err := os.ReadFile(f.Torrent)
+			if err == nil {
+				data, err = s.getData(b)
+			}
+			if err != nil {
+				failures = append(failures, StoplistFixtureFailure{Fixture: f, Err: err})
+				continue
+			}
+		}
+		found, rule := false, ""
+		for _, d := range data {
+			norm := s.normalize(d)
+			cr := r.c.Check(norm)
+			if !cr.Found {
+				continue
+			}
+			if !r.pf.check(norm) {
+				failures = append(failures, StoplistFixtureFailure{Fixture: f, PrefilterMiss: d})
+				continue
+			}
+			if !found {
+				found, rule = true, r.ruleLabel(cr)
+			}
+		}
+		if found != f.Found || (f.Found && f.Rule != "" && rule != f.Rule) {
+			failures = append(failures, StoplistFixtureFailure{Fixture: f, Found: found, Rule: rule})
+		}
+	}
+	return failures
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoplistFixtures(t *testing.T) {
+	dir := t.TempDir()
+	yaml := "main:\n  - \"{bad}\"\n  - \"{other}\"\nbad:\n  - apple\n  - /cherr(y|ies)/\nother:\n  - durian\n"
+	path := filepath.Join(dir, "stoplist.yaml")
+	writeStoplist(t, path, yaml)
+	if err := os.WriteFile(filepath.Join(dir, "apple.torrent"), namedTorrent(t, "Green Apple"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fixtures := filepath.Join(dir, "fixtures.yaml")
+	if err := os.WriteFile(fixtures, []byte(`
+- name: text match
+  text: "Red-Cherries!"
+  found: true
+  rule: bad
+- torrent: apple.torrent
+  found: true
+- name: clean
+  text: ripe banana
+  found: false
+- name: wrong verdict
+  text: ripe banana
+  found: true
+- name: wrong rule
+  text: apple
+  found: true
+  rule: age_sexual
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fx, err := LoadStoplistFixtures(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	failures := st.TestFixtures(fx)
+	if len(failures) != 2 || failures[0].Fixture.Name != "wrong verdict" || failures[1].Fixture.Name != "wrong rule" ||
+		failures[1].Rule != "bad" {
+		t.Fatalf("failures = %+v", failures)
+	}
+
+	// A prefilter built without the regex leaf skips what the checker
+	// matches.
+	r, err := compileStoplist([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := parsePrefilter([]byte("main:\n  - \"{bad}\"\n  - \"{other}\"\nbad:\n  - apple\nother:\n  - durian\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures = newStaticStoplist(r.c, pf).TestFixtures(fx[:1])
+	if len(failures) != 2 || !strings.Contains(failures[0].String(), "PREFILTER MISS") || failures[1].Found {
+		t.Fatalf("failures = %+v", failures)
+	}
+
+	if _, err = LoadStoplistFixtures(writeFixtures(t, dir, "- name: empty\n  found: true\n")); err == nil {
+		t.Fatal("fixture without text or torrent must be rejected")
+	}
+}
+
+func writeFixtures(t *testing.T, dir, yaml string) string {
+	path := filepath.Join(dir, "bad-fixtures.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -50,6 +50,12 @@ func newPrefilter(path string) (*prefilter, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to read stoplist for prefilter %q", path)
 	}
+	return parsePrefilter(raw)
+}
+
+// parsePrefilter builds the prefilter from the stoplist YAML itself, so a
+// reload compiles the checker and the prefilter from the same bytes.
+func parsePrefilter(raw []byte) (*prefilter, error) {
 	var sections map[string][]string
 	if err := yaml.Unmarshal(raw, &sections); err != nil {
 		return nil, errors.Wrap(err, "failed to parse stoplist yaml for prefilter")
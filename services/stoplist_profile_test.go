@@ -30,7 +30,7 @@ func BenchmarkPhases(b *testing.B) {
 	if err != nil {
 		b.Fatal(err)
 	}
-	s := &Stoplist{c: checker, pf: pf}
+	s := newStaticStoplist(checker, pf)
 	data, err := s.getData(torrent)
 	if err != nil {
 		b.Fatal(err)
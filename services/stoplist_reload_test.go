@@ -0,0 +1,162 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func writeStoplist(t *testing.T, path, yaml string) {
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func namedTorrent(t *testing.T, name string) []byte {
+	info, err := bencode.Marshal(metainfo.Info{Name: name, PieceLength: 16384, Length: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := bencode.Marshal(metainfo.MetaInfo{InfoBytes: info})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func checkFound(t *testing.T, s *Stoplist, name string) bool {
+	cr, err := s.Check(namedTorrent(t, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cr.Found
+}
+
+func TestStoplistReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - apple\n")
+	s, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	v1 := s.Version()
+	if !checkFound(t, s, "green apple") || checkFound(t, s, "ripe banana") {
+		t.Fatal("unexpected verdict for the initial version")
+	}
+
+	// Unchanged file: nothing to do.
+	if err = s.reload(); err != nil || s.Version() != v1 {
+		t.Fatalf("err = %v, version = %v", err, s.Version())
+	}
+
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - banana\n")
+	if err = s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Version() == v1 {
+		t.Fatal("version must change with the file")
+	}
+	if checkFound(t, s, "green apple") || !checkFound(t, s, "ripe banana") {
+		t.Fatal("unexpected verdict for the reloaded version")
+	}
+}
+
+func TestStoplistReloadKeepsVersionOnInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - apple\n")
+	s, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	v1 := s.Version()
+
+	for _, yaml := range []string{"bad:\n  - apple\n", "main: [\n"} {
+		writeStoplist(t, path, yaml)
+		if err = s.reload(); err == nil {
+			t.Fatalf("%q: expected error", yaml)
+		}
+		if s.Version() != v1 || !checkFound(t, s, "green apple") {
+			t.Fatalf("%q: previous version must stay active", yaml)
+		}
+		// The same broken file is reported once.
+		if err = s.reload(); err != nil {
+			t.Fatalf("%q: err = %v on repeated poll", yaml, err)
+		}
+	}
+
+	if err = os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.reload(); err == nil || s.Version() != v1 {
+		t.Fatalf("err = %v, version = %v", err, s.Version())
+	}
+}
+
+func TestStoplistWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - apple\n")
+	s, err := newStoplist(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - banana\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for !checkFound(t, s, "ripe banana") {
+		if time.Now().After(deadline) {
+			t.Fatal("stoplist was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStoplistRuleLabels(t *testing.T) {
+	labels, err := mainRuleLabels([]byte("main:\n  - \"{stopwords}\"\n  - \"{age}+{sexual}\"\n  - \"{Age} + {name}\"\n  - \"{age}+{sexual}\"\n  - literal\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"stopwords", "age_sexual", "age_name", "line_3", "line_4"}
+	if strings.Join(labels, ",") != strings.Join(want, ",") {
+		t.Fatalf("labels = %v, want %v", labels, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\n  - \"{worse}\"\nbad:\n  - apple\nworse:\n  - cherry\n")
+	s, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.samples = newMatchSampler(Limit{Burst: 1, Period: time.Hour})
+	matches := stoplistActionMatchesTotal.WithLabelValues(StoplistMainName, StoplistActionBlock, "worse", StoplistFieldName)
+	before, misses := counterValue(t, matches), counterValue(t, prefilterMisses)
+	if !checkFound(t, s, "red cherry") || checkFound(t, s, "ripe banana") {
+		t.Fatal("unexpected verdict")
+	}
+	if got := counterValue(t, matches) - before; got != 1 {
+		t.Fatalf("matches = %v, want 1", got)
+	}
+	if got := counterValue(t, prefilterMisses) - misses; got != 1 {
+		t.Fatalf("prefilter misses = %v, want 1", got)
+	}
+	if !s.samples.sample("other") || s.samples.sample("worse") {
+		t.Fatal("the only sample of a rule per period must be spent by its first match")
+	}
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
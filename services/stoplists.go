@@ -0,0 +1,113 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/urfave/cli"
+)
+
+// StoplistMainName is the name of the stoplist at --stoplist-path.
+const StoplistMainName = "main"
+
+// What happens to a torrent a stoplist matches.
+const (
+	// StoplistActionBlock rejects it with PermissionDenied.
+	StoplistActionBlock = "block"
+	// StoplistActionFlag lets it through, counts and logs the match and
+	// marks the reply with the stoplistFlagHeader header.
+	StoplistActionFlag = "flag"
+	// StoplistActionShadow only counts and logs the match, to try new
+	// rules against real traffic before promoting them.
+	StoplistActionShadow = "shadow"
+)
+
+// stoplistFlagHeader lists the stoplists that flagged the torrent of a
+// reply.
+const stoplistFlagHeader = "x-stoplist-flags"
+
+var (
+	stoplistActionMatchesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_stoplist_action_matches_total",
+		Help: "Stoplist matches, whether or not they end in a rejection, labelled by stoplist, action (block, flag, shadow), main-rule line and torrent field.",
+	}, []string{"list", "action", "rule", "field"})
+)
+
+type extraStoplist struct {
+	name   string
+	action string
+	path   string
+}
+
+// parseExtraStoplists reads "name=action:path" entries.
+func parseExtraStoplists(raw string) ([]extraStoplist, error) {
+	var lists []extraStoplist
+	seen := map[string]struct{}{StoplistMainName: {}}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("malformed entry %q, want name=action:path", part)
+		}
+		ap := strings.SplitN(kv[1], ":", 2)
+		if len(ap) != 2 || ap[1] == "" {
+			return nil, errors.Errorf("malformed entry %q, want name=action:path", part)
+		}
+		l := extraStoplist{
+			name:   strings.TrimSpace(kv[0]),
+			action: strings.ToLower(strings.TrimSpace(ap[0])),
+			path:   strings.TrimSpace(ap[1]),
+		}
+		switch l.action {
+		case StoplistActionBlock, StoplistActionFlag, StoplistActionShadow:
+		default:
+			return nil, errors.Errorf("malformed entry %q, action must be block, flag or shadow", part)
+		}
+		if _, dup := seen[l.name]; dup || l.name == "" {
+			return nil, errors.Errorf("malformed entry %q, name must be unique", part)
+		}
+		seen[l.name] = struct{}{}
+		lists = append(lists, l)
+	}
+	return lists, nil
+}
+
+// NewExtraStoplists loads the stoplists of --stoplists. They are checked
+// after the main one and reloaded like it.
+func NewExtraStoplists(c *cli.Context) ([]*Stoplist, error) {
+	lists, err := parseExtraStoplists(c.String(StoplistsFlag))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse --%v", StoplistsFlag)
+	}
+	var res []*Stoplist
+	for _, l := range lists {
+		s, err := newExtraStoplist(c, l)
+		if err != nil {
+			for _, s := range res {
+				s.Close()
+			}
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func newExtraStoplist(c *cli.Context, l extraStoplist) (*Stoplist, error) {
+	samples, err := newMatchSamplerFromFlags(c)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newNamedStoplist(l.name, l.action, l.path, time.Duration(c.Int(StoplistReloadIntervalFlag))*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	s.samples = samples
+	return s, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseExtraStoplists(t *testing.T) {
+	lists, err := parseExtraStoplists(" next=Shadow:/etc/next.yaml, review=flag:c:/review.yaml ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []extraStoplist{
+		{name: "next", action: StoplistActionShadow, path: "/etc/next.yaml"},
+		{name: "review", action: StoplistActionFlag, path: "c:/review.yaml"},
+	}
+	if len(lists) != len(want) || lists[0] != want[0] || lists[1] != want[1] {
+		t.Fatalf("lists = %+v", lists)
+	}
+	for _, bad := range []string{
+		"next",
+		"next=shadow",
+		"next=drop:/a.yaml",
+		"main=block:/a.yaml",
+		"a=block:/a.yaml,a=flag:/b.yaml",
+	} {
+		if _, err = parseExtraStoplists(bad); err == nil {
+			t.Fatalf("%q must be rejected", bad)
+		}
+	}
+}
+
+// headerStream records the headers set on a gRPC call.
+type headerStream struct {
+	md metadata.MD
+}
+
+func (s *headerStream) Method() string { return "/test" }
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.md = metadata.Join(s.md, md)
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *headerStream) SetTrailer(metadata.MD) error { return nil }
+
+func TestExtraStoplistActions(t *testing.T) {
+	dir := t.TempDir()
+	var lists []*Stoplist
+	for _, l := range []extraStoplist{
+		{name: "next", action: StoplistActionShadow, path: filepath.Join(dir, "next.yaml")},
+		{name: "review", action: StoplistActionFlag, path: filepath.Join(dir, "review.yaml")},
+		{name: "strict", action: StoplistActionBlock, path: filepath.Join(dir, "strict.yaml")},
+	} {
+		writeStoplist(t, l.path, "main:\n  - \"{bad}\"\nbad:\n  - "+l.name+"\n")
+		s, err := newNamedStoplist(l.name, l.action, l.path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		lists = append(lists, s)
+	}
+	srv := &Server{s: NewStore(nil, nil), xsl: lists}
+	hLog := log.WithField("infoHash", allowlistTestHash)
+	check := func(name string) (metadata.MD, error) {
+		st := &headerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), st)
+		_, err := srv.checkStoplist(ctx, namedTorrent(t, name), hLog, time.Now(), allowlistTestHash, true)
+		return st.md, err
+	}
+
+	shadow := stoplistActionMatchesTotal.WithLabelValues("next", StoplistActionShadow, "bad", StoplistFieldName)
+	before := counterValue(t, shadow)
+	md, err := check("next review")
+	if err != nil {
+		t.Fatalf("err = %v, shadow and flag stoplists must let the torrent through", err)
+	}
+	if got := md.Get(stoplistFlagHeader); len(got) != 1 || got[0] != "review" {
+		t.Fatalf("flags = %v, want [review]", got)
+	}
+	if got := counterValue(t, shadow) - before; got != 1 {
+		t.Fatalf("shadow matches = %v, want 1", got)
+	}
+	if md, err = check("next"); err != nil || len(md.Get(stoplistFlagHeader)) != 0 {
+		t.Fatalf("md = %v, err = %v", md, err)
+	}
+	if _, err = check("strict"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied from the block stoplist", err)
+	}
+}
@@ -1,11 +1,16 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	cs "github.com/webtor-io/common-services"
 	"github.com/webtor-io/lazymap"
 
 	log "github.com/sirupsen/logrus"
@@ -15,14 +20,75 @@ type StoreProvider interface {
 	Push(ctx context.Context, h string, torrent []byte) (ok bool, err error)
 	Pull(ctx context.Context, h string) (torrent []byte, err error)
 	Touch(ctx context.Context, h string) (ok bool, err error)
+	// Exists is a cheap presence probe that must not transfer the torrent
+	// body. Returns ErrNotFound when the provider doesn't hold h.
+	Exists(ctx context.Context, h string) (ok bool, err error)
 	// PushManifest stores a derived file manifest for the given infoHash.
 	// A provider may no-op if it opts out of manifest caching.
 	PushManifest(ctx context.Context, h string, manifest []byte) (ok bool, err error)
 	// PullManifest returns a previously cached file manifest, or ErrNotFound.
 	PullManifest(ctx context.Context, h string) (manifest []byte, err error)
+	// Delete removes both the torrent and its derived manifest. Deleting a
+	// missing entry is not an error.
+	Delete(ctx context.Context, h string) (ok bool, err error)
 	Name() string
 }
 
+// DurableProvider is implemented by providers whose writes survive a
+// restart of this process and don't expire. Expiring caches such as Redis
+// are not durable. Write-behind mode only acknowledges a Push once the
+// fastest durable tier has it.
+type DurableProvider interface {
+	Durable() bool
+}
+
+// VerdictProvider is implemented by tiers that keep the stoplist verdict of
+// a torrent next to its manifest. A verdict is looked up on every pull, so
+// only the fast tiers hold one; a wrapper whose tier doesn't reports
+// ErrNotFound and drops writes, like a provider opting out of manifests.
+// Delete removes the verdict along with the torrent.
+type VerdictProvider interface {
+	PushVerdict(ctx context.Context, h string, verdict []byte) (ok bool, err error)
+	// PullVerdict returns the stored verdict, or ErrNotFound.
+	PullVerdict(ctx context.Context, h string) (verdict []byte, err error)
+}
+
+// StoreConfig tunes optional Store behaviour. A nil config keeps defaults.
+type StoreConfig struct {
+	WriteBehind *WriteBehindConfig
+	Guard       *GuardConfig
+	Hedge       *HedgeConfig
+	// NegativeCache, if set, remembers infoHashes no tier holds.
+	NegativeCache NegativeCache
+	// Verdicts enables caching stoplist verdicts in the tiers implementing
+	// VerdictProvider.
+	Verdicts bool
+}
+
+// NewStoreConfig builds a StoreConfig from the flags registered by
+// RegisterStoreFlags.
+func NewStoreConfig(c *cli.Context, cl *cs.RedisClient) (*StoreConfig, error) {
+	guard, err := NewGuardConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return &StoreConfig{
+		WriteBehind:   NewWriteBehindConfig(c),
+		Guard:         guard,
+		Hedge:         NewHedgeConfig(c),
+		NegativeCache: NewNegativeCache(c, cl),
+		Verdicts:      c.Bool(StoplistVerdictCacheFlag),
+	}, nil
+}
+
+// RegisterStoreFlags adds the flags read by NewStoreConfig.
+func RegisterStoreFlags(f []cli.Flag) []cli.Flag {
+	f = RegisterWriteBehindFlags(f)
+	f = RegisterGuardFlags(f)
+	f = RegisterHedgeFlags(f)
+	return RegisterNegativeCacheFlags(f)
+}
+
 type Store struct {
 	pullm        *lazymap.LazyMap[[]byte]
 	pushm        *lazymap.LazyMap[bool]
@@ -30,28 +96,111 @@ type Store struct {
 	manifestm    *lazymap.LazyMap[[]byte]
 	providers    []StoreProvider
 	revProviders []StoreProvider
-	ratem        *lazymap.LazyMap[*atomic.Int64]
+	// syncPush are the tiers push writes before returning (bottom-up);
+	// everything else goes through the write-behind queue wb.
+	syncPush []StoreProvider
+	wb       *writeBehind
+	// hedge is set when pulls query the next tier early on a slow answer.
+	hedge    *hedge
+	negative NegativeCache
+	// verdicts are the tiers caching stoplist verdicts, nil if disabled.
+	verdicts []VerdictProvider
 }
 
 var (
 	ErrNotFound = errors.New("store: torrent not found")
 )
 
-func NewStore(providers []StoreProvider) *Store {
-	cfg := &lazymap.Config{
-		Expire:      5 * time.Minute,
-		StoreErrors: false,
+// StreamPuller is implemented by providers that can hand out the torrent body
+// as a stream instead of a fully read slice. Providers without it are still
+// usable by Store.PullStream through their regular Pull.
+type StreamPuller interface {
+	PullStream(ctx context.Context, h string) (r io.ReadCloser, err error)
+}
+
+// Lister is implemented by providers that can enumerate the infoHashes they
+// hold, for maintenance jobs walking a whole tier. fn is called once per
+// stored torrent; returning an error from it stops the listing.
+type Lister interface {
+	List(ctx context.Context, fn func(h string) error) error
+}
+
+// ListerFunc adapts a listing function, e.g. of a legacy key layout, to
+// Lister.
+type ListerFunc func(ctx context.Context, fn func(h string) error) error
+
+func (f ListerFunc) List(ctx context.Context, fn func(h string) error) error {
+	return f(ctx, fn)
+}
+
+// StoredKeyStats sums up a ForEachStoredKey walk.
+type StoredKeyStats struct {
+	Scanned int64
+	Failed  int64
+}
+
+// ForEachStoredKey lists l and runs fn on every listed infoHash from workers
+// goroutines. A failing fn is counted, not fatal: maintenance jobs walk
+// millions of keys and are rerun until nothing fails, so fn logs its own
+// errors. The walk stops early if ctx is done; the listing error is
+// returned.
+func ForEachStoredKey(ctx context.Context, l Lister, workers int, fn func(ctx context.Context, h string) error) (StoredKeyStats, error) {
+	if workers <= 0 {
+		workers = 1
 	}
+	var scanned, failed atomic.Int64
+	hashes := make(chan string)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for h := range hashes {
+				if err := fn(ctx, h); err != nil {
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+	err := l.List(ctx, func(h string) error {
+		scanned.Add(1)
+		select {
+		case hashes <- h:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(hashes)
+	wg.Wait()
+	return StoredKeyStats{Scanned: scanned.Load(), Failed: failed.Load()}, err
+}
+
+// DeleteResult is the outcome of a Delete on a single provider tier.
+type DeleteResult struct {
+	Provider string
+	Err      error
+}
 
-	rateCfg := &lazymap.Config{
-		Expire:      1 * time.Minute,
+func NewStore(providers []StoreProvider, sc *StoreConfig) *Store {
+	if sc == nil {
+		sc = &StoreConfig{}
+	}
+	cfg := &lazymap.Config{
+		Expire:      5 * time.Minute,
 		StoreErrors: false,
 	}
 	pullm := lazymap.New[[]byte](cfg)
 	pushm := lazymap.New[bool](cfg)
 	touchm := lazymap.New[bool](cfg)
 	manifestm := lazymap.New[[]byte](cfg)
-	ratem := lazymap.New[*atomic.Int64](rateCfg)
+	if sc.Guard != nil && sc.Guard.enabled() {
+		guarded := make([]StoreProvider, len(providers))
+		for i, p := range providers {
+			guarded[i] = newGuardedProvider(p, sc.Guard)
+		}
+		providers = guarded
+	}
 	var revProviders []StoreProvider
 	for _, p := range providers {
 		log.WithField("provider", p.Name()).Info("use provider")
@@ -60,19 +209,46 @@ func NewStore(providers []StoreProvider) *Store {
 	for i := len(providers) - 1; i >= 0; i-- {
 		revProviders = append(revProviders, providers[i])
 	}
-	return &Store{
+	st := &Store{
 		pullm:        &pullm,
 		pushm:        &pushm,
 		touchm:       &touchm,
 		manifestm:    &manifestm,
-		ratem:        &ratem,
 		providers:    providers,
 		revProviders: revProviders,
+		syncPush:     revProviders,
+		negative:     sc.NegativeCache,
+	}
+	if sc.Verdicts {
+		for _, p := range providers {
+			if vp, ok := p.(VerdictProvider); ok {
+				st.verdicts = append(st.verdicts, vp)
+			}
+		}
+	}
+	if sc.WriteBehind != nil && sc.WriteBehind.Enabled {
+		sync, async := splitWriteBehind(providers)
+		if len(async) > 0 {
+			st.syncPush = sync
+			st.wb = newWriteBehind(sc.WriteBehind, async)
+		}
+	}
+	if sc.Hedge != nil && sc.Hedge.Enabled {
+		st.hedge = newHedge(sc.Hedge)
+	}
+	return st
+}
+
+// Close drains the write-behind queue, if any, so pending slow-tier writes
+// aren't lost on a graceful shutdown.
+func (s *Store) Close() {
+	if s.wb != nil {
+		s.wb.Close()
 	}
 }
 
 func (s *Store) push(ctx context.Context, h string, torrent []byte) (ok bool, err error) {
-	for _, v := range s.revProviders {
+	for _, v := range s.syncPush {
 		t := time.Now()
 		ok, err = v.Push(ctx, h, torrent)
 		if err != nil {
@@ -80,40 +256,20 @@ func (s *Store) push(ctx context.Context, h string, torrent []byte) (ok bool, er
 		}
 		log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", v.Name()).Info("provider push")
 	}
-	return
-}
-
-func (s *Store) checkRate(h string) bool {
-	a := s.getRate(h)
-	return a.Load() < 10
-}
-
-func (s *Store) incRate(h string) {
-	a := s.getRate(h)
-	if a.Load() > 15 {
-		return
+	s.forgetMissing(ctx, h)
+	if s.wb != nil {
+		s.wb.Enqueue(h, torrent)
 	}
-	go func() {
-		<-time.After(time.Minute)
-		a.Add(-1)
-	}()
-	a.Add(1)
-}
-
-func (s *Store) getRate(h string) *atomic.Int64 {
-	a, _ := s.ratem.Get(h, func() (*atomic.Int64, error) {
-		return &atomic.Int64{}, nil
-	})
-	return a
+	return
 }
 
 func (s *Store) touch(ctx context.Context, h string) (ok bool, err error) {
-	if !s.checkRate(h) {
-		s.incRate(h)
-		log.WithField("infohash", h).Warn("get rate limit")
+	missing, gen := s.knownMissing(ctx, h)
+	if missing {
 		return false, ErrNotFound
 	}
 	s.touchm.Touch(h)
+	failed := false
 	for i, v := range s.providers {
 		t := time.Now()
 		ok, err = v.Touch(ctx, h)
@@ -122,6 +278,7 @@ func (s *Store) touch(ctx context.Context, h string) (ok bool, err error) {
 			continue
 		} else if err != nil {
 			log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", v.Name()).WithError(err).Warn("provider has error")
+			failed = true
 			continue
 		}
 		log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", v.Name()).Info("provider touch")
@@ -132,43 +289,110 @@ func (s *Store) touch(ctx context.Context, h string) (ok bool, err error) {
 		}
 		break
 	}
-	if err != nil && errors.Is(err, ErrNotFound) {
-		s.incRate(h)
+	if !failed {
+		s.rememberMissing(ctx, h, gen, err)
 	}
 	return
 }
 
+// pull walks the tiers from start. Only a walk from the top tier consults
+// and feeds the negative cache: a partial walk can't confirm absence.
 func (s *Store) pull(ctx context.Context, h string, start int) (torrent []byte, err error) {
-	if !s.checkRate(h) {
-		s.incRate(h)
-		log.WithField("infohash", h).Warn("get rate limit")
-		return nil, ErrNotFound
+	var gen missingGen
+	if start == 0 {
+		var missing bool
+		if missing, gen = s.knownMissing(ctx, h); missing {
+			return nil, ErrNotFound
+		}
+	}
+	if s.hedge != nil {
+		torrent, err = s.hedgedPull(ctx, h, start)
+	} else {
+		torrent, err = s.sequentialPull(ctx, h, start)
+	}
+	if start == 0 {
+		s.rememberMissing(ctx, h, gen, err)
 	}
+	return
+}
+
+// sequentialPull asks providers one by one from start and returns the first
+// hit. A failing tier is skipped, but then ErrNotFound can't be confirmed and
+// its error is returned instead if nothing is found.
+func (s *Store) sequentialPull(ctx context.Context, h string, start int) (torrent []byte, err error) {
+	var lastErr error
 	for i := start; i < len(s.providers); i++ {
 		t := time.Now()
 		torrent, err = s.providers[i].Pull(ctx, h)
 		if errors.Is(err, ErrNotFound) {
 			continue
 		} else if err != nil {
-			return
+			// A degraded tier must not fail the Pull while a lower one may
+			// still hold the torrent.
+			log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", s.providers[i].Name()).WithError(err).Warn("provider has error")
+			lastErr = err
+			continue
 		}
 		log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", s.providers[i].Name()).Info("provider pull")
-		if torrent != nil {
-			for j := 0; j < i; j++ {
-				log.WithField("infohash", h).WithField("provider", s.providers[j].Name()).Info("provider push")
-				_, err = s.providers[j].Push(ctx, h, torrent)
-				if err != nil {
-					log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", s.providers[j].Name()).WithError(err).Warn("provider not pushed")
-					continue
-				}
+		s.backfill(ctx, h, torrent, i, t)
+		return
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, ErrNotFound
+}
+
+// backfill pushes a torrent found on tier i to the faster tiers above it.
+func (s *Store) backfill(ctx context.Context, h string, torrent []byte, i int, t time.Time) {
+	if torrent == nil {
+		return
+	}
+	for j := 0; j < i; j++ {
+		log.WithField("infohash", h).WithField("provider", s.providers[j].Name()).Info("provider push")
+		if _, err := s.providers[j].Push(ctx, h, torrent); err != nil {
+			log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", s.providers[j].Name()).WithError(err).Warn("provider not pushed")
+		}
+	}
+}
+
+// PullStream walks providers like pull but hands out the first hit as a
+// stream, opened via StreamPuller when the provider supports it. It bypasses
+// the pullm lazymap and doesn't backfill upper tiers: both would mean holding
+// the whole body in memory, which is exactly what streaming avoids. Regular
+// Pull keeps warming the fast tiers for the common small-torrent case.
+func (s *Store) PullStream(ctx context.Context, h string) (r io.ReadCloser, err error) {
+	missing, gen := s.knownMissing(ctx, h)
+	if missing {
+		return nil, ErrNotFound
+	}
+	var lastErr error
+	for _, v := range s.providers {
+		t := time.Now()
+		if sp, ok := v.(StreamPuller); ok {
+			r, err = sp.PullStream(ctx, h)
+		} else {
+			var torrent []byte
+			torrent, err = v.Pull(ctx, h)
+			if err == nil {
+				r = io.NopCloser(bytes.NewReader(torrent))
 			}
 		}
-		break
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", v.Name()).WithError(err).Warn("provider has error")
+			lastErr = err
+			continue
+		}
+		log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", v.Name()).Info("provider pull stream")
+		return r, nil
 	}
-	if err != nil && errors.Is(err, ErrNotFound) {
-		s.incRate(h)
+	if lastErr != nil {
+		return nil, lastErr
 	}
-	return
+	s.rememberMissing(ctx, h, gen, ErrNotFound)
+	return nil, ErrNotFound
 }
 
 func (s *Store) Pull(ctx context.Context, h string) ([]byte, error) {
@@ -190,10 +414,72 @@ func (s *Store) Touch(ctx context.Context, h string) (bool, error) {
 	})
 }
 
+// Check walks providers in order and returns the name of the first one
+// holding h, using the per-provider Exists probe instead of a full Pull.
+// A provider error doesn't abort the walk: a lower tier may still answer.
+// If no tier holds h, ErrNotFound is returned unless some tier failed, in
+// which case the last error is surfaced since absence can't be confirmed.
+func (s *Store) Check(ctx context.Context, h string) (provider string, err error) {
+	missing, gen := s.knownMissing(ctx, h)
+	if missing {
+		return "", ErrNotFound
+	}
+	var lastErr error
+	for _, v := range s.providers {
+		t := time.Now()
+		_, err = v.Exists(ctx, h)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", v.Name()).WithError(err).Warn("provider has error")
+			lastErr = err
+			continue
+		}
+		log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", v.Name()).Info("provider check")
+		return v.Name(), nil
+	}
+	if lastErr != nil {
+		return "", lastErr
+	}
+	s.rememberMissing(ctx, h, gen, ErrNotFound)
+	return "", ErrNotFound
+}
+
+// Delete purges h, its manifest and stoplist verdict from every provider and
+// drops the in-process lazymap entries, so neither a cached Pull/Manifest
+// result nor a coalesced Push/Touch outcome outlives the takedown. Tiers are purged
+// bottom-up (like push) so a concurrent Pull can't backfill an upper tier
+// from a lower one that is still pending deletion. A failing tier doesn't
+// stop the others; results are returned in provider order.
+func (s *Store) Delete(ctx context.Context, h string) []DeleteResult {
+	if s.wb != nil {
+		// Write-behind jobs must not resurrect the torrent, so they are
+		// cancelled and waited for before the purge.
+		s.wb.Cancel(h)
+	}
+	res := make([]DeleteResult, len(s.providers))
+	for i, v := range s.revProviders {
+		t := time.Now()
+		_, err := v.Delete(ctx, h)
+		res[len(s.revProviders)-1-i] = DeleteResult{Provider: v.Name(), Err: err}
+		if err != nil {
+			log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", v.Name()).WithError(err).Warn("provider not deleted")
+			continue
+		}
+		log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", v.Name()).Info("provider delete")
+	}
+	s.pullm.Drop(h)
+	s.manifestm.Drop(h)
+	s.pushm.Drop(h)
+	s.touchm.Drop(h)
+	return res
+}
+
 // pullManifest walks providers from `start`, returning the first cached
 // manifest and backfilling the faster upper tiers on a hit. Mirrors pull,
 // but for derived manifests; a provider that opts out of manifest caching
-// reports ErrNotFound and is transparently skipped.
+// reports ErrNotFound and is transparently skipped. A failing tier is
+// skipped as well: the manifest can always be rebuilt from the torrent.
 func (s *Store) pullManifest(ctx context.Context, h string, start int) (manifest []byte, err error) {
 	for i := start; i < len(s.providers); i++ {
 		t := time.Now()
@@ -201,7 +487,8 @@ func (s *Store) pullManifest(ctx context.Context, h string, start int) (manifest
 		if errors.Is(err, ErrNotFound) {
 			continue
 		} else if err != nil {
-			return
+			log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", s.providers[i].Name()).WithError(err).Warn("provider has error")
+			continue
 		}
 		log.WithField("infohash", h).WithField("duration", time.Since(t)).WithField("provider", s.providers[i].Name()).Info("provider pull manifest")
 		if manifest != nil {
@@ -233,8 +520,8 @@ func (s *Store) pushManifest(ctx context.Context, h string, manifest []byte) {
 // Manifest returns the cached file manifest for h, building it via build()
 // from the stored .torrent on a cache miss and persisting it across tiers.
 // The whole get-or-build is singleflighted per infoHash so a cold burst on
-// the same torrent triggers at most one Pull+parse. Manifests are immutable
-// per infoHash, so no invalidation is needed.
+// the same torrent triggers at most one Pull+parse. A manifest only changes
+// with its torrent, so it is invalidated by Delete alone.
 func (s *Store) Manifest(ctx context.Context, h string, build func(torrent []byte) ([]byte, error)) ([]byte, error) {
 	return s.manifestm.Get(h, func() ([]byte, error) {
 		manifest, err := s.pullManifest(ctx, h, 0)
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// errProvider wraps fakeProvider and fails every Exists probe, mimicking a
+// tier whose backend is unreachable.
+type errProvider struct {
+	*fakeProvider
+}
+
+func (e *errProvider) Exists(_ context.Context, _ string) (bool, error) {
+	return false, errors.New("backend down")
+}
+
+func TestStoreCheckReturnsFirstTier(t *testing.T) {
+	fast := newFakeProvider("fast", true)
+	slow := newFakeProvider("slow", true)
+	store := NewStore([]StoreProvider{fast, slow}, nil)
+
+	const h = "abc"
+	_, _ = slow.Push(context.Background(), h, []byte("t"))
+
+	provider, err := store.Check(context.Background(), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider != "slow" {
+		t.Fatalf("provider = %q, want slow", provider)
+	}
+	// A probe must never backfill upper tiers.
+	if _, ok := fast.torrents[h]; ok {
+		t.Fatal("check must not backfill")
+	}
+}
+
+func TestStoreCheckNotFound(t *testing.T) {
+	store := NewStore([]StoreProvider{newFakeProvider("fast", true)}, nil)
+	if _, err := store.Check(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreCheckFallsThroughProviderError(t *testing.T) {
+	broken := &errProvider{newFakeProvider("broken", true)}
+	slow := newFakeProvider("slow", true)
+	store := NewStore([]StoreProvider{broken, slow}, nil)
+
+	_, _ = slow.Push(context.Background(), "h", []byte("t"))
+	provider, err := store.Check(context.Background(), "h")
+	if err != nil || provider != "slow" {
+		t.Fatalf("provider = %q, err = %v; want slow", provider, err)
+	}
+	// Absence can't be confirmed while a tier is failing.
+	if _, err = store.Check(context.Background(), "missing"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want backend error", err)
+	}
+}
+
+func TestStoreDeletePurgesAllTiersAndLazymaps(t *testing.T) {
+	fast := newFakeProvider("fast", true)
+	slow := newFakeProvider("slow", true)
+	store := NewStore([]StoreProvider{fast, slow}, nil)
+
+	const h = "gone"
+	_, _ = slow.Push(context.Background(), h, []byte("t"))
+	_, _ = slow.PushManifest(context.Background(), h, []byte("m"))
+	// Warm the pull lazymap so a stale result would be served after delete.
+	if _, err := store.Pull(context.Background(), h); err != nil {
+		t.Fatal(err)
+	}
+
+	res := store.Delete(context.Background(), h)
+	if len(res) != 2 || res[0].Provider != "fast" || res[1].Provider != "slow" {
+		t.Fatalf("results = %+v, want fast, slow", res)
+	}
+	for _, r := range res {
+		if r.Err != nil {
+			t.Fatalf("provider %v: %v", r.Provider, r.Err)
+		}
+	}
+	if len(fast.torrents)+len(slow.torrents)+len(slow.manifests) != 0 {
+		t.Fatal("torrent or manifest left behind")
+	}
+	if _, err := store.Pull(context.Background(), h); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("pull after delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestForEachStoredKey(t *testing.T) {
+	l := ListerFunc(func(ctx context.Context, fn func(h string) error) error {
+		for i := 0; i < 100; i++ {
+			if err := fn(fmt.Sprint(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	var done atomic.Int64
+	st, err := ForEachStoredKey(context.Background(), l, 4, func(_ context.Context, h string) error {
+		done.Add(1)
+		if h == "7" || h == "42" {
+			return errors.New("bad value")
+		}
+		return nil
+	})
+	if err != nil || st.Scanned != 100 || st.Failed != 2 || done.Load() != 100 {
+		t.Fatalf("stats = %+v, done = %v, err = %v", st, done.Load(), err)
+	}
+
+	// A cancelled walk stops listing.
+	ctx, cancel := context.WithCancel(context.Background())
+	st, err = ForEachStoredKey(ctx, l, 1, func(_ context.Context, h string) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || st.Scanned == 100 {
+		t.Fatalf("stats = %+v, err = %v", st, err)
+	}
+}
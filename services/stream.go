@@ -0,0 +1,130 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamChunkSize keeps every TorrentChunk far below grpcMaxMsgSize.
+const streamChunkSize = 1024 * 1024
+
+func (s *Server) PullStream(in *pb.PullRequest, stream pb.TorrentStore_PullStreamServer) error {
+	ctx := stream.Context()
+	infoHash := in.GetInfoHash()
+
+	if !s.streamable(ctx, infoHash) {
+		// The stoplists need the complete metainfo before a single byte may
+		// be released, so the torrent is buffered server-side and only
+		// chunked on the wire. This caches its verdict, so the next pull
+		// streams.
+		torrent, err := s.pull(ctx, infoHash, "pull_stream")
+		if err != nil {
+			return err
+		}
+		_, err = sendChunks(stream, bytes.NewReader(torrent))
+		return err
+	}
+
+	t := time.Now()
+	hLog := log.WithField("infoHash", infoHash).WithField("method", "pull_stream")
+	hLog.Info("pull stream request")
+
+	if err := s.checkAbuse(ctx, hLog, t, infoHash); err != nil {
+		return err
+	}
+	if err := s.checkRateLimit(ctx, hLog, "pull_stream", infoHash); err != nil {
+		return err
+	}
+	r, err := s.s.PullStream(ctx, infoHash)
+	if errors.Is(err, ErrNotFound) {
+		s.rl.Spend(ctx, "pull_stream", infoHash)
+		hLog.WithField("duration", time.Since(t)).Info("torrent not found")
+		return status.Errorf(codes.NotFound, "unable to find torrent for infoHash=%v", infoHash)
+	} else if err != nil {
+		hLog.WithField("duration", time.Since(t)).WithError(err).Error("failed to pull")
+		return errors.Wrapf(err, "failed to pull torrent infoHash=%v", infoHash)
+	}
+	defer func(r io.ReadCloser) {
+		_ = r.Close()
+	}(r)
+	n, err := sendChunks(stream, r)
+	if err != nil {
+		hLog.WithField("len", n).WithField("duration", time.Since(t)).WithError(err).Error("failed to stream torrent")
+		return errors.Wrapf(err, "failed to stream torrent infoHash=%v", infoHash)
+	}
+	hLog.WithField("len", n).WithField("duration", time.Since(t)).Info("torrent streamed")
+	return nil
+}
+
+// streamable tells whether the torrent of h may be streamed straight from
+// the tiers without being checked first. That takes no stoplists of
+// --stoplists, whose verdicts aren't cached, and a clean cached verdict of
+// the current main stoplist version unless h is allowlisted.
+func (s *Server) streamable(ctx context.Context, h string) bool {
+	if len(s.xsl) > 0 {
+		return false
+	}
+	if s.sl == nil {
+		return true
+	}
+	if _, ok := s.al.AllowedHash(h); ok {
+		return true
+	}
+	v, ok := s.s.Verdict(ctx, h, s.sl.Version())
+	return ok && !v.Found
+}
+
+// sendChunks copies r to the stream in streamChunkSize pieces. The buffer is
+// reused between sends: Send serializes the message before returning.
+func sendChunks(stream pb.TorrentStore_PullStreamServer, r io.Reader) (int64, error) {
+	buf := make([]byte, streamChunkSize)
+	var total int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if serr := stream.Send(&pb.TorrentChunk{Data: buf[:n]}); serr != nil {
+				return total, serr
+			}
+			total += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return total, nil
+		} else if err != nil {
+			return total, err
+		}
+	}
+}
+
+// PushStream reassembles the chunks and hands the complete torrent to the
+// regular push path: computing the infoHash, the stoplist check and the
+// announce merge all need the whole metainfo anyway. streamMaxSize bounds
+// how much a single client can make the server buffer.
+func (s *Server) PushStream(stream pb.TorrentStore_PushStreamServer) error {
+	var buf bytes.Buffer
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if s.streamMaxSize > 0 && int64(buf.Len()+len(chunk.GetData())) > s.streamMaxSize {
+			log.WithField("len", buf.Len()).WithField("method", "push_stream").Warn("torrent exceeds stream limit")
+			return status.Errorf(codes.ResourceExhausted, "torrent exceeds stream limit of %v bytes", s.streamMaxSize)
+		}
+		buf.Write(chunk.GetData())
+	}
+	infoHash, err := s.push(stream.Context(), buf.Bytes(), "push_stream")
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&pb.PushReply{InfoHash: infoHash})
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakePullStream struct {
+	grpc.ServerStream
+	chunks [][]byte
+}
+
+func (f *fakePullStream) Context() context.Context { return context.Background() }
+
+func (f *fakePullStream) Send(c *pb.TorrentChunk) error {
+	f.chunks = append(f.chunks, append([]byte(nil), c.GetData()...))
+	return nil
+}
+
+type fakePushStream struct {
+	grpc.ServerStream
+	chunks [][]byte
+	reply  *pb.PushReply
+}
+
+func (f *fakePushStream) Context() context.Context { return context.Background() }
+
+func (f *fakePushStream) Recv() (*pb.TorrentChunk, error) {
+	if len(f.chunks) == 0 {
+		return nil, io.EOF
+	}
+	c := f.chunks[0]
+	f.chunks = f.chunks[1:]
+	return &pb.TorrentChunk{Data: c}, nil
+}
+
+func (f *fakePushStream) SendAndClose(r *pb.PushReply) error {
+	f.reply = r
+	return nil
+}
+
+func TestSendChunksSplitsLargeBody(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), streamChunkSize*2+10)
+	stream := &fakePullStream{}
+	n, err := sendChunks(stream, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(body)) || len(stream.chunks) != 3 {
+		t.Fatalf("sent %d bytes in %d chunks, want %d in 3", n, len(stream.chunks), len(body))
+	}
+	if !bytes.Equal(bytes.Join(stream.chunks, nil), body) {
+		t.Fatal("reassembled body differs")
+	}
+}
+
+func TestPushStreamThenPullStream(t *testing.T) {
+	p := newFakeProvider("fast", true)
+	srv := &Server{s: NewStore([]StoreProvider{p}, nil)}
+
+	torrent := makeMultiFileTorrent(t, "x", []metainfo.FileInfo{{Path: []string{"a"}, Length: 1}})
+	push := &fakePushStream{chunks: [][]byte{torrent[:10], torrent[10:]}}
+	if err := srv.PushStream(push); err != nil {
+		t.Fatal(err)
+	}
+	if push.reply.GetInfoHash() == "" {
+		t.Fatal("empty infoHash")
+	}
+
+	pull := &fakePullStream{}
+	if err := srv.PullStream(&pb.PullRequest{InfoHash: push.reply.GetInfoHash()}, pull); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.Join(pull.chunks, nil), torrent) {
+		t.Fatal("pulled torrent differs from pushed")
+	}
+}
+
+func TestPushStreamEnforcesMaxSize(t *testing.T) {
+	srv := &Server{s: NewStore(nil, nil), streamMaxSize: 4}
+	err := srv.PushStream(&fakePushStream{chunks: [][]byte{[]byte("abc"), []byte("de")}})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("err = %v, want ResourceExhausted", err)
+	}
+}
+
+func TestPullStreamChecksExtraStoplists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strict.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - strict\n")
+	sl, err := newNamedStoplist("strict", StoplistActionBlock, path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sl.Close()
+	srv := &Server{s: NewStore([]StoreProvider{newFakeProvider("fast", true)}, nil), xsl: []*Stoplist{sl}}
+
+	torrent := namedTorrent(t, "strict")
+	push := &fakePushStream{chunks: [][]byte{torrent}}
+	err = srv.PushStream(push)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied on push", err)
+	}
+	mi, err := metainfo.Load(bytes.NewReader(torrent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := mi.HashInfoBytes().HexString()
+	if _, err = srv.s.Push(context.Background(), h, torrent); err != nil {
+		t.Fatal(err)
+	}
+	pull := &fakePullStream{}
+	err = srv.PullStream(&pb.PullRequest{InfoHash: h}, pull)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied from the extra block stoplist", err)
+	}
+	if len(pull.chunks) != 0 {
+		t.Fatal("blocked torrent must not be streamed")
+	}
+}
+
+// streamingFake counts the pulls served as a stream.
+type streamingFake struct {
+	*fakeProvider
+	streams int
+}
+
+func (f *streamingFake) PullStream(ctx context.Context, h string) (io.ReadCloser, error) {
+	torrent, err := f.Pull(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	f.streams++
+	return io.NopCloser(bytes.NewReader(torrent)), nil
+}
+
+func TestPullStreamStreamsOnCachedCleanVerdict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - apple\n")
+	st, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	p := &streamingFake{fakeProvider: newFakeProvider("s3", true)}
+	srv := &Server{s: NewStore([]StoreProvider{p}, &StoreConfig{Verdicts: true}), sl: st}
+	ctx := context.Background()
+
+	torrent := namedTorrent(t, "ripe banana")
+	reply, err := srv.Push(ctx, &pb.PushRequest{Torrent: torrent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := reply.GetInfoHash()
+
+	// The push cached a clean verdict, so the torrent is streamed.
+	pull := &fakePullStream{}
+	if err = srv.PullStream(&pb.PullRequest{InfoHash: h}, pull); err != nil {
+		t.Fatal(err)
+	}
+	if p.streams != 1 || !bytes.Equal(bytes.Join(pull.chunks, nil), torrent) {
+		t.Fatalf("streams = %v, want the torrent streamed once", p.streams)
+	}
+
+	// A blocking verdict goes through the buffered, checked path.
+	srv.s.PushVerdict(ctx, h, &StoplistVerdict{Version: st.Version(), Found: true, Stack: []string{"main"}})
+	pull = &fakePullStream{}
+	if err = srv.PullStream(&pb.PullRequest{InfoHash: h}, pull); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied", err)
+	}
+	if p.streams != 1 || len(pull.chunks) != 0 {
+		t.Fatalf("streams = %v, chunks = %v, blocked torrent must not be streamed", p.streams, len(pull.chunks))
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/urfave/cli"
+)
+
+const (
+	TrackerBlocklistPathFlag   = "tracker-blocklist-path"
+	TrackerBlocklistActionFlag = "tracker-blocklist-action"
+)
+
+// What happens to a torrent announcing to a blocked tracker.
+const (
+	// TrackerActionReject rejects it with PermissionDenied.
+	TrackerActionReject = "reject"
+	// TrackerActionStrip stores it without the blocked trackers.
+	TrackerActionStrip = "strip"
+)
+
+var (
+	trackerBlocklistTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_tracker_blocklist_total",
+		Help: "Torrents announcing to blocked trackers, labelled by action (reject, strip).",
+	}, []string{"action"})
+)
+
+func RegisterTrackerBlocklistFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.StringFlag{
+			Name:   TrackerBlocklistPathFlag,
+			Usage:  "file of blocked tracker hosts, one per line, .example.com blocks the domain and its subdomains",
+			EnvVar: "TRACKER_BLOCKLIST_PATH",
+		},
+		cli.StringFlag{
+			Name:   TrackerBlocklistActionFlag,
+			Usage:  "what to do with torrents announcing to blocked trackers (reject, strip), checked on push and manifest build, strip only cleans pushed torrents and stored ones are served as they are until pushed again",
+			EnvVar: "TRACKER_BLOCKLIST_ACTION",
+			Value:  TrackerActionStrip,
+		},
+	)
+}
+
+// TrackerBlocklist screens the tracker and web seed hosts of torrents
+// against blocked hosts and domains. Tracker URLs are left out of the
+// stoplist: packs announce to dozens of them and the regex tree is too
+// expensive per URL. A host lookup is cheap, and it only runs on Push and
+// on manifest build, whose results are cached. A nil TrackerBlocklist
+// blocks nothing.
+type TrackerBlocklist struct {
+	hosts   map[string]struct{}
+	domains map[string]struct{}
+	strip   bool
+}
+
+func NewTrackerBlocklist(c *cli.Context) (*TrackerBlocklist, error) {
+	path := c.String(TrackerBlocklistPathFlag)
+	if path == "" {
+		return nil, nil
+	}
+	var strip bool
+	switch a := c.String(TrackerBlocklistActionFlag); a {
+	case TrackerActionReject:
+	case TrackerActionStrip:
+		strip = true
+	default:
+		return nil, errors.Errorf("unknown --%v %q, want reject or strip", TrackerBlocklistActionFlag, a)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read tracker blocklist %q", path)
+	}
+	s := parseTrackerBlocklist(raw)
+	s.strip = strip
+	return s, nil
+}
+
+// parseTrackerBlocklist reads one host per line. A leading dot blocks the
+// domain and all of its subdomains. Empty lines and # comments are skipped.
+func parseTrackerBlocklist(raw []byte) *TrackerBlocklist {
+	s := &TrackerBlocklist{
+		hosts:   map[string]struct{}{},
+		domains: map[string]struct{}{},
+	}
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(line)), ".")
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, ".") {
+			s.domains[line[1:]] = struct{}{}
+		} else {
+			s.hosts[line] = struct{}{}
+		}
+	}
+	return s
+}
+
+// Blocked tells whether the host of the tracker or web seed URL u is
+// blocked.
+func (s *TrackerBlocklist) Blocked(u string) bool {
+	if s == nil {
+		return false
+	}
+	pu, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(pu.Hostname()), ".")
+	if host == "" {
+		return false
+	}
+	if _, ok := s.hosts[host]; ok {
+		return true
+	}
+	for d := host; ; {
+		if _, ok := s.domains[d]; ok {
+			return true
+		}
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			return false
+		}
+		d = d[i+1:]
+	}
+}
+
+// strips tells whether u is to be dropped before storage.
+func (s *TrackerBlocklist) strips(u string) bool {
+	return s != nil && s.strip && s.Blocked(u)
+}
+
+// blockedURLs returns the blocked URLs among Announce, AnnounceList and
+// UrlList of torrent.
+func (s *TrackerBlocklist) blockedURLs(torrent []byte) ([]string, error) {
+	if s == nil {
+		return nil, nil
+	}
+	mi, err := metainfo.Load(bytes.NewReader(torrent))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse torrent")
+	}
+	var blocked []string
+	seen := map[string]struct{}{}
+	check := func(u string) {
+		if _, ok := seen[u]; ok {
+			return
+		}
+		seen[u] = struct{}{}
+		if s.Blocked(u) {
+			blocked = append(blocked, u)
+		}
+	}
+	if mi.Announce != "" {
+		check(mi.Announce)
+	}
+	for _, tier := range mi.UpvertedAnnounceList() {
+		for _, u := range tier {
+			check(u)
+		}
+	}
+	for _, u := range mi.UrlList {
+		check(u)
+	}
+	return blocked, nil
+}
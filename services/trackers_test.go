@@ -0,0 +1,146 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testTrackerBlocklist = `
+# spam trackers
+tracker.spam.example
+.evil.example.
+`
+
+func TestTrackerBlocklistBlocked(t *testing.T) {
+	tb := parseTrackerBlocklist([]byte(testTrackerBlocklist))
+	for u, want := range map[string]bool{
+		"udp://tracker.spam.example:6969/announce": true,
+		"http://TRACKER.SPAM.EXAMPLE./announce":    true,
+		"http://other.spam.example/announce":       false,
+		"http://evil.example/announce":             true,
+		"https://a.b.evil.example/seed/file":       true,
+		"http://notevil.example/announce":          false,
+		"http://good.example/announce":             false,
+		"not a url":                                false,
+	} {
+		if got := tb.Blocked(u); got != want {
+			t.Errorf("Blocked(%q) = %v, want %v", u, got, want)
+		}
+	}
+	var none *TrackerBlocklist
+	if none.Blocked("http://evil.example/announce") {
+		t.Fatal("a nil blocklist must block nothing")
+	}
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMergeStripsBlockedTrackers(t *testing.T) {
+	tb := parseTrackerBlocklist([]byte(testTrackerBlocklist))
+	tb.strip = true
+	existing := makeTorrent(t, "http://x.evil.example/announce",
+		metainfo.AnnounceList{{"http://x.evil.example/announce"}, {"http://good.example/announce"}},
+		metainfo.UrlList{"http://seed.evil.example/f"}, false)
+	incoming := makeTorrent(t, "", metainfo.AnnounceList{{"udp://tracker.spam.example:80/announce", "udp://ok.example:80/announce"}}, nil, false)
+
+	merged, changed, err := mergeTorrent(existing, incoming, []string{"http://default.evil.example/announce"}, tb)
+	if err != nil || !changed {
+		t.Fatalf("changed = %v, err = %v", changed, err)
+	}
+	trackers, urls := parseAnnounce(t, merged)
+	if len(trackers) != 2 || !hasString(trackers, "http://good.example/announce") || !hasString(trackers, "udp://ok.example:80/announce") {
+		t.Fatalf("trackers = %v", trackers)
+	}
+	if len(urls) != 0 {
+		t.Fatalf("urls = %v", urls)
+	}
+	mi, err := metainfo.Load(bytes.NewReader(merged))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.Announce != "http://good.example/announce" {
+		t.Fatalf("announce = %q", mi.Announce)
+	}
+
+	// Reject mode leaves merging alone.
+	tb.strip = false
+	if _, changed, err = mergeTorrent(existing, existing, nil, tb); err != nil || changed {
+		t.Fatalf("changed = %v, err = %v", changed, err)
+	}
+}
+
+func TestServerTrackerBlocklist(t *testing.T) {
+	ctx := context.Background()
+	torrent := makeTorrent(t, "http://tracker.spam.example/announce",
+		metainfo.AnnounceList{{"http://tracker.spam.example/announce"}, {"http://good.example/announce"}}, nil, false)
+	mi, err := metainfo.Load(bytes.NewReader(torrent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := mi.HashInfoBytes().HexString()
+
+	tb := parseTrackerBlocklist([]byte(testTrackerBlocklist))
+	srv := &Server{s: NewStore([]StoreProvider{newFakeProvider("fast", true)}, nil), tb: tb}
+	if _, err = srv.Push(ctx, &pb.PushRequest{Torrent: torrent}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied in reject mode", err)
+	}
+
+	tb.strip = true
+	if _, err = srv.Push(ctx, &pb.PushRequest{Torrent: torrent}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := srv.s.Pull(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trackers, _ := parseAnnounce(t, stored); len(trackers) != 1 || trackers[0] != "http://good.example/announce" {
+		t.Fatalf("trackers = %v", trackers)
+	}
+
+	// A torrent stored before the tracker got blocked is left as it is by
+	// manifest build and Pull, and cleaned by the next push.
+	p := newFakeProvider("fast", true)
+	_, _ = p.Push(ctx, h, torrent)
+	srv = &Server{s: NewStore([]StoreProvider{p}, nil), tb: tb}
+	if _, err = srv.Files(ctx, &pb.FilesRequest{InfoHash: h}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := srv.Pull(ctx, &pb.PullRequest{InfoHash: h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r.Torrent, torrent) {
+		t.Fatal("pull must serve the stored copy as it is")
+	}
+	if _, err = srv.Push(ctx, &pb.PushRequest{Torrent: torrent}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = p.Pull(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trackers, _ := parseAnnounce(t, stored); len(trackers) != 1 {
+		t.Fatalf("trackers = %v", trackers)
+	}
+
+	tb.strip = false
+	p = newFakeProvider("fast", true)
+	_, _ = p.Push(ctx, h, torrent)
+	srv = &Server{s: NewStore([]StoreProvider{p}, nil), tb: tb}
+	if _, err = srv.Files(ctx, &pb.FilesRequest{InfoHash: h}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied on manifest build in reject mode", err)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	sl "github.com/webtor-io/stoplist"
+)
+
+var (
+	verdictCacheTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_stoplist_verdict_cache_total",
+		Help: "Stoplist verdict cache lookups, labelled by result (hit, miss, stale).",
+	}, []string{"result"})
+)
+
+// StoplistVerdict is the outcome of a stoplist check of a stored torrent
+// along with the stoplist version it was reached with.
+type StoplistVerdict struct {
+	Version string   `json:"version"`
+	Found   bool     `json:"found"`
+	Stack   []string `json:"stack,omitempty"`
+	// Rule and Field label the block in torrent_store_stoplist_blocks_total.
+	Rule  string `json:"rule,omitempty"`
+	Field string `json:"field,omitempty"`
+}
+
+func (v *StoplistVerdict) result() *sl.CheckResult {
+	return &sl.CheckResult{Found: v.Found, Stack: v.Stack}
+}
+
+// Verdict returns the cached stoplist verdict of h if it was reached with
+// the given stoplist version. Tiers keep a single verdict per infoHash, so
+// a verdict of another version is stale and a reload invalidates verdicts
+// without leaving old ones behind. A hit backfills the faster tiers above.
+// Lookup failures read as a miss: the verdict can always be recomputed.
+func (s *Store) Verdict(ctx context.Context, h string, version string) (*StoplistVerdict, bool) {
+	for i, p := range s.verdicts {
+		raw, err := p.PullVerdict(ctx, h)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			log.WithField("infohash", h).WithError(err).Warn("failed to pull stoplist verdict")
+			continue
+		}
+		var v StoplistVerdict
+		if err = json.Unmarshal(raw, &v); err != nil {
+			log.WithField("infohash", h).WithError(err).Warn("failed to parse stoplist verdict")
+			continue
+		}
+		if v.Version != version {
+			verdictCacheTotal.WithLabelValues("stale").Inc()
+			return nil, false
+		}
+		for j := 0; j < i; j++ {
+			if _, err = s.verdicts[j].PushVerdict(ctx, h, raw); err != nil {
+				log.WithField("infohash", h).WithError(err).Warn("stoplist verdict not backfilled")
+			}
+		}
+		verdictCacheTotal.WithLabelValues("hit").Inc()
+		return &v, true
+	}
+	if s.verdicts != nil {
+		verdictCacheTotal.WithLabelValues("miss").Inc()
+	}
+	return nil, false
+}
+
+// PushVerdict caches the stoplist verdict of h in every verdict tier.
+// Failures are logged only, like manifest writes.
+func (s *Store) PushVerdict(ctx context.Context, h string, v *StoplistVerdict) {
+	if s.verdicts == nil {
+		return
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		log.WithField("infohash", h).WithError(err).Warn("failed to marshal stoplist verdict")
+		return
+	}
+	for i := len(s.verdicts) - 1; i >= 0; i-- {
+		if _, err = s.verdicts[i].PushVerdict(ctx, h, raw); err != nil {
+			log.WithField("infohash", h).WithError(err).Warn("stoplist verdict not pushed")
+		}
+	}
+}
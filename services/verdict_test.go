@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+	pb "github.com/webtor-io/torrent-store/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStoplistVerdictCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stoplist.yaml")
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - apple\n")
+	st, err := newStoplist(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	p := newFakeProvider("fast", true)
+	slow := newFakeProvider("slow", true)
+	srv := &Server{s: NewStore([]StoreProvider{p, slow}, &StoreConfig{Verdicts: true}), sl: st}
+	ctx := context.Background()
+
+	torrent := namedTorrent(t, "ripe banana")
+	mi, err := metainfo.Load(bytes.NewReader(torrent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := mi.HashInfoBytes().HexString()
+
+	// A push stored as-is caches its intake verdict.
+	if _, err = srv.Push(ctx, &pb.PushRequest{Torrent: torrent}); err != nil {
+		t.Fatal(err)
+	}
+	v1 := st.Version()
+	if v, ok := srv.s.Verdict(ctx, h, v1); !ok || v.Found {
+		t.Fatalf("verdict = %+v, %v", v, ok)
+	}
+	// Verdicts sit in their own slot next to manifests, in every tier.
+	p.mu.Lock()
+	manifests, verdicts := len(p.manifests), len(p.verdicts)
+	p.mu.Unlock()
+	if manifests != 0 || verdicts != 1 {
+		t.Fatalf("tier holds %v manifests and %v verdicts, want only the verdict", manifests, verdicts)
+	}
+	// A hit on a lower tier backfills the upper one.
+	p.mu.Lock()
+	delete(p.verdicts, h)
+	p.mu.Unlock()
+	if _, ok := srv.s.Verdict(ctx, h, v1); !ok {
+		t.Fatal("verdict must be found on the slow tier")
+	}
+	if _, err = p.PullVerdict(ctx, h); err != nil {
+		t.Fatalf("verdict not backfilled: %v", err)
+	}
+
+	// Pulls are answered from the cache: a planted verdict wins over the
+	// stoplist itself.
+	srv.s.PushVerdict(ctx, h, &StoplistVerdict{Version: v1, Found: true, Stack: []string{"main"}})
+	if _, err = srv.Pull(ctx, &pb.PullRequest{InfoHash: h}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied from the cached verdict", err)
+	}
+
+	// A reload makes the cached verdict stale, the next pull checks afresh
+	// and caches the verdict of the new version.
+	writeStoplist(t, path, "main:\n  - \"{bad}\"\nbad:\n  - banana\n")
+	if err = st.reload(); err != nil {
+		t.Fatal(err)
+	}
+	v2 := st.Version()
+	if _, ok := srv.s.Verdict(ctx, h, v2); ok {
+		t.Fatal("verdict of the previous version must be stale")
+	}
+	if _, err = srv.Pull(ctx, &pb.PullRequest{InfoHash: h}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied by the new version", err)
+	}
+	if v, ok := srv.s.Verdict(ctx, h, v2); !ok || !v.Found {
+		t.Fatalf("verdict = %+v, %v", v, ok)
+	}
+
+	// Delete drops the verdict along with the torrent.
+	srv.s.Delete(ctx, h)
+	if _, ok := srv.s.Verdict(ctx, h, v2); ok {
+		t.Fatal("verdict must be deleted with the torrent")
+	}
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	WriteBehindFlag        = "write-behind"
+	WriteBehindQueueFlag   = "write-behind-queue"
+	WriteBehindWorkersFlag = "write-behind-workers"
+	WriteBehindRetriesFlag = "write-behind-retries"
+	WriteBehindBackoffFlag = "write-behind-backoff"
+	WriteBehindTimeoutFlag = "write-behind-timeout"
+)
+
+var (
+	writeBehindQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "torrent_store_write_behind_queue_depth",
+		Help: "Slow-tier writes waiting in the write-behind queue.",
+	})
+	writeBehindRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_write_behind_retries_total",
+		Help: "Write-behind attempts that failed and were retried, labelled by provider.",
+	}, []string{"provider"})
+	writeBehindFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "torrent_store_write_behind_failures_total",
+		Help: "Write-behind writes dropped after exhausting retries, labelled by provider.",
+	}, []string{"provider"})
+)
+
+func RegisterWriteBehindFlags(f []cli.Flag) []cli.Flag {
+	return append(f,
+		cli.BoolFlag{
+			Name:   WriteBehindFlag,
+			Usage:  "acknowledge push after the fastest durable tier (fs, s3) and write slower tiers in background",
+			EnvVar: "WRITE_BEHIND",
+		},
+		cli.IntFlag{
+			Name:   WriteBehindQueueFlag,
+			Usage:  "write-behind queue size",
+			Value:  1000,
+			EnvVar: "WRITE_BEHIND_QUEUE",
+		},
+		cli.IntFlag{
+			Name:   WriteBehindWorkersFlag,
+			Usage:  "write-behind workers",
+			Value:  4,
+			EnvVar: "WRITE_BEHIND_WORKERS",
+		},
+		cli.IntFlag{
+			Name:   WriteBehindRetriesFlag,
+			Usage:  "write-behind retries per tier",
+			Value:  5,
+			EnvVar: "WRITE_BEHIND_RETRIES",
+		},
+		cli.IntFlag{
+			Name:   WriteBehindBackoffFlag,
+			Usage:  "write-behind initial retry backoff (ms), doubled on every retry",
+			Value:  500,
+			EnvVar: "WRITE_BEHIND_BACKOFF",
+		},
+		cli.IntFlag{
+			Name:   WriteBehindTimeoutFlag,
+			Usage:  "write-behind timeout per attempt (sec)",
+			Value:  30,
+			EnvVar: "WRITE_BEHIND_TIMEOUT",
+		},
+	)
+}
+
+type WriteBehindConfig struct {
+	Enabled bool
+	Queue   int
+	Workers int
+	Retries int
+	Backoff time.Duration
+	Timeout time.Duration
+}
+
+func NewWriteBehindConfig(c *cli.Context) *WriteBehindConfig {
+	return &WriteBehindConfig{
+		Enabled: c.Bool(WriteBehindFlag),
+		Queue:   c.Int(WriteBehindQueueFlag),
+		Workers: c.Int(WriteBehindWorkersFlag),
+		Retries: c.Int(WriteBehindRetriesFlag),
+		Backoff: time.Duration(c.Int(WriteBehindBackoffFlag)) * time.Millisecond,
+		Timeout: time.Duration(c.Int(WriteBehindTimeoutFlag)) * time.Second,
+	}
+}
+
+// splitWriteBehind partitions providers (fastest first) into the tiers push
+// writes synchronously — everything up to and including the first durable
+// one, returned bottom-up like revProviders — and the slower tiers below it
+// that are written behind. Without any durable tier everything stays sync.
+func splitWriteBehind(providers []StoreProvider) (sync []StoreProvider, async []StoreProvider) {
+	cut := len(providers)
+	for i, p := range providers {
+		if d, ok := p.(DurableProvider); ok && d.Durable() {
+			cut = i + 1
+			break
+		}
+	}
+	for i := cut - 1; i >= 0; i-- {
+		sync = append(sync, providers[i])
+	}
+	return sync, providers[cut:]
+}
+
+type writeBehindJob struct {
+	h       string
+	torrent []byte
+	p       StoreProvider
+	gen     uint64
+}
+
+// writeBehind is a bounded queue of slow-tier writes drained by a fixed
+// worker pool. Each job is retried with exponential backoff. Every worker
+// has its own queue and an infoHash always maps to the same one, so writes
+// of h land in push order: an older, smaller announce list of a merge never
+// overwrites a newer one. Cancel bumps a per-infoHash generation so jobs
+// queued before a Delete are skipped instead of resurrecting the torrent,
+// and stops the ones already being written.
+type writeBehind struct {
+	cfg       *WriteBehindConfig
+	providers []StoreProvider
+	queues    []chan *writeBehindJob
+	closeC    chan struct{}
+	wg        sync.WaitGroup
+	// sending counts Enqueue calls blocked on a full queue, Close waits
+	// for them before closing the queues.
+	sending sync.WaitGroup
+	mux     sync.Mutex
+	closed  bool
+	gens    map[string]uint64
+	pending map[string]int
+	// running holds the cancel funcs of the jobs being written, idle is
+	// signalled whenever one of them finishes.
+	running map[string]map[*writeBehindJob]context.CancelFunc
+	idle    *sync.Cond
+}
+
+func newWriteBehind(cfg *WriteBehindConfig, providers []StoreProvider) *writeBehind {
+	s := initWriteBehind(cfg, providers)
+	for _, p := range providers {
+		log.WithField("provider", p.Name()).Info("use write-behind for provider")
+	}
+	s.startWorkers()
+	return s
+}
+
+// initWriteBehind sets up the queues, the Queue slots are shared out
+// between Workers.
+func initWriteBehind(cfg *WriteBehindConfig, providers []StoreProvider) *writeBehind {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	queue := cfg.Queue / workers
+	if queue <= 0 {
+		queue = 1
+	}
+	s := &writeBehind{
+		cfg:       cfg,
+		providers: providers,
+		queues:    make([]chan *writeBehindJob, workers),
+		closeC:    make(chan struct{}),
+		gens:      map[string]uint64{},
+		pending:   map[string]int{},
+		running:   map[string]map[*writeBehindJob]context.CancelFunc{},
+	}
+	for i := range s.queues {
+		s.queues[i] = make(chan *writeBehindJob, queue)
+	}
+	s.idle = sync.NewCond(&s.mux)
+	return s
+}
+
+func (s *writeBehind) startWorkers() {
+	s.wg.Add(len(s.queues))
+	for _, q := range s.queues {
+		go s.worker(q)
+	}
+}
+
+// queue returns the queue of h.
+func (s *writeBehind) queue(h string) chan *writeBehindJob {
+	f := fnv.New32a()
+	_, _ = f.Write([]byte(h))
+	return s.queues[f.Sum32()%uint32(len(s.queues))]
+}
+
+func (s *writeBehind) depth() int {
+	n := 0
+	for _, q := range s.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// Enqueue schedules a write of torrent to every write-behind tier. When the
+// queue of h is full Enqueue blocks until a slot frees up: backpressure on
+// Push is preferable to silently losing the slow-tier copy, and writing
+// inline would overtake the queued writes of h.
+func (s *writeBehind) Enqueue(h string, torrent []byte) {
+	q := s.queue(h)
+	for _, p := range s.providers {
+		s.mux.Lock()
+		j := &writeBehindJob{h: h, torrent: torrent, p: p, gen: s.gens[h]}
+		s.pending[h]++
+		if s.closed {
+			s.mux.Unlock()
+			s.run(j)
+			continue
+		}
+		s.sending.Add(1)
+		s.mux.Unlock()
+		select {
+		case q <- j:
+		default:
+			log.WithField("infohash", h).WithField("provider", p.Name()).Warn("write-behind queue full, waiting")
+			q <- j
+		}
+		s.sending.Done()
+		writeBehindQueueDepth.Set(float64(s.depth()))
+	}
+}
+
+// Cancel drops every queued write for h and stops the ones in flight. It
+// returns once no write for h is running anymore, so a Delete purging the
+// tiers afterwards can't be undone by a write-behind job.
+func (s *writeBehind) Cancel(h string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.pending[h] > 0 {
+		s.gens[h]++
+	}
+	for _, cancel := range s.running[h] {
+		cancel()
+	}
+	for len(s.running[h]) > 0 {
+		s.idle.Wait()
+	}
+}
+
+// done releases a queued job and forgets h once nothing is pending for it.
+func (s *writeBehind) done(j *writeBehindJob) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.pending[j.h]--
+	if s.pending[j.h] <= 0 {
+		delete(s.pending, j.h)
+		delete(s.gens, j.h)
+	}
+	writeBehindQueueDepth.Set(float64(s.depth()))
+}
+
+// start registers j as running unless it was cancelled. The returned
+// context is cancelled by Cancel.
+func (s *writeBehind) start(j *writeBehindJob) (context.Context, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.gens[j.h] != j.gen {
+		return nil, false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if s.running[j.h] == nil {
+		s.running[j.h] = map[*writeBehindJob]context.CancelFunc{}
+	}
+	s.running[j.h][j] = cancel
+	return ctx, true
+}
+
+func (s *writeBehind) finish(j *writeBehindJob) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.running[j.h][j]()
+	delete(s.running[j.h], j)
+	if len(s.running[j.h]) == 0 {
+		delete(s.running, j.h)
+	}
+	s.idle.Broadcast()
+}
+
+// run writes j unless it was cancelled and releases it.
+func (s *writeBehind) run(j *writeBehindJob) {
+	if ctx, ok := s.start(j); ok {
+		s.write(ctx, j)
+		s.finish(j)
+	}
+	s.done(j)
+}
+
+func (s *writeBehind) worker(q chan *writeBehindJob) {
+	defer s.wg.Done()
+	for j := range q {
+		s.run(j)
+	}
+}
+
+// write pushes a single job, retrying with exponential backoff. Retries stop
+// as soon as the job is cancelled.
+func (s *writeBehind) write(ctx context.Context, j *writeBehindJob) {
+	backoff := s.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		t := time.Now()
+		actx, cancel := ctx, func() {}
+		if s.cfg.Timeout > 0 {
+			actx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		}
+		_, err := j.p.Push(actx, j.h, j.torrent)
+		cancel()
+		hLog := log.WithField("infohash", j.h).WithField("duration", time.Since(t)).WithField("provider", j.p.Name())
+		if err == nil {
+			hLog.Info("provider write-behind push")
+			return
+		}
+		if ctx.Err() != nil {
+			hLog.Info("provider write-behind push cancelled")
+			return
+		}
+		if attempt >= s.cfg.Retries {
+			writeBehindFailuresTotal.WithLabelValues(j.p.Name()).Inc()
+			hLog.WithError(err).Error("provider write-behind push failed, giving up")
+			return
+		}
+		writeBehindRetriesTotal.WithLabelValues(j.p.Name()).Inc()
+		hLog.WithError(err).WithField("attempt", attempt+1).Warn("provider write-behind push failed, retrying")
+		select {
+		case <-time.After(backoff):
+		case <-s.closeC:
+			// On shutdown finish with one last immediate attempt per retry
+			// instead of sleeping through the backoff.
+		case <-ctx.Done():
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// Close stops accepting jobs and waits until the queue is drained.
+func (s *writeBehind) Close() {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.closeC)
+	s.mux.Unlock()
+	s.sending.Wait()
+	for _, q := range s.queues {
+		close(q)
+	}
+	s.wg.Wait()
+}
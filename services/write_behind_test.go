@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// durableProvider marks a fakeProvider as a durable tier.
+type durableProvider struct {
+	*fakeProvider
+}
+
+func (d *durableProvider) Durable() bool { return true }
+
+// flakyProvider fails the first `fails` pushes.
+type flakyProvider struct {
+	*fakeProvider
+	fails  int32
+	pushes atomic.Int32
+}
+
+func (f *flakyProvider) Push(ctx context.Context, h string, torrent []byte) (bool, error) {
+	if f.pushes.Add(1) <= f.fails {
+		return false, errors.New("s3 hiccup")
+	}
+	return f.fakeProvider.Push(ctx, h, torrent)
+}
+
+func TestSplitWriteBehind(t *testing.T) {
+	mem := newFakeProvider("memory", true)
+	redis := newFakeProvider("redis", true)
+	fs := &durableProvider{newFakeProvider("fs", true)}
+	s3 := &durableProvider{newFakeProvider("s3", false)}
+
+	sync, async := splitWriteBehind([]StoreProvider{mem, redis, fs, s3})
+	if len(sync) != 3 || sync[0].Name() != "fs" || sync[1].Name() != "redis" || sync[2].Name() != "memory" {
+		t.Fatalf("sync = %v, want [fs redis memory]", sync)
+	}
+	if len(async) != 1 || async[0].Name() != "s3" {
+		t.Fatalf("async = %v, want [s3]", async)
+	}
+
+	sync, async = splitWriteBehind([]StoreProvider{mem})
+	if len(sync) != 1 || len(async) != 0 {
+		t.Fatalf("without durable tier everything must stay sync")
+	}
+}
+
+func TestStorePushWriteBehindRetriesSlowTier(t *testing.T) {
+	fs := &durableProvider{newFakeProvider("fs", true)}
+	s3 := &flakyProvider{fakeProvider: newFakeProvider("s3", false), fails: 2}
+	store := NewStore([]StoreProvider{fs, s3}, &StoreConfig{
+		WriteBehind: &WriteBehindConfig{Enabled: true, Queue: 10, Workers: 1, Retries: 3, Backoff: time.Millisecond},
+	})
+
+	if _, err := store.Push(context.Background(), "h", []byte("t")); err != nil {
+		t.Fatalf("push must succeed once the durable tier has it: %v", err)
+	}
+	if _, err := fs.Pull(context.Background(), "h"); err != nil {
+		t.Fatal("sync tier not written")
+	}
+	store.Close()
+	if _, err := s3.fakeProvider.Pull(context.Background(), "h"); err != nil {
+		t.Fatal("write-behind tier not written after retries")
+	}
+	if n := s3.pushes.Load(); n != 3 {
+		t.Fatalf("s3 pushes = %d, want 3", n)
+	}
+}
+
+func TestWriteBehindCancelSkipsQueuedWrites(t *testing.T) {
+	s3 := newFakeProvider("s3", false)
+	wb := initWriteBehind(&WriteBehindConfig{Queue: 10, Workers: 1}, []StoreProvider{s3})
+	// No workers yet: the job stays queued until Cancel has run.
+	wb.Enqueue("h", []byte("t"))
+	wb.Cancel("h")
+	wb.startWorkers()
+	wb.Close()
+	if _, err := s3.Pull(context.Background(), "h"); !errors.Is(err, ErrNotFound) {
+		t.Fatal("cancelled write must not reach the tier")
+	}
+}
+
+// hangingProvider blocks every Push until its context is done.
+type hangingProvider struct {
+	*fakeProvider
+	entered chan struct{}
+}
+
+func (p *hangingProvider) Push(ctx context.Context, _ string, _ []byte) (bool, error) {
+	close(p.entered)
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestStoreDeleteStopsRunningWriteBehind(t *testing.T) {
+	fs := &durableProvider{newFakeProvider("fs", true)}
+	s3 := &hangingProvider{fakeProvider: newFakeProvider("s3", false), entered: make(chan struct{})}
+	store := NewStore([]StoreProvider{fs, s3}, &StoreConfig{
+		WriteBehind: &WriteBehindConfig{Enabled: true, Queue: 10, Workers: 1, Retries: 3, Backoff: time.Hour},
+	})
+	defer store.Close()
+	ctx := context.Background()
+
+	if _, err := store.Push(ctx, "h", []byte("t")); err != nil {
+		t.Fatal(err)
+	}
+	<-s3.entered
+	done := make(chan struct{})
+	go func() {
+		store.Delete(ctx, "h")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delete must stop the running write-behind job")
+	}
+	if _, err := fs.Pull(ctx, "h"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWriteBehindKeepsPushOrderPerInfoHash(t *testing.T) {
+	s3 := newFakeProvider("s3", false)
+	wb := initWriteBehind(&WriteBehindConfig{Queue: 400, Workers: 8}, []StoreProvider{s3})
+	for i := 0; i < 50; i++ {
+		wb.Enqueue("h", []byte{byte(i)})
+	}
+	wb.startWorkers()
+	wb.Close()
+	torrent, err := s3.Pull(context.Background(), "h")
+	if err != nil || len(torrent) != 1 || torrent[0] != 49 {
+		t.Fatalf("torrent = %v, err = %v, want the last push", torrent, err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	s "github.com/webtor-io/torrent-store/services"
+)
+
+const (
+	stoplistFixturesFlag = "fixtures"
+)
+
+func makeStoplistCMD() cli.Command {
+	return cli.Command{
+		Name:  "stoplist",
+		Usage: "Stoplist maintenance",
+		Subcommands: []cli.Command{
+			makeStoplistTestCMD(),
+		},
+	}
+}
+
+func makeStoplistTestCMD() cli.Command {
+	stoplistTestCmd := cli.Command{
+		Name:   "test",
+		Usage:  "Checks the stoplist rules against a fixtures file",
+		Action: stoplistTest,
+	}
+	configureStoplistTest(&stoplistTestCmd)
+	return stoplistTestCmd
+}
+
+func configureStoplistTest(c *cli.Command) {
+	c.Flags = append(c.Flags,
+		cli.StringFlag{
+			Name:   s.StoplistPathFlag,
+			Usage:  "stoplist path",
+			EnvVar: "STOPLIST_PATH",
+		},
+		cli.StringFlag{
+			Name:  stoplistFixturesFlag,
+			Usage: "YAML list of fixtures (name, text or torrent, found, rule)",
+		},
+	)
+}
+
+func stoplistTest(c *cli.Context) (err error) {
+	stoplist, err := s.NewStoplist(c)
+	if err != nil {
+		return
+	}
+	if stoplist == nil {
+		return errors.Errorf("no stoplist, set --%v", s.StoplistPathFlag)
+	}
+	defer stoplist.Close()
+	path := c.String(stoplistFixturesFlag)
+	if path == "" {
+		return errors.Errorf("no fixtures, set --%v", stoplistFixturesFlag)
+	}
+	fixtures, err := s.LoadStoplistFixtures(path)
+	if err != nil {
+		return
+	}
+	failures := stoplist.TestFixtures(fixtures)
+	for _, f := range failures {
+		fmt.Fprintln(c.App.Writer, f.String())
+	}
+	fmt.Fprintf(c.App.Writer, "%v fixtures, %v failures, stoplist version %v\n", len(fixtures), len(failures), stoplist.Version())
+	if len(failures) > 0 {
+		return errors.Errorf("%v stoplist fixture failures", len(failures))
+	}
+	return
+}